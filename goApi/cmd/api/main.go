@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/floxo05/todoapi/internal/repository"
 	"github.com/floxo05/todoapi/internal/routes"
 	"github.com/floxo05/todoapi/internal/services"
@@ -9,8 +10,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"log"
+	"os"
+	"strconv"
+	"time"
 )
 
+// todos stay in the trash for 30 days unless TRASH_RETENTION_DAYS says otherwise
+const defaultTrashRetentionDays = 30
+
 func main() {
 	tools.DoMigration("up")
 
@@ -24,7 +31,8 @@ func main() {
 	// enable CORS
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
-	config.AllowHeaders = append(config.AllowHeaders, "Authorization")
+	config.AllowHeaders = append(config.AllowHeaders, "Authorization", "X-Link-Password", "X-Workspace-ID", "If-Match")
+	config.ExposeHeaders = append(config.ExposeHeaders, "ETag")
 	r.Use(cors.New(config))
 
 	db, err := tools.InitDB()
@@ -33,33 +41,142 @@ func main() {
 	}
 
 	catRepo := repository.NewCategoryRepo(db)
-	todoRepo := repository.NewTodoRepo(db, catRepo)
+	tagRepo := repository.NewTagRepo(db)
+	searchIndex := repository.NewMariaDBSearchIndex(db)
+	todoRepo := repository.NewTodoRepo(db, catRepo, tagRepo, searchIndex)
 	userRepo := repository.NewUserRepo(db, todoRepo)
+	invitationRepo := repository.NewInvitationRepo(db, todoRepo, userRepo)
+	publicLinkRepo := repository.NewPublicLinkRepo(db, todoRepo, catRepo)
+	workspaceRepo := repository.NewWorkspaceRepo(db)
+	ownershipRepo := repository.NewOwnershipRepo(db, todoRepo)
+	commentRepo := repository.NewCommentRepo(db, todoRepo, userRepo)
+	activityRepo := repository.NewActivityRepo(db, todoRepo)
+	transactionManager := repository.NewTransactionManager(db, searchIndex)
 	userContextHelper := services.NewUserContext(userRepo)
 	passwordHasher := services.NewPasswordHasher()
+	recurrence := services.NewRecurrence()
+	markdownRenderer := services.NewMarkdownRenderer()
+	trashPurger := services.NewTrashPurger(transactionManager, trashRetention(), time.Hour)
+	autoArchiver := services.NewAutoArchiver(todoRepo, time.Hour)
 
-	todoRoute := routes.NewTodoRoute(todoRepo, userContextHelper)
-	userRoute := routes.NewUserRoute(userRepo, passwordHasher, userContextHelper)
+	todoRoute := routes.NewTodoRoute(todoRepo, transactionManager, userContextHelper, recurrence, markdownRenderer)
+	userRoute := routes.NewUserRoute(userRepo, transactionManager, passwordHasher, userContextHelper)
 	tokenRoute := routes.NewTokenRoute()
-	catRoute := routes.NewCategoryRoute(catRepo, userContextHelper)
+	catRoute := routes.NewCategoryRoute(catRepo, userRepo, transactionManager, userContextHelper)
+	tagRoute := routes.NewTagRoute(tagRepo, transactionManager, userContextHelper)
+	bulkRoute := routes.NewBulkRoute(transactionManager, userContextHelper, recurrence)
+	invitationRoute := routes.NewInvitationRoute(invitationRepo, userRepo, transactionManager, userContextHelper)
+	publicLinkRoute := routes.NewPublicLinkRoute(publicLinkRepo, todoRepo, catRepo, passwordHasher, userContextHelper)
+	workspaceRoute := routes.NewWorkspaceRoute(workspaceRepo, userRepo, userContextHelper)
+	ownershipRoute := routes.NewOwnershipRoute(ownershipRepo, userRepo, transactionManager, userContextHelper)
+	commentRoute := routes.NewCommentRoute(commentRepo, transactionManager, userContextHelper)
+	activityRoute := routes.NewActivityRoute(activityRepo, userContextHelper)
 
 	// register Routes
 	authRoutes := r.Group("/auth")
 	{
 		authRoutes.Use(routes.JWTAuthMiddleware())
+		authRoutes.Use(routes.WorkspaceMiddleware(workspaceRepo, userContextHelper))
 		authRoutes.POST("/todo/create", todoRoute.CreateTodo)
 		authRoutes.GET("/todos", todoRoute.GetTodos)
+		authRoutes.GET("/todos/search", todoRoute.SearchTodos)
+		authRoutes.GET("/todos/archived", todoRoute.GetArchivedTodos)
+		authRoutes.POST("/todos/archive-completed", todoRoute.ArchiveCompletedTodos)
+		authRoutes.POST("/todos/bulk", bulkRoute.ExecuteBulk)
+		authRoutes.GET("/todo/:id", todoRoute.GetTodo)
+		authRoutes.PUT("/todo/:id/parent", todoRoute.MoveTodo)
+		authRoutes.PUT("/todo/:id/position", todoRoute.ReorderTodo)
+		authRoutes.GET("/todo/:id/description", todoRoute.GetTodoDescription)
+		authRoutes.POST("/markdown/render", todoRoute.RenderMarkdown)
 		authRoutes.PUT("/todo/:id", todoRoute.UpdateTodo)
+		authRoutes.PATCH("/todo/:id", todoRoute.PatchTodo)
+		authRoutes.GET("/todo/:id/revisions", todoRoute.GetTodoRevisions)
+		authRoutes.POST("/todo/:id/revisions/:revisionId/revert", todoRoute.RevertTodo)
 		authRoutes.DELETE("/todo/:id", todoRoute.DeleteTodo)
+		authRoutes.POST("/todo/:id/archive", todoRoute.ArchiveTodo)
+		authRoutes.POST("/todo/:id/unarchive", todoRoute.UnarchiveTodo)
+		authRoutes.GET("/trash", todoRoute.GetTrash)
+		authRoutes.POST("/trash/:id/restore", todoRoute.RestoreTodo)
+		authRoutes.DELETE("/trash/:id", todoRoute.PurgeTodo)
 		authRoutes.GET("/check-token", tokenRoute.CheckToken)
-		authRoutes.POST("/share", userRoute.ShareToUser)
+		authRoutes.POST("/share", invitationRoute.ShareToUser)
+		authRoutes.GET("/invitations", invitationRoute.GetInvitations)
+		authRoutes.POST("/invitations/:id/accept", invitationRoute.AcceptInvitation)
+		authRoutes.POST("/invitations/:id/decline", invitationRoute.DeclineInvitation)
+		authRoutes.GET("/blocked-users", invitationRoute.GetBlockedUsers)
+		authRoutes.POST("/blocked-users", invitationRoute.BlockUser)
+		authRoutes.DELETE("/blocked-users/:username", invitationRoute.UnblockUser)
+		authRoutes.POST("/public-links", publicLinkRoute.CreatePublicLink)
+		authRoutes.GET("/public-links", publicLinkRoute.GetPublicLinks)
+		authRoutes.DELETE("/public-links/:id", publicLinkRoute.RevokePublicLink)
+		authRoutes.GET("/todo/:id/collaborators", userRoute.GetCollaborators)
+		authRoutes.PUT("/todo/:id/collaborators/:username", userRoute.SetCollaboratorRole)
+		authRoutes.DELETE("/todo/:id/collaborators/:username", userRoute.RemoveCollaborator)
+		authRoutes.POST("/todo/:id/leave", userRoute.LeaveTodo)
+		authRoutes.PUT("/todo/:id/assignees/:username", userRoute.AssignTodo)
+		authRoutes.DELETE("/todo/:id/assignees/:username", userRoute.UnassignTodo)
+		authRoutes.POST("/todo/:id/transfer", ownershipRoute.RequestTransfer)
+		authRoutes.POST("/todo/:id/transfer/force", ownershipRoute.ForceTransfer)
+		authRoutes.GET("/todo/:id/transfers", ownershipRoute.GetTransferHistory)
+		authRoutes.GET("/transfers", ownershipRoute.GetTransfers)
+		authRoutes.GET("/todo/:id/comments", commentRoute.GetComments)
+		authRoutes.POST("/todo/:id/comments", commentRoute.CreateComment)
+		authRoutes.PUT("/todo/:id/comments/:commentId", commentRoute.UpdateComment)
+		authRoutes.DELETE("/todo/:id/comments/:commentId", commentRoute.DeleteComment)
+		authRoutes.GET("/todo/:id/comments/:commentId/history", commentRoute.GetCommentHistory)
+		authRoutes.GET("/mentions", commentRoute.GetMentions)
+		authRoutes.GET("/todo/:id/history", activityRoute.GetTodoHistory)
+		authRoutes.GET("/activity", activityRoute.GetActivity)
+		authRoutes.POST("/transfers/:id/accept", ownershipRoute.AcceptTransfer)
+		authRoutes.POST("/transfers/:id/decline", ownershipRoute.DeclineTransfer)
+		authRoutes.PUT("/settings/auto-archive", userRoute.SetAutoArchive)
 		authRoutes.POST("/category/create", catRoute.CreateCategory)
 		authRoutes.GET("/categories", catRoute.GetCategories)
+		authRoutes.GET("/category/:id/members", catRoute.GetCategoryMembers)
+		authRoutes.PUT("/category/:id/members/:username", catRoute.SetCategoryMember)
+		authRoutes.DELETE("/category/:id/members/:username", catRoute.RemoveCategoryMember)
+		authRoutes.GET("/category-invitations", catRoute.GetCategoryInvitations)
+		authRoutes.POST("/category-invitations/:id/accept", catRoute.AcceptCategoryInvitation)
+		authRoutes.POST("/category-invitations/:id/decline", catRoute.DeclineCategoryInvitation)
+		authRoutes.POST("/workspaces", workspaceRoute.CreateWorkspace)
+		authRoutes.GET("/workspaces", workspaceRoute.GetWorkspaces)
+		authRoutes.GET("/workspaces/:id/members", workspaceRoute.GetWorkspaceMembers)
+		authRoutes.PUT("/workspaces/:id/members/:username", workspaceRoute.SetWorkspaceMember)
+		authRoutes.DELETE("/workspaces/:id/members/:username", workspaceRoute.RemoveWorkspaceMember)
+		authRoutes.GET("/workspace-invitations", workspaceRoute.GetWorkspaceInvitations)
+		authRoutes.POST("/workspace-invitations/:id/accept", workspaceRoute.AcceptWorkspaceInvitation)
+		authRoutes.POST("/workspace-invitations/:id/decline", workspaceRoute.DeclineWorkspaceInvitation)
+		authRoutes.POST("/tag/create", tagRoute.CreateTag)
+		authRoutes.GET("/tags", tagRoute.GetTags)
+		authRoutes.PUT("/tag/:id", tagRoute.RenameTag)
+		authRoutes.POST("/tag/:id/merge", tagRoute.MergeTags)
+		authRoutes.DELETE("/tag/:id", tagRoute.DeleteTag)
+		authRoutes.POST("/todo/:id/tags", tagRoute.AttachTag)
+		authRoutes.DELETE("/todo/:id/tags/:tagId", tagRoute.DetachTag)
 	}
 
+	// remove todos from the trash once their retention period is over and archive completed todos
+	go trashPurger.Run(context.Background())
+	go autoArchiver.Run(context.Background())
+
 	r.POST("/login", userRoute.Login)
 	r.POST("/register", userRoute.Register)
+	// public links work without an account
+	r.GET("/public/:token", publicLinkRoute.GetPublicContent)
 
 	// Run the server
 	r.Run(":8080")
 }
+
+func trashRetention() time.Duration {
+	days := defaultTrashRetentionDays
+	if value := os.Getenv("TRASH_RETENTION_DAYS"); value != "" {
+		var err error
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 0 {
+			log.Fatal("TRASH_RETENTION_DAYS must be a number of days")
+		}
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
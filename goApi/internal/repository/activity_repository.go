@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/floxo05/todoapi/internal/types"
+	"time"
+)
+
+const activitySortKey = "activity"
+
+const eventColumns = "e.id, e.todo_id, COALESCE(t.title, e.todo_title), e.actor_id, u.username, e.action, e.subject, e.changes, e.created_at"
+
+type ActivityRepo struct {
+	db       dbtx
+	todoRepo types.TodoRepository
+}
+
+func NewActivityRepo(db *sql.DB, todoRepo types.TodoRepository) *ActivityRepo {
+	return &ActivityRepo{db: db, todoRepo: todoRepo}
+}
+
+func (r *ActivityRepo) RecordEvent(event *types.TodoEvent) error {
+	var changes *string
+	if len(event.Changes) > 0 {
+		data, err := json.Marshal(event.Changes)
+		if err != nil {
+			return err
+		}
+
+		value := string(data)
+		changes = &value
+	}
+
+	// the todo of a purge event is gone, the event keeps its title and workspace instead
+	var todoTitle *string
+	var workspaceID *int
+	if event.TodoID == 0 {
+		todoTitle = &event.TodoTitle
+		workspaceID = event.WorkspaceID
+	}
+
+	event.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	res, err := r.db.Exec(`
+		INSERT INTO todo_events (todo_id, actor_id, action, subject, changes, created_at, todo_title, workspace_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		nullableID(event.TodoID), event.ActorID, event.Action, event.Subject, changes, event.CreatedAt, todoTitle,
+		workspaceID)
+	if err != nil {
+		return err
+	}
+
+	eventID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	event.ID = int(eventID)
+
+	return nil
+}
+
+func (r *ActivityRepo) GetTodoHistory(todoID int, user *types.User) ([]types.TodoEvent, error) {
+	err := r.todoRepo.RequireRole(todoID, user, types.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.queryEvents(`
+		SELECT `+eventColumns+`
+		FROM todo_events e
+			JOIN users u ON u.id = e.actor_id
+			JOIN todos t ON t.id = e.todo_id
+		WHERE e.todo_id = ?
+		ORDER BY e.created_at, e.id`, todoID)
+}
+
+// GetActivityByUser pages through the events by id, so events recorded while a client pages do not shift the pages.
+// Todos that were purged only show up for the user who purged them.
+func (r *ActivityRepo) GetActivityByUser(user *types.User, page types.PageRequest) (*types.Page[types.TodoEvent], error) {
+	workspace, workspaceArgs := workspaceCondition("t.workspace_id", user)
+	purgedWorkspace, purgedWorkspaceArgs := workspaceCondition("e.workspace_id", user)
+	from := `
+		FROM todo_events e
+			JOIN users u ON u.id = e.actor_id
+			LEFT JOIN todos t ON t.id = e.todo_id
+			LEFT JOIN user_todos ut ON ut.todo_id = e.todo_id AND ut.user_id = ?
+		WHERE ((ut.user_id IS NOT NULL AND ` + workspace + `)
+		   OR (e.todo_id IS NULL AND e.actor_id = ? AND ` + purgedWorkspace + `))`
+	fromArgs := append([]interface{}{user.ID}, workspaceArgs...)
+	fromArgs = append(fromArgs, user.ID)
+	fromArgs = append(fromArgs, purgedWorkspaceArgs...)
+
+	query := "SELECT " + eventColumns + from
+	args := append([]interface{}{}, fromArgs...)
+	if page.Cursor != "" {
+		cursor, err := decodeCursor(page.Cursor, activitySortKey)
+		if err != nil {
+			return nil, err
+		}
+
+		query += " AND e.id < ?"
+		args = append(args, cursor.ID)
+	}
+
+	// one more row tells whether there is a next page
+	events, err := r.queryEvents(query+" ORDER BY e.id DESC LIMIT ?", append(args, page.Limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := types.Page[types.TodoEvent]{Items: events}
+	if len(result.Items) > page.Limit {
+		result.Items = result.Items[:page.Limit]
+
+		var next string
+		next, err = encodeCursor(pageCursor{Sort: activitySortKey, ID: result.Items[page.Limit-1].ID})
+		if err != nil {
+			return nil, err
+		}
+		result.NextCursor = &next
+	}
+
+	if page.WithTotal {
+		var total int
+		err = r.db.QueryRow("SELECT COUNT(*)"+from, fromArgs...).Scan(&total)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	return &result, nil
+}
+
+func (r *ActivityRepo) queryEvents(query string, args ...interface{}) ([]types.TodoEvent, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []types.TodoEvent{}
+	for rows.Next() {
+		var event types.TodoEvent
+		var createdAt string
+		var todoID sql.NullInt64
+		var subject, changes sql.NullString
+		err = rows.Scan(&event.ID, &todoID, &event.TodoTitle, &event.ActorID, &event.Actor, &event.Action,
+			&subject, &changes, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		event.TodoID = int(todoID.Int64)
+
+		if subject.Valid {
+			event.Subject = &subject.String
+		}
+
+		if changes.Valid {
+			err = json.Unmarshal([]byte(changes.String), &event.Changes)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		event.CreatedAt, err = time.Parse(dateTimeLayout, createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/floxo05/todoapi/internal/types"
+	"reflect"
+	"testing"
+)
+
+func TestActivityRepo_GetActivityByUser(t *testing.T) {
+	t.Run("should return the newest events and a cursor to the next page", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		columns := []string{"id", "todo_id", "title", "actor_id", "username", "action", "subject", "changes", "created_at"}
+		mock.ExpectQuery("^SELECT e.id, e.todo_id, COALESCE\\(t.title, e.todo_title\\)").WithArgs(1, 1, 2+1).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(7, 5, "Todo", 2, "anna", types.EventCompleted, nil,
+					`{"completed":{"old":false,"new":true}}`, "2022-01-02 00:00:00").
+				AddRow(6, 5, "Todo", 1, "test", types.EventShared, "anna",
+					`{"role":{"old":null,"new":"editor"}}`, "2022-01-01 00:00:00").
+				AddRow(4, 3, "Other", 1, "test", types.EventCreated, nil, nil, "2021-12-31 00:00:00"))
+
+		repo := NewActivityRepo(db, NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex()))
+
+		// Act
+		page, err := repo.GetActivityByUser(&types.User{ID: 1}, types.PageRequest{Limit: 2})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if len(page.Items) != 2 || page.Items[0].ID != 7 || page.Items[1].ID != 6 {
+			t.Errorf("Expected the events 7 and 6, but got %v", page.Items)
+		}
+
+		expected := map[string]types.FieldChange{"completed": {Old: false, New: true}}
+		if !reflect.DeepEqual(page.Items[0].Changes, expected) {
+			t.Errorf("Expected changes %v, but got %v", expected, page.Items[0].Changes)
+		}
+
+		if page.Items[1].Subject == nil || *page.Items[1].Subject != "anna" {
+			t.Errorf("Expected subject anna, but got %v", page.Items[1].Subject)
+		}
+
+		if page.NextCursor == nil {
+			t.Fatalf("Expected a cursor to the next page, but got nil")
+		}
+
+		cursor, err := decodeCursor(*page.NextCursor, activitySortKey)
+		if err != nil || cursor.ID != 6 {
+			t.Errorf("Expected the cursor to point behind event 6, but got %v", cursor)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestActivityRepo_RecordEvent(t *testing.T) {
+	t.Run("should keep the title and workspace of a purged todo", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		workspaceID := 4
+		mock.ExpectExec("^INSERT INTO todo_events").
+			WithArgs(nil, 1, types.EventPurged, nil, nil, sqlmock.AnyArg(), "Old todo", workspaceID).
+			WillReturnResult(sqlmock.NewResult(9, 1))
+
+		repo := NewActivityRepo(db, NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex()))
+
+		// Act
+		err = repo.RecordEvent(&types.TodoEvent{TodoTitle: "Old todo", WorkspaceID: &workspaceID, ActorID: 1,
+			Action: types.EventPurged})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package repository
+
+import "github.com/floxo05/todoapi/internal/types"
+
+// Assignees need at least editor access to their todos. Every change that lowers or removes access calls
+// unassignWithoutAccess for the todos it touched.
+
+// unassignWithoutAccess removes the assignees of the todos who are no editors of them anymore
+func unassignWithoutAccess(db dbtx, todoIds []int) error {
+	if len(todoIds) == 0 {
+		return nil
+	}
+
+	args := rolesFrom(types.RoleEditor)
+	for _, id := range todoIds {
+		args = append(args, id)
+	}
+
+	_, err := db.Exec(`
+		DELETE a FROM todo_assignees a
+		WHERE NOT EXISTS (SELECT 1 FROM user_todos ut
+		                  WHERE ut.todo_id = a.todo_id AND ut.user_id = a.user_id
+		                    AND ut.role IN (`+placeholders(len(rolesFrom(types.RoleEditor)))+`))
+		  AND a.todo_id IN (`+placeholders(len(todoIds))+`)`, args...)
+
+	return err
+}
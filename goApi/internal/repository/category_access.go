@@ -0,0 +1,127 @@
+package repository
+
+import "github.com/floxo05/todoapi/internal/types"
+
+// Members of a shared category get a user_todos row for every todo of the category and its subtasks, marked with
+// via_category_id. The owner of the category manages the todos others put into it. Rows that already exist are
+// kept, so direct access always wins over access through a category.
+
+// collectSubtaskIds returns the ids of all subtasks below the parents, level by level
+func collectSubtaskIds(db dbtx, parentIds []int) ([]int, error) {
+	var ids []int
+	parents := make([]interface{}, 0, len(parentIds))
+	for _, id := range parentIds {
+		parents = append(parents, id)
+	}
+
+	for len(parents) > 0 {
+		rows, err := db.Query("SELECT id FROM todos WHERE parent_id IN ("+placeholders(len(parents))+")", parents...)
+		if err != nil {
+			return nil, err
+		}
+
+		parents = nil
+		for rows.Next() {
+			var id int
+			err = rows.Scan(&id)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			ids = append(ids, id)
+			parents = append(parents, id)
+		}
+		rows.Close()
+	}
+
+	return ids, nil
+}
+
+// categoryTodoIds returns the ids of the todos of the category together with their subtasks
+func categoryTodoIds(db dbtx, categoryID int) ([]int, error) {
+	rows, err := db.Query("SELECT id FROM todos WHERE category_id = ?", categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	subtaskIds, err := collectSubtaskIds(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ids, subtaskIds...), nil
+}
+
+// grantCategoryAccess gives the members and the owner of the category access to the todos
+func grantCategoryAccess(db dbtx, categoryID int, todoIds []int) error {
+	for _, todoID := range todoIds {
+		_, err := db.Exec(`
+			INSERT IGNORE INTO user_todos (user_id, todo_id, role, position, via_category_id)
+			SELECT m.user_id, ?, m.role, (SELECT COALESCE(MAX(p.position), 0) + ? FROM user_todos p WHERE p.user_id = m.user_id), ?
+			FROM (SELECT user_id, role FROM category_members WHERE category_id = ?
+			      UNION ALL
+			      SELECT created_user_id, ? FROM categories WHERE id = ?) m`,
+			todoID, positionGap, categoryID, categoryID, types.RoleManager, categoryID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revokeCategoryAccess removes the access through the category from todos that are not in it anymore
+func revokeCategoryAccess(db dbtx, categoryID int, todoIds []int) error {
+	if len(todoIds) == 0 {
+		return nil
+	}
+
+	args := []interface{}{categoryID, categoryID}
+	for _, id := range todoIds {
+		args = append(args, id)
+	}
+
+	_, err := db.Exec(`
+		DELETE ut FROM user_todos ut
+			JOIN todos t ON t.id = ut.todo_id
+		WHERE ut.via_category_id = ? AND (t.category_id IS NULL OR t.category_id <> ?)
+		  AND ut.todo_id IN (`+placeholders(len(todoIds))+`)`, args...)
+	if err != nil {
+		return err
+	}
+
+	return unassignWithoutAccess(db, todoIds)
+}
+
+// deleteUserTags removes the tags of the user from todos the user can not see anymore
+func deleteUserTags(db dbtx, userID int, todoIds []int) error {
+	if len(todoIds) == 0 {
+		return nil
+	}
+
+	args := []interface{}{userID}
+	for _, id := range todoIds {
+		args = append(args, id)
+	}
+
+	_, err := db.Exec(`
+		DELETE tt FROM todo_tags tt
+			JOIN tags g ON g.id = tt.tag_id
+		WHERE g.created_user_id = ? AND tt.todo_id IN (`+placeholders(len(todoIds))+`)`, args...)
+
+	return err
+}
@@ -2,11 +2,26 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"github.com/floxo05/todoapi/internal/types"
+	"time"
 )
 
+const categorySortKey = "id"
+
+const categoryColumns = "id, title, created_user_id, workspace_id"
+
+// categoriesOfUser selects the categories of the personal space the user created or is a member of
+const categoriesOfUser = `workspace_id IS NULL
+	AND (created_user_id = ? OR id IN (SELECT category_id FROM category_members WHERE user_id = ?))`
+
+// categoriesOfWorkspace selects the categories of the workspace, guests only see the ones they are a member of
+const categoriesOfWorkspace = `workspace_id = ?
+	AND (EXISTS (SELECT 1 FROM workspace_members m WHERE m.workspace_id = categories.workspace_id AND m.user_id = ? AND m.role <> ?)
+	     OR id IN (SELECT category_id FROM category_members WHERE user_id = ?))`
+
 type CategoryRepo struct {
-	db *sql.DB
+	db dbtx
 }
 
 func NewCategoryRepo(db *sql.DB) *CategoryRepo {
@@ -24,7 +39,8 @@ func (c *CategoryRepo) UpsertCategory(category *types.Category) error {
 	if categoryFromDb.ID == 0 || categoryFromDb.Title != category.Title {
 		// if the category does not exist, insert it
 
-		res, err = c.db.Exec("INSERT INTO categories (title, created_user_id) VALUES (?, ?)", category.Title, category.CreatedUserId)
+		res, err = c.db.Exec("INSERT INTO categories (title, created_user_id, workspace_id) VALUES (?, ?, ?)",
+			category.Title, category.CreatedUserId, category.WorkspaceID)
 		if err != nil {
 			return err
 		}
@@ -52,9 +68,15 @@ func (c *CategoryRepo) GetCategoryFromDB(category *types.Category) (*types.Categ
 	var res *sql.Rows
 	var err error
 
-	// if there is an id, get the category by id, otherwise get the category by title and created_user_id
+	// get the category by title and created_user_id, in a workspace the categories belong to the workspace
 
-	res, err = c.db.Query("SELECT id, title, created_user_id FROM categories WHERE title = ? AND created_user_id = ?", category.Title, category.CreatedUserId)
+	if category.WorkspaceID == nil {
+		res, err = c.db.Query("SELECT "+categoryColumns+" FROM categories WHERE title = ? AND created_user_id = ? AND workspace_id IS NULL",
+			category.Title, category.CreatedUserId)
+	} else {
+		res, err = c.db.Query("SELECT "+categoryColumns+" FROM categories WHERE title = ? AND workspace_id = ?",
+			category.Title, *category.WorkspaceID)
+	}
 
 	if err != nil {
 		return nil, err
@@ -63,7 +85,7 @@ func (c *CategoryRepo) GetCategoryFromDB(category *types.Category) (*types.Categ
 
 	var newCategory types.Category
 	for res.Next() {
-		err = res.Scan(&newCategory.ID, &newCategory.Title, &newCategory.CreatedUserId)
+		err = scanCategory(res, &newCategory)
 		if err != nil {
 			return nil, err
 		}
@@ -73,7 +95,7 @@ func (c *CategoryRepo) GetCategoryFromDB(category *types.Category) (*types.Categ
 }
 
 func (c *CategoryRepo) GetCategoryByID(id int) (*types.Category, error) {
-	res, err := c.db.Query("SELECT id, title, created_user_id FROM categories WHERE id = ?", id)
+	res, err := c.db.Query("SELECT "+categoryColumns+" FROM categories WHERE id = ?", id)
 	if err != nil {
 		return nil, err
 	}
@@ -81,7 +103,7 @@ func (c *CategoryRepo) GetCategoryByID(id int) (*types.Category, error) {
 
 	var category types.Category
 	for res.Next() {
-		err = res.Scan(&category.ID, &category.Title, &category.CreatedUserId)
+		err = scanCategory(res, &category)
 		if err != nil {
 			return nil, err
 		}
@@ -90,8 +112,10 @@ func (c *CategoryRepo) GetCategoryByID(id int) (*types.Category, error) {
 	return &category, nil
 }
 
-func (c *CategoryRepo) GetCategoriesByUserId(userID int) ([]types.Category, error) {
-	res, err := c.db.Query("SELECT id, title, created_user_id FROM categories WHERE created_user_id = ?", userID)
+// GetCategoriesByUserId returns the categories of the user including the categories shared with them
+func (c *CategoryRepo) GetCategoriesByUserId(userID int, workspaceID *int) ([]types.Category, error) {
+	conditions, args := categoriesCondition(userID, workspaceID)
+	res, err := c.db.Query("SELECT "+categoryColumns+" FROM categories WHERE "+conditions, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -100,7 +124,7 @@ func (c *CategoryRepo) GetCategoriesByUserId(userID int) ([]types.Category, erro
 	var categories []types.Category
 	for res.Next() {
 		var category types.Category
-		err = res.Scan(&category.ID, &category.Title, &category.CreatedUserId)
+		err = scanCategory(res, &category)
 		if err != nil {
 			return nil, err
 		}
@@ -109,3 +133,486 @@ func (c *CategoryRepo) GetCategoriesByUserId(userID int) ([]types.Category, erro
 
 	return categories, nil
 }
+
+// GetCategoriesPageByUserId returns one page of the categories of the user ordered by id
+func (c *CategoryRepo) GetCategoriesPageByUserId(userID int, workspaceID *int, page types.PageRequest) (*types.Page[types.Category], error) {
+	conditions, conditionArgs := categoriesCondition(userID, workspaceID)
+	query := "SELECT " + categoryColumns + " FROM categories WHERE " + conditions
+	args := append([]interface{}{}, conditionArgs...)
+	if page.Cursor != "" {
+		cursor, err := decodeCursor(page.Cursor, categorySortKey)
+		if err != nil {
+			return nil, err
+		}
+
+		query += " AND id > ?"
+		args = append(args, cursor.ID)
+	}
+
+	// one more row tells whether there is a next page
+	res, err := c.db.Query(query+" ORDER BY id LIMIT ?", append(args, page.Limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	result := types.Page[types.Category]{Items: []types.Category{}}
+	for res.Next() {
+		var category types.Category
+		err = scanCategory(res, &category)
+		if err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, category)
+	}
+
+	if len(result.Items) > page.Limit {
+		result.Items = result.Items[:page.Limit]
+
+		var next string
+		next, err = encodeCursor(pageCursor{Sort: categorySortKey, ID: result.Items[page.Limit-1].ID})
+		if err != nil {
+			return nil, err
+		}
+		result.NextCursor = &next
+	}
+
+	if page.WithTotal {
+		var total int
+		err = c.db.QueryRow("SELECT COUNT(*) FROM categories WHERE "+conditions, conditionArgs...).Scan(&total)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	return &result, nil
+}
+
+// CategoryRole also takes the role into account that the user has on the todos of the workspace of the category
+func (c *CategoryRepo) CategoryRole(categoryID int, user *types.User) (string, error) {
+	var createdUserID int
+	var workspaceID sql.NullInt64
+	err := c.db.QueryRow("SELECT created_user_id, workspace_id FROM categories WHERE id = ?", categoryID).
+		Scan(&createdUserID, &workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if createdUserID == user.ID {
+		return types.RoleOwner, nil
+	}
+
+	var role string
+	err = c.db.QueryRow("SELECT role FROM category_members WHERE category_id = ? AND user_id = ?", categoryID, user.ID).
+		Scan(&role)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	if !workspaceID.Valid {
+		return role, nil
+	}
+
+	var workspaceRole string
+	err = c.db.QueryRow("SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceID.Int64, user.ID).
+		Scan(&workspaceRole)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	if todoRole := workspaceTodoRole(workspaceRole); roleRank(todoRole) > roleRank(role) {
+		role = todoRole
+	}
+
+	return role, nil
+}
+
+// GetCategoryMembers returns the owner and the members of the category, every member can see the others
+func (c *CategoryRepo) GetCategoryMembers(categoryID int, user *types.User) ([]types.Collaborator, error) {
+	role, err := c.CategoryRole(categoryID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == "" {
+		return nil, errors.New("category not found")
+	}
+
+	rows, err := c.db.Query(`
+		SELECT username, role
+		FROM (SELECT u.username, ? AS role
+		      FROM categories g
+		          JOIN users u ON u.id = g.created_user_id
+		      WHERE g.id = ?
+		      UNION ALL
+		      SELECT u.username, m.role
+		      FROM category_members m
+		          JOIN users u ON u.id = m.user_id
+		      WHERE m.category_id = ?) members
+		ORDER BY role = ? DESC, username`, types.RoleOwner, categoryID, categoryID, types.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []types.Collaborator{}
+	for rows.Next() {
+		var member types.Collaborator
+		err = rows.Scan(&member.Username, &member.Role)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// SetCategoryMember changes the role of a member, only the owner can do this. The member gets the role on all todos
+// they can access through the category. Other users have to be invited with InviteCategoryMember.
+func (c *CategoryRepo) SetCategoryMember(categoryID int, user *types.User, member *types.User, role string) error {
+	err := c.requireCategoryOwner(categoryID, user)
+	if err != nil {
+		return err
+	}
+
+	if role == types.RoleOwner || roleRank(role) < 0 {
+		return errors.New("role can not be granted")
+	}
+
+	var count int
+	err = c.db.QueryRow("SELECT COUNT(*) FROM category_members WHERE category_id = ? AND user_id = ?", categoryID, member.ID).
+		Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return types.ErrNotCategoryMember
+	}
+
+	_, err = c.db.Exec("UPDATE category_members SET role = ? WHERE category_id = ? AND user_id = ?", role, categoryID, member.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.db.Exec("UPDATE user_todos SET role = ? WHERE user_id = ? AND via_category_id = ?", role, member.ID, categoryID)
+	if err != nil {
+		return err
+	}
+
+	todoIds, err := categoryTodoIds(c.db, categoryID)
+	if err != nil {
+		return err
+	}
+
+	return unassignWithoutAccess(c.db, todoIds)
+}
+
+// InviteCategoryMember invites another user to the category with the role, only the owner can do this. Inviting the
+// user again replaces the pending invitation and starts its validity anew.
+func (c *CategoryRepo) InviteCategoryMember(categoryID int, user *types.User, invitee *types.User, role string) error {
+	err := c.requireCategoryOwner(categoryID, user)
+	if err != nil {
+		return err
+	}
+
+	if role == types.RoleOwner || roleRank(role) < 0 {
+		return errors.New("role can not be granted")
+	}
+
+	if invitee.ID == user.ID {
+		return errors.New("the owner is already a member of the category")
+	}
+
+	err = c.requireInvitable(categoryID, invitee)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = c.db.QueryRow("SELECT COUNT(*) FROM user_blocks WHERE user_id = ? AND blocked_user_id = ?", invitee.ID, user.ID).
+		Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errors.New("user does not accept invitations from you")
+	}
+
+	err = c.db.QueryRow("SELECT COUNT(*) FROM category_members WHERE category_id = ? AND user_id = ?", categoryID, invitee.ID).
+		Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errors.New("user is already a member of the category")
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err = c.db.Exec(`
+		INSERT INTO category_invitations (category_id, inviter_id, invitee_id, role, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE inviter_id = VALUES(inviter_id), role = VALUES(role),
+			created_at = VALUES(created_at), expires_at = VALUES(expires_at)`,
+		categoryID, user.ID, invitee.ID, role, now, now.Add(invitationValidity))
+
+	return err
+}
+
+// GetCategoryInvitationsByUser returns the pending invitations of the user to categories that did not expire yet,
+// newest first
+func (c *CategoryRepo) GetCategoryInvitationsByUser(user *types.User) ([]types.CategoryInvitation, error) {
+	rows, err := c.db.Query(`
+		SELECT i.id, i.category_id, g.title, u.username, i.role, i.created_at, i.expires_at
+		FROM category_invitations i
+			JOIN categories g ON g.id = i.category_id
+			JOIN users u ON u.id = i.inviter_id
+		WHERE i.invitee_id = ? AND i.expires_at > ?
+		ORDER BY i.created_at DESC, i.id DESC`, user.ID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []types.CategoryInvitation{}
+	for rows.Next() {
+		var invitation types.CategoryInvitation
+		var createdAt, expiresAt string
+		err = rows.Scan(&invitation.ID, &invitation.CategoryID, &invitation.CategoryTitle, &invitation.InvitedBy,
+			&invitation.Role, &createdAt, &expiresAt)
+		if err != nil {
+			return nil, err
+		}
+
+		invitation.CreatedAt, err = time.Parse(dateTimeLayout, createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		invitation.ExpiresAt, err = time.Parse(dateTimeLayout, expiresAt)
+		if err != nil {
+			return nil, err
+		}
+
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+// AcceptCategoryInvitation makes the user a member of the category with access to all of its todos. The inviter
+// has to be the owner of the category still. The invitation is deleted last, so a failed accept can be repeated.
+func (c *CategoryRepo) AcceptCategoryInvitation(invitationID int, user *types.User) error {
+	var categoryID, inviterID int
+	var role, expiresAt string
+	err := c.db.QueryRow(`
+		SELECT category_id, inviter_id, role, expires_at
+		FROM category_invitations
+		WHERE id = ? AND invitee_id = ?`, invitationID, user.ID).Scan(&categoryID, &inviterID, &role, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("invitation not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	expires, err := time.Parse(dateTimeLayout, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	if !expires.After(time.Now().UTC()) {
+		return errors.New("invitation has expired")
+	}
+
+	err = c.requireCategoryOwner(categoryID, &types.User{ID: inviterID})
+	if err != nil {
+		return err
+	}
+
+	err = c.requireInvitable(categoryID, user)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.db.Exec(`
+		INSERT INTO category_members (category_id, user_id, role) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE role = VALUES(role)`, categoryID, user.ID, role)
+	if err != nil {
+		return err
+	}
+
+	todoIds, err := categoryTodoIds(c.db, categoryID)
+	if err != nil {
+		return err
+	}
+
+	err = grantCategoryAccess(c.db, categoryID, todoIds)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.db.Exec("DELETE FROM category_invitations WHERE id = ?", invitationID)
+
+	return err
+}
+
+func (c *CategoryRepo) DeclineCategoryInvitation(invitationID int, user *types.User) error {
+	result, err := c.db.Exec("DELETE FROM category_invitations WHERE id = ? AND invitee_id = ?", invitationID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return errors.New("invitation not found")
+	}
+
+	return nil
+}
+
+func (c *CategoryRepo) GetCategoryTodoIds(categoryID int) ([]int, error) {
+	rows, err := c.db.Query("SELECT id FROM todos WHERE category_id = ? AND deleted_at IS NULL ORDER BY id", categoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// RemoveCategoryMember takes the category and the access to its todos away from a member, only the owner can do this
+func (c *CategoryRepo) RemoveCategoryMember(categoryID int, user *types.User, member *types.User) error {
+	err := c.requireCategoryOwner(categoryID, user)
+	if err != nil {
+		return err
+	}
+
+	result, err := c.db.Exec("DELETE FROM category_members WHERE category_id = ? AND user_id = ?", categoryID, member.ID)
+	if err != nil {
+		return err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if removed == 0 {
+		return errors.New("user is not a member of the category")
+	}
+
+	rows, err := c.db.Query("SELECT todo_id FROM user_todos WHERE user_id = ? AND via_category_id = ?", member.ID, categoryID)
+	if err != nil {
+		return err
+	}
+
+	var todoIds []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		todoIds = append(todoIds, id)
+	}
+	rows.Close()
+
+	_, err = c.db.Exec("DELETE FROM user_todos WHERE user_id = ? AND via_category_id = ?", member.ID, categoryID)
+	if err != nil {
+		return err
+	}
+
+	err = deleteUserTags(c.db, member.ID, todoIds)
+	if err != nil {
+		return err
+	}
+
+	return unassignWithoutAccess(c.db, todoIds)
+}
+
+// requireInvitable fails if the category belongs to a workspace the user is no member of
+func (c *CategoryRepo) requireInvitable(categoryID int, user *types.User) error {
+	var count int
+	err := c.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM categories g
+		WHERE g.id = ? AND g.workspace_id IS NOT NULL
+		  AND NOT EXISTS (SELECT 1 FROM workspace_members m WHERE m.workspace_id = g.workspace_id AND m.user_id = ?)`,
+		categoryID, user.ID).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errors.New("user is not a member of the workspace")
+	}
+
+	return nil
+}
+
+func (c *CategoryRepo) requireCategoryOwner(categoryID int, user *types.User) error {
+	role, err := c.CategoryRole(categoryID, user)
+	if err != nil {
+		return err
+	}
+
+	if role == "" {
+		return errors.New("category not found")
+	}
+
+	if role != types.RoleOwner {
+		return errors.New("only the owner can manage the members of the category")
+	}
+
+	return nil
+}
+
+// categoriesCondition selects the categories of the user in the workspace, nil for the personal space
+func categoriesCondition(userID int, workspaceID *int) (string, []interface{}) {
+	if workspaceID == nil {
+		return categoriesOfUser, []interface{}{userID, userID}
+	}
+
+	return categoriesOfWorkspace, []interface{}{*workspaceID, userID, types.WorkspaceGuest, userID}
+}
+
+func scanCategory(row rowScanner, category *types.Category) error {
+	var workspaceID sql.NullInt64
+	err := row.Scan(&category.ID, &category.Title, &category.CreatedUserId, &workspaceID)
+	if err != nil {
+		return err
+	}
+
+	if workspaceID.Valid {
+		id := int(workspaceID.Int64)
+		category.WorkspaceID = &id
+	}
+
+	return nil
+}
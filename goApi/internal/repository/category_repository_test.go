@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/floxo05/todoapi/internal/types"
+	"testing"
+	"time"
+)
+
+func TestCategoryRepo_SetCategoryMember(t *testing.T) {
+	t.Run("should not add users who were not invited", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT created_user_id, workspace_id FROM categories").WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"created_user_id", "workspace_id"}).AddRow(1, nil))
+		mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM category_members").WithArgs(3, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		repo := NewCategoryRepo(db)
+
+		// Act
+		err = repo.SetCategoryMember(3, &types.User{ID: 1}, &types.User{ID: 2}, types.RoleManager)
+
+		// Assert
+		if !errors.Is(err, types.ErrNotCategoryMember) {
+			t.Errorf("Expected error %v, but got %v", types.ErrNotCategoryMember, err)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestCategoryRepo_AcceptCategoryInvitation(t *testing.T) {
+	t.Run("should reject invitations of users who do not own the category anymore", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		expiresAt := time.Now().UTC().Add(time.Hour).Format(dateTimeLayout)
+		mock.ExpectQuery("^SELECT category_id, inviter_id, role, expires_at FROM category_invitations").WithArgs(4, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"category_id", "inviter_id", "role", "expires_at"}).
+				AddRow(3, 1, types.RoleEditor, expiresAt))
+		mock.ExpectQuery("^SELECT created_user_id, workspace_id FROM categories").WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"created_user_id", "workspace_id"}).AddRow(5, nil))
+		mock.ExpectQuery("^SELECT role FROM category_members").WithArgs(3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}))
+
+		repo := NewCategoryRepo(db)
+
+		// Act
+		err = repo.AcceptCategoryInvitation(4, &types.User{ID: 2})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/floxo05/todoapi/internal/types"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const commentColumns = `c.id, c.todo_id, u.username, c.body, c.created_at, c.updated_at,
+	(SELECT JSON_ARRAYAGG(mu.username ORDER BY mu.username)
+	 FROM comment_mentions m JOIN users mu ON mu.id = m.user_id WHERE m.comment_id = c.id) AS mentions`
+
+// mentionPattern finds the @username mentions in a comment, a mention ends at whitespace
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@(\S+)`)
+
+type CommentRepo struct {
+	db       dbtx
+	todoRepo types.TodoRepository
+	userRepo types.UserRepository
+}
+
+func NewCommentRepo(db *sql.DB, todoRepo types.TodoRepository, userRepo types.UserRepository) *CommentRepo {
+	return &CommentRepo{db: db, todoRepo: todoRepo, userRepo: userRepo}
+}
+
+func (r *CommentRepo) CreateComment(comment *types.Comment, user *types.User) error {
+	err := r.todoRepo.RequireRole(comment.TodoID, user, types.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	err = r.requireNotDeleted(comment.TodoID)
+	if err != nil {
+		return err
+	}
+
+	comment.Author = user.Username
+	comment.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	comment.UpdatedAt = nil
+
+	res, err := r.db.Exec("INSERT INTO comments (todo_id, user_id, body, created_at) VALUES (?, ?, ?, ?)",
+		comment.TodoID, user.ID, comment.Body, comment.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	commentID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	comment.ID = int(commentID)
+
+	return r.saveMentions(comment)
+}
+
+// GetCommentsByTodo returns the comments of the todo, oldest first
+func (r *CommentRepo) GetCommentsByTodo(todoID int, user *types.User) ([]types.Comment, error) {
+	err := r.todoRepo.RequireRole(todoID, user, types.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.queryComments(`
+		SELECT `+commentColumns+`
+		FROM comments c
+			JOIN users u ON u.id = c.user_id
+		WHERE c.todo_id = ?
+		ORDER BY c.created_at, c.id`, todoID)
+}
+
+func (r *CommentRepo) UpdateComment(comment *types.Comment, user *types.User) error {
+	err := r.todoRepo.RequireRole(comment.TodoID, user, types.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	err = r.requireNotDeleted(comment.TodoID)
+	if err != nil {
+		return err
+	}
+
+	var authorID int
+	var body, createdAt string
+	var updatedAt sql.NullString
+	err = r.db.QueryRow("SELECT user_id, body, created_at, updated_at FROM comments WHERE id = ? AND todo_id = ?",
+		comment.ID, comment.TodoID).Scan(&authorID, &body, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("comment not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	if authorID != user.ID {
+		return errors.New("only the author can edit the comment")
+	}
+
+	comment.Author = user.Username
+	comment.CreatedAt, err = time.Parse(dateTimeLayout, createdAt)
+	if err != nil {
+		return err
+	}
+
+	comment.UpdatedAt, err = parseNullDateTime(updatedAt)
+	if err != nil {
+		return err
+	}
+
+	if comment.Body == body {
+		return r.saveMentions(comment)
+	}
+
+	// the previous body was written when the comment was created or last edited
+	written := comment.CreatedAt
+	if comment.UpdatedAt != nil {
+		written = *comment.UpdatedAt
+	}
+
+	_, err = r.db.Exec("INSERT INTO comment_revisions (comment_id, body, created_at) VALUES (?, ?, ?)",
+		comment.ID, body, written)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err = r.db.Exec("UPDATE comments SET body = ?, updated_at = ? WHERE id = ?", comment.Body, now, comment.ID)
+	if err != nil {
+		return err
+	}
+
+	comment.UpdatedAt = &now
+
+	return r.saveMentions(comment)
+}
+
+func (r *CommentRepo) DeleteComment(commentID int, todoID int, user *types.User) error {
+	var authorID int
+	err := r.db.QueryRow("SELECT user_id FROM comments WHERE id = ? AND todo_id = ?", commentID, todoID).Scan(&authorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("comment not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	required := types.RoleViewer
+	if authorID != user.ID {
+		required = types.RoleManager
+	}
+
+	err = r.todoRepo.RequireRole(todoID, user, required)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec("DELETE FROM comments WHERE id = ?", commentID)
+
+	return err
+}
+
+// GetCommentHistory returns the previous bodies of the comment, oldest first
+func (r *CommentRepo) GetCommentHistory(commentID int, todoID int, user *types.User) ([]types.CommentRevision, error) {
+	err := r.todoRepo.RequireRole(todoID, user, types.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT r.body, r.created_at
+		FROM comment_revisions r
+			JOIN comments c ON c.id = r.comment_id
+		WHERE r.comment_id = ? AND c.todo_id = ?
+		ORDER BY r.created_at, r.id`, commentID, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []types.CommentRevision{}
+	for rows.Next() {
+		var revision types.CommentRevision
+		var createdAt string
+		err = rows.Scan(&revision.Body, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		revision.CreatedAt, err = time.Parse(dateTimeLayout, createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+// GetMentionsByUser only returns comments on todos of the active workspace the user still has access to
+func (r *CommentRepo) GetMentionsByUser(user *types.User) ([]types.Comment, error) {
+	workspace, workspaceArgs := workspaceCondition("t.workspace_id", user)
+
+	return r.queryComments(`
+		SELECT `+commentColumns+`
+		FROM comments c
+			JOIN users u ON u.id = c.user_id
+			JOIN comment_mentions cm ON cm.comment_id = c.id
+			JOIN user_todos ut ON ut.todo_id = c.todo_id AND ut.user_id = cm.user_id
+			JOIN todos t ON t.id = c.todo_id
+		WHERE cm.user_id = ? AND t.deleted_at IS NULL AND `+workspace+`
+		ORDER BY c.created_at DESC, c.id DESC`, append([]interface{}{user.ID}, workspaceArgs...)...)
+}
+
+// saveMentions stores the users mentioned in the comment. Unknown usernames and users without access to the todo
+// are no mentions.
+// requireNotDeleted fails for todos in the trash, they can not be discussed until they are restored
+func (r *CommentRepo) requireNotDeleted(todoID int) error {
+	var deleted int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM todos WHERE id = ? AND deleted_at IS NOT NULL", todoID).Scan(&deleted)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		return errors.New("todo is in the trash")
+	}
+
+	return nil
+}
+
+func (r *CommentRepo) saveMentions(comment *types.Comment) error {
+	_, err := r.db.Exec("DELETE FROM comment_mentions WHERE comment_id = ?", comment.ID)
+	if err != nil {
+		return err
+	}
+
+	comment.Mentions = []string{}
+	for _, username := range mentionedUsernames(comment.Body) {
+		var mentioned *types.User
+		mentioned, err = r.userRepo.GetUserByUsername(username)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var count int
+		err = r.db.QueryRow("SELECT COUNT(*) FROM user_todos WHERE todo_id = ? AND user_id = ?", comment.TodoID, mentioned.ID).
+			Scan(&count)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			continue
+		}
+
+		_, err = r.db.Exec("INSERT IGNORE INTO comment_mentions (comment_id, user_id) VALUES (?, ?)", comment.ID, mentioned.ID)
+		if err != nil {
+			return err
+		}
+
+		comment.Mentions = append(comment.Mentions, mentioned.Username)
+	}
+
+	return nil
+}
+
+func (r *CommentRepo) queryComments(query string, args ...interface{}) ([]types.Comment, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []types.Comment{}
+	for rows.Next() {
+		var comment types.Comment
+		var createdAt string
+		var updatedAt, mentions sql.NullString
+		err = rows.Scan(&comment.ID, &comment.TodoID, &comment.Author, &comment.Body, &createdAt, &updatedAt, &mentions)
+		if err != nil {
+			return nil, err
+		}
+
+		comment.CreatedAt, err = time.Parse(dateTimeLayout, createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		comment.UpdatedAt, err = parseNullDateTime(updatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		comment.Mentions = []string{}
+		if mentions.Valid {
+			err = json.Unmarshal([]byte(mentions.String), &comment.Mentions)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// mentionedUsernames returns the usernames mentioned in the text once each, punctuation after a mention is ignored
+func mentionedUsernames(text string) []string {
+	var usernames []string
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		username := strings.TrimRight(match[1], ".,:;!?)")
+		if username == "" || seen[username] {
+			continue
+		}
+
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+
+	return usernames
+}
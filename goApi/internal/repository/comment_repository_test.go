@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/floxo05/todoapi/internal/types"
+	"reflect"
+	"testing"
+)
+
+func TestCommentRepo_CreateComment(t *testing.T) {
+	t.Run("should only mention known users with access to the todo", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		userColumns := []string{"id", "username", "password", "auto_archive_days"}
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(1, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleViewer))
+		mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM todos").WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec("^INSERT INTO comments").WillReturnResult(sqlmock.NewResult(9, 1))
+		mock.ExpectExec("^DELETE FROM comment_mentions").WithArgs(9).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("^SELECT id, username, password, auto_archive_days FROM users").WithArgs("anna").
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(2, "anna", "hash", nil))
+		mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM user_todos").WithArgs(5, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectExec("^INSERT IGNORE INTO comment_mentions").WithArgs(9, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("^SELECT id, username, password, auto_archive_days FROM users").WithArgs("ben").
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(3, "ben", "hash", nil))
+		mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM user_todos").WithArgs(5, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("^SELECT id, username, password, auto_archive_days FROM users").WithArgs("nobody").
+			WillReturnError(sql.ErrNoRows)
+
+		todoRepo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+		repo := NewCommentRepo(db, todoRepo, NewUserRepo(db, todoRepo))
+		comment := types.Comment{TodoID: 5, Body: "@anna could you ask @ben and @nobody?"}
+
+		// Act
+		err = repo.CreateComment(&comment, &types.User{ID: 1, Username: "test"})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if !reflect.DeepEqual(comment.Mentions, []string{"anna"}) {
+			t.Errorf("Expected mentions [anna], but got %v", comment.Mentions)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should not add comments to todos in the trash", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(1, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleOwner))
+		mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM todos").WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		todoRepo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+		repo := NewCommentRepo(db, todoRepo, NewUserRepo(db, todoRepo))
+
+		// Act
+		err = repo.CreateComment(&types.Comment{TodoID: 5, Body: "still there?"}, &types.User{ID: 1})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestCommentRepo_UpdateComment(t *testing.T) {
+	t.Run("should only let the author edit the comment", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(1, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleOwner))
+		mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM todos").WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery("^SELECT user_id, body, created_at, updated_at FROM comments").WithArgs(9, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "body", "created_at", "updated_at"}).
+				AddRow(2, "first", "2022-01-01 00:00:00", nil))
+
+		todoRepo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+		repo := NewCommentRepo(db, todoRepo, NewUserRepo(db, todoRepo))
+
+		// Act
+		err = repo.UpdateComment(&types.Comment{ID: 9, TodoID: 5, Body: "second"}, &types.User{ID: 1})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestCommentRepo_mentionedUsernames(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "should find mentions", text: "@anna and @ben", want: []string{"anna", "ben"}},
+		{name: "should ignore trailing punctuation", text: "thanks @anna!", want: []string{"anna"}},
+		{name: "should mention users once", text: "@anna @anna", want: []string{"anna"}},
+		{name: "should ignore mail addresses", text: "write to anna@example.com", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := mentionedUsernames(tt.text)
+
+			// Assert
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
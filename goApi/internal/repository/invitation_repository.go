@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/floxo05/todoapi/internal/types"
+	"time"
+)
+
+// invitations that are not accepted within this time expire
+const invitationValidity = 14 * 24 * time.Hour
+
+type InvitationRepo struct {
+	db       dbtx
+	todoRepo types.TodoRepository
+	userRepo types.UserRepository
+}
+
+func NewInvitationRepo(db *sql.DB, todoRepo types.TodoRepository, userRepo types.UserRepository) *InvitationRepo {
+	return &InvitationRepo{db: db, todoRepo: todoRepo, userRepo: userRepo}
+}
+
+// CreateInvitation invites another user to the todo with the role. Inviting the user again replaces the pending
+// invitation and starts its validity anew.
+func (r *InvitationRepo) CreateInvitation(todoID int, user *types.User, invitee *types.User, role string) error {
+	err := requireGrant(r.todoRepo, todoID, user, role)
+	if err != nil {
+		return err
+	}
+
+	if invitee.ID == user.ID {
+		return errors.New("user can not invite themselves")
+	}
+
+	var count int
+	err = r.db.QueryRow("SELECT COUNT(*) FROM user_blocks WHERE user_id = ? AND blocked_user_id = ?", invitee.ID, user.ID).
+		Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errors.New("user does not accept invitations from you")
+	}
+
+	// todos of a workspace can only be shared within the workspace
+	err = r.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM todos t
+		WHERE t.id = ? AND t.workspace_id IS NOT NULL
+		  AND NOT EXISTS (SELECT 1 FROM workspace_members m WHERE m.workspace_id = t.workspace_id AND m.user_id = ?)`,
+		todoID, invitee.ID).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errors.New("user is not a member of the workspace")
+	}
+
+	err = r.db.QueryRow("SELECT COUNT(*) FROM user_todos WHERE todo_id = ? AND user_id = ?", todoID, invitee.ID).
+		Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errors.New("user already has access to the todo")
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err = r.db.Exec(`
+		INSERT INTO invitations (todo_id, inviter_id, invitee_id, role, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE inviter_id = VALUES(inviter_id), role = VALUES(role),
+			created_at = VALUES(created_at), expires_at = VALUES(expires_at)`,
+		todoID, user.ID, invitee.ID, role, now, now.Add(invitationValidity))
+
+	return err
+}
+
+// GetInvitationsByUser returns the pending invitations of the user that did not expire yet, newest first
+func (r *InvitationRepo) GetInvitationsByUser(user *types.User) ([]types.Invitation, error) {
+	rows, err := r.db.Query(`
+		SELECT i.id, i.todo_id, t.title, u.username, i.role, i.created_at, i.expires_at
+		FROM invitations i
+			JOIN todos t ON t.id = i.todo_id
+			JOIN users u ON u.id = i.inviter_id
+		WHERE i.invitee_id = ? AND i.expires_at > ? AND t.deleted_at IS NULL
+		ORDER BY i.created_at DESC, i.id DESC`, user.ID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []types.Invitation{}
+	for rows.Next() {
+		var invitation types.Invitation
+		var createdAt, expiresAt string
+		err = rows.Scan(&invitation.ID, &invitation.TodoID, &invitation.TodoTitle, &invitation.InvitedBy,
+			&invitation.Role, &createdAt, &expiresAt)
+		if err != nil {
+			return nil, err
+		}
+
+		invitation.CreatedAt, err = time.Parse(dateTimeLayout, createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		invitation.ExpiresAt, err = time.Parse(dateTimeLayout, expiresAt)
+		if err != nil {
+			return nil, err
+		}
+
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+// AcceptInvitation shares the todo with the user. The rights of the inviter are checked again, they may have
+// changed since the invitation was sent.
+func (r *InvitationRepo) AcceptInvitation(invitationID int, user *types.User) error {
+	var todoID, inviterID int
+	var role, expiresAt string
+	var deletedAt sql.NullString
+	var workspaceID sql.NullInt64
+	err := r.db.QueryRow(`
+		SELECT i.todo_id, i.inviter_id, i.role, i.expires_at, t.deleted_at, t.workspace_id
+		FROM invitations i
+			JOIN todos t ON t.id = i.todo_id
+		WHERE i.id = ? AND i.invitee_id = ?`,
+		invitationID, user.ID).Scan(&todoID, &inviterID, &role, &expiresAt, &deletedAt, &workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("invitation not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	expires, err := time.Parse(dateTimeLayout, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	if !expires.After(time.Now().UTC()) {
+		return errors.New("invitation has expired")
+	}
+
+	// the invitation stays pending, it can be accepted once the todo is restored
+	if deletedAt.Valid {
+		return errors.New("todo is in the trash")
+	}
+
+	_, err = r.db.Exec("DELETE FROM invitations WHERE id = ?", invitationID)
+	if err != nil {
+		return err
+	}
+
+	// the rights of the inviter are checked in the workspace of the todo
+	inviter := &types.User{ID: inviterID}
+	if workspaceID.Valid {
+		id := int(workspaceID.Int64)
+		inviter.WorkspaceID = &id
+	}
+
+	return r.userRepo.ShareTodoWithUser(todoID, inviter, user, role)
+}
+
+func (r *InvitationRepo) DeclineInvitation(invitationID int, user *types.User) error {
+	result, err := r.db.Exec("DELETE FROM invitations WHERE id = ? AND invitee_id = ?", invitationID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return errors.New("invitation not found")
+	}
+
+	return nil
+}
+
+// BlockUser stops the invitations of another user to the user, their pending invitations are declined
+func (r *InvitationRepo) BlockUser(user *types.User, blockedUser *types.User) error {
+	if blockedUser.ID == user.ID {
+		return errors.New("user can not block themselves")
+	}
+
+	_, err := r.db.Exec("INSERT IGNORE INTO user_blocks (user_id, blocked_user_id) VALUES (?, ?)", user.ID, blockedUser.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec("DELETE FROM invitations WHERE invitee_id = ? AND inviter_id = ?", user.ID, blockedUser.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec("DELETE FROM category_invitations WHERE invitee_id = ? AND inviter_id = ?", user.ID, blockedUser.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec("DELETE FROM workspace_invitations WHERE invitee_id = ? AND inviter_id = ?", user.ID, blockedUser.ID)
+
+	return err
+}
+
+func (r *InvitationRepo) UnblockUser(user *types.User, blockedUser *types.User) error {
+	_, err := r.db.Exec("DELETE FROM user_blocks WHERE user_id = ? AND blocked_user_id = ?", user.ID, blockedUser.ID)
+
+	return err
+}
+
+// GetBlockedUsers returns the usernames of the users the user blocked
+func (r *InvitationRepo) GetBlockedUsers(user *types.User) ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT u.username
+		FROM user_blocks b
+			JOIN users u ON u.id = b.blocked_user_id
+		WHERE b.user_id = ?
+		ORDER BY u.username`, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usernames := []string{}
+	for rows.Next() {
+		var username string
+		err = rows.Scan(&username)
+		if err != nil {
+			return nil, err
+		}
+
+		usernames = append(usernames, username)
+	}
+
+	return usernames, rows.Err()
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/floxo05/todoapi/internal/types"
+	"testing"
+	"time"
+)
+
+func TestInvitationRepo_CreateInvitation(t *testing.T) {
+	t.Run("should not invite users who blocked the inviter", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(1, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleOwner))
+		mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM user_blocks").WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		todoRepo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+		repo := NewInvitationRepo(db, todoRepo, NewUserRepo(db, todoRepo))
+
+		// Act
+		err = repo.CreateInvitation(5, &types.User{ID: 1}, &types.User{ID: 2}, types.RoleEditor)
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should only let the owner invite managers", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(1, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleManager))
+
+		todoRepo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+		repo := NewInvitationRepo(db, todoRepo, NewUserRepo(db, todoRepo))
+
+		// Act
+		err = repo.CreateInvitation(5, &types.User{ID: 1}, &types.User{ID: 2}, types.RoleManager)
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+}
+
+func TestInvitationRepo_AcceptInvitation(t *testing.T) {
+	t.Run("should reject expired invitations", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		expiresAt := time.Now().UTC().Add(-time.Hour).Format(dateTimeLayout)
+		mock.ExpectQuery("^SELECT i.todo_id, i.inviter_id, i.role, i.expires_at, t.deleted_at, t.workspace_id FROM invitations").WithArgs(3, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"todo_id", "inviter_id", "role", "expires_at", "deleted_at", "workspace_id"}).
+				AddRow(5, 1, types.RoleEditor, expiresAt, nil, nil))
+
+		todoRepo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+		repo := NewInvitationRepo(db, todoRepo, NewUserRepo(db, todoRepo))
+
+		// Act
+		err = repo.AcceptInvitation(3, &types.User{ID: 2})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("should reject invitations to todos in the trash", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		expiresAt := time.Now().UTC().Add(time.Hour).Format(dateTimeLayout)
+		mock.ExpectQuery("^SELECT i.todo_id, i.inviter_id, i.role, i.expires_at, t.deleted_at, t.workspace_id FROM invitations").WithArgs(3, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"todo_id", "inviter_id", "role", "expires_at", "deleted_at", "workspace_id"}).
+				AddRow(5, 1, types.RoleEditor, expiresAt, "2024-01-01 00:00:00", nil))
+
+		todoRepo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+		repo := NewInvitationRepo(db, todoRepo, NewUserRepo(db, todoRepo))
+
+		// Act
+		err = repo.AcceptInvitation(3, &types.User{ID: 2})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should keep the higher role of someone who already has access", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		expiresAt := time.Now().UTC().Add(time.Hour).Format(dateTimeLayout)
+		mock.ExpectQuery("^SELECT i.todo_id, i.inviter_id, i.role, i.expires_at, t.deleted_at, t.workspace_id FROM invitations").WithArgs(3, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"todo_id", "inviter_id", "role", "expires_at", "deleted_at", "workspace_id"}).
+				AddRow(5, 1, types.RoleEditor, expiresAt, nil, nil))
+		mock.ExpectExec("^DELETE FROM invitations WHERE id = \\?").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(1, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleOwner))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("^INSERT INTO user_todos (.+) ON DUPLICATE KEY UPDATE (.+) role = IF\\(").
+			WithArgs(5, 2, types.RoleEditor, positionGap, 2).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		todoRepo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+		repo := NewInvitationRepo(db, todoRepo, NewUserRepo(db, todoRepo))
+
+		// Act
+		err = repo.AcceptInvitation(3, &types.User{ID: 2})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
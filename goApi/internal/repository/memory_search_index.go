@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"github.com/floxo05/todoapi/internal/types"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemorySearchIndex is an in-process SearchIndex, e.g. for tests or setups without FULLTEXT support
+type MemorySearchIndex struct {
+	mu        sync.RWMutex
+	documents map[int][]string
+}
+
+func NewMemorySearchIndex() *MemorySearchIndex {
+	return &MemorySearchIndex{documents: map[int][]string{}}
+}
+
+func (m *MemorySearchIndex) IndexTodo(document types.SearchDocument) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.documents[document.TodoID] = documentTokens(document)
+	return nil
+}
+
+func (m *MemorySearchIndex) RemoveTodo(todoID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.documents, todoID)
+	return nil
+}
+
+// SearchTodos returns all matches, the index does not know who can see a todo
+func (m *MemorySearchIndex) SearchTodos(user *types.User, words []string, phrases []string, limit int) ([]int, error) {
+	var wordTokens []string
+	for _, word := range words {
+		wordTokens = append(wordTokens, tokenize(word)...)
+	}
+
+	var phraseTokens [][]string
+	for _, phrase := range phrases {
+		if tokens := tokenize(phrase); len(tokens) > 0 {
+			phraseTokens = append(phraseTokens, tokens)
+		}
+	}
+
+	if len(wordTokens) == 0 && len(phraseTokens) == 0 {
+		return nil, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scores := map[int]int{}
+	for id, tokens := range m.documents {
+		score := matchScore(tokens, wordTokens, phraseTokens)
+		if score > 0 {
+			scores[id] = score
+		}
+	}
+
+	ids := make([]int, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+
+	// best matches first, ties by id like the MariaDB implementation
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	return ids, nil
+}
+
+// matchScore counts the matching tokens of a document, it is 0 if a word or phrase is missing
+func matchScore(tokens []string, words []string, phrases [][]string) int {
+	score := 0
+	for _, word := range words {
+		matches := 0
+		for _, token := range tokens {
+			if strings.HasPrefix(token, word) {
+				matches++
+			}
+		}
+
+		if matches == 0 {
+			return 0
+		}
+		score += matches
+	}
+
+	for _, phrase := range phrases {
+		matches := 0
+		for i := 0; i+len(phrase) <= len(tokens); i++ {
+			if strings.Join(tokens[i:i+len(phrase)], " ") == strings.Join(phrase, " ") {
+				matches++
+			}
+		}
+
+		if matches == 0 {
+			return 0
+		}
+		score += matches * len(phrase)
+	}
+
+	return score
+}
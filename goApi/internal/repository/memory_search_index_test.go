@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"github.com/floxo05/todoapi/internal/types"
+	"reflect"
+	"testing"
+)
+
+func TestMemorySearchIndex_SearchTodos(t *testing.T) {
+	// Arrange
+	index := NewMemorySearchIndex()
+	_ = index.IndexTodo(types.SearchDocument{TodoID: 1, Title: "Write quarterly Report", Category: "Work"})
+	_ = index.IndexTodo(types.SearchDocument{TodoID: 2, Title: "Report report: Q3 numbers", Category: "Work"})
+	_ = index.IndexTodo(types.SearchDocument{TodoID: 3, Title: "Buy milk", Category: "Groceries"})
+
+	testcases := []struct {
+		name        string
+		words       []string
+		phrases     []string
+		expectedIds []int
+	}{
+		{name: "should rank by matches", words: []string{"report"}, expectedIds: []int{2, 1}},
+		{name: "should match prefixes", words: []string{"groc"}, expectedIds: []int{3}},
+		{name: "should require all words", words: []string{"report", "milk"}, expectedIds: nil},
+		{name: "should match phrases", phrases: []string{"q3 numbers"}, expectedIds: []int{2}},
+		{name: "should not match phrases out of order", phrases: []string{"numbers q3"}, expectedIds: nil},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			ids, err := index.SearchTodos(&types.User{ID: 1}, tc.words, tc.phrases, 10)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			if len(ids) == 0 && len(tc.expectedIds) == 0 {
+				return
+			}
+
+			if !reflect.DeepEqual(ids, tc.expectedIds) {
+				t.Errorf("Expected ids %v, but got %v", tc.expectedIds, ids)
+			}
+		})
+	}
+
+	t.Run("should forget removed todos", func(t *testing.T) {
+		_ = index.RemoveTodo(3)
+
+		ids, _ := index.SearchTodos(&types.User{ID: 1}, []string{"milk"}, nil, 10)
+		if len(ids) != 0 {
+			t.Errorf("Expected no ids, but got %v", ids)
+		}
+	})
+}
@@ -0,0 +1,363 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/floxo05/todoapi/internal/types"
+	"time"
+)
+
+const ownershipTransferColumns = `o.id, o.todo_id, t.title, f.username, r.username, a.username, o.created_at,
+	o.transferred_at`
+
+const ownershipTransferJoins = `
+	JOIN todos t ON t.id = o.todo_id
+	JOIN users f ON f.id = o.from_user_id
+	JOIN users r ON r.id = o.to_user_id
+	LEFT JOIN users a ON a.id = o.forced_by_id`
+
+type OwnershipRepo struct {
+	db       dbtx
+	todoRepo types.TodoRepository
+}
+
+func NewOwnershipRepo(db *sql.DB, todoRepo types.TodoRepository) *OwnershipRepo {
+	return &OwnershipRepo{db: db, todoRepo: todoRepo}
+}
+
+// RequestTransfer offers the todo to another user, only the owner can do this. A new request replaces the pending one.
+func (o *OwnershipRepo) RequestTransfer(todoID int, user *types.User, recipient *types.User) error {
+	err := o.todoRepo.RequireRole(todoID, user, types.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	if recipient.ID == user.ID {
+		return errors.New("user already owns the todo")
+	}
+
+	var count int
+	err = o.db.QueryRow("SELECT COUNT(*) FROM user_blocks WHERE user_id = ? AND blocked_user_id = ?", recipient.ID, user.ID).
+		Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errors.New("user does not accept invitations from you")
+	}
+
+	err = o.requireRecipient(todoID, recipient)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.db.Exec("DELETE FROM ownership_transfers WHERE todo_id = ? AND transferred_at IS NULL", todoID)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.db.Exec("INSERT INTO ownership_transfers (todo_id, from_user_id, to_user_id, created_at) VALUES (?, ?, ?, ?)",
+		todoID, user.ID, recipient.ID, time.Now().UTC().Truncate(time.Second))
+
+	return err
+}
+
+func (o *OwnershipRepo) GetTransfersByUser(user *types.User) ([]types.OwnershipTransfer, error) {
+	return o.queryTransfers(`
+		WHERE (o.to_user_id = ? OR o.from_user_id = ?) AND o.transferred_at IS NULL AND t.deleted_at IS NULL
+		ORDER BY o.created_at DESC, o.id DESC`, user.ID, user.ID)
+}
+
+// AcceptTransfer makes the user the owner of the todo. The transfer fails if the todo changed its owner since the
+// transfer was requested.
+func (o *OwnershipRepo) AcceptTransfer(transferID int, user *types.User) error {
+	var todoID, fromUserID int
+	var workspaceID sql.NullInt64
+	err := o.db.QueryRow(`
+		SELECT o.todo_id, o.from_user_id, t.workspace_id
+		FROM ownership_transfers o
+			JOIN todos t ON t.id = o.todo_id
+		WHERE o.id = ? AND o.to_user_id = ? AND o.transferred_at IS NULL`,
+		transferID, user.ID).Scan(&todoID, &fromUserID, &workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("transfer not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	// the previous owner is checked in the workspace of the todo
+	fromUser := &types.User{ID: fromUserID}
+	if workspaceID.Valid {
+		id := int(workspaceID.Int64)
+		fromUser.WorkspaceID = &id
+	}
+
+	isOwner, err := o.todoRepo.IsOwner(&types.Todo{ID: todoID}, fromUser)
+	if err != nil {
+		return err
+	}
+
+	if !isOwner {
+		return errors.New("the todo has another owner by now")
+	}
+
+	err = o.requireRecipient(todoID, user)
+	if err != nil {
+		return err
+	}
+
+	err = o.transferOwnership(todoID, fromUserID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.db.Exec("UPDATE ownership_transfers SET transferred_at = ? WHERE id = ?",
+		time.Now().UTC().Truncate(time.Second), transferID)
+
+	return err
+}
+
+func (o *OwnershipRepo) DeclineTransfer(transferID int, user *types.User) error {
+	result, err := o.db.Exec("DELETE FROM ownership_transfers WHERE id = ? AND (to_user_id = ? OR from_user_id = ?) AND transferred_at IS NULL",
+		transferID, user.ID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return errors.New("transfer not found")
+	}
+
+	return nil
+}
+
+// ForceTransfer gives a todo of a workspace to another member without asking the owner or the recipient, e.g. when
+// the owner left the team. Only owners and admins of the workspace can do this. Unlike an accepted transfer, the
+// previous owner does not stay on the todo as manager.
+func (o *OwnershipRepo) ForceTransfer(todoID int, user *types.User, recipient *types.User) error {
+	var ownerID int
+	var parentID, workspaceID sql.NullInt64
+	err := o.db.QueryRow("SELECT owner_id, parent_id, workspace_id FROM todos WHERE id = ? AND deleted_at IS NULL", todoID).
+		Scan(&ownerID, &parentID, &workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("todo not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	if !workspaceID.Valid {
+		return errors.New("only todos of a workspace can be transferred without the consent of the owner")
+	}
+
+	var role string
+	err = o.db.QueryRow("SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceID.Int64, user.ID).
+		Scan(&role)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if !hasWorkspaceRole(role, types.WorkspaceAdmin) {
+		return errors.New("only owners and admins of the workspace can force a transfer")
+	}
+
+	if recipient.ID == ownerID {
+		return errors.New("user already owns the todo")
+	}
+
+	err = o.requireRecipient(todoID, recipient)
+	if err != nil {
+		return err
+	}
+
+	err = o.transferOwnership(todoID, ownerID, recipient.ID)
+	if err != nil {
+		return err
+	}
+
+	err = o.dropPreviousOwner(todoID, ownerID, int(workspaceID.Int64))
+	if err != nil {
+		return err
+	}
+
+	_, err = o.db.Exec("DELETE FROM ownership_transfers WHERE todo_id = ? AND transferred_at IS NULL", todoID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err = o.db.Exec(`
+		INSERT INTO ownership_transfers (todo_id, from_user_id, to_user_id, forced_by_id, created_at, transferred_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, todoID, ownerID, recipient.ID, user.ID, now, now)
+
+	return err
+}
+
+// GetTransferHistory returns the completed transfers of the todo, oldest first
+func (o *OwnershipRepo) GetTransferHistory(todoID int, user *types.User) ([]types.OwnershipTransfer, error) {
+	err := o.todoRepo.RequireRole(todoID, user, types.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.queryTransfers(`
+		WHERE o.todo_id = ? AND o.transferred_at IS NOT NULL
+		ORDER BY o.transferred_at, o.id`, todoID)
+}
+
+// requireRecipient fails unless the user can own the todo: subtasks belong to the owner of their parent and todos
+// of a workspace only to members who are no guests
+func (o *OwnershipRepo) requireRecipient(todoID int, recipient *types.User) error {
+	var parentID, workspaceID sql.NullInt64
+	err := o.db.QueryRow("SELECT parent_id, workspace_id FROM todos WHERE id = ?", todoID).Scan(&parentID, &workspaceID)
+	if err != nil {
+		return err
+	}
+
+	if parentID.Valid {
+		return errors.New("subtasks belong to the owner of their parent, transfer the parent instead")
+	}
+
+	if !workspaceID.Valid {
+		return nil
+	}
+
+	var role string
+	err = o.db.QueryRow("SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceID.Int64, recipient.ID).
+		Scan(&role)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if !hasWorkspaceRole(role, types.WorkspaceMember) {
+		return errors.New("user is not a member of the workspace")
+	}
+
+	return nil
+}
+
+// transferOwnership moves the todo and its subtasks to the new owner. The previous owner stays on the todos as
+// manager unless the transfer was forced, the new owner gets direct access that does not depend on a category or workspace anymore.
+func (o *OwnershipRepo) transferOwnership(todoID int, fromUserID int, toUserID int) error {
+	subtaskIds, err := collectSubtaskIds(o.db, []int{todoID})
+	if err != nil {
+		return err
+	}
+
+	ids := append([]int{todoID}, subtaskIds...)
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, toUserID)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	_, err = o.db.Exec("UPDATE todos SET owner_id = ?, version = version + 1 WHERE id IN ("+placeholders(len(ids))+")", args...)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		_, err = o.db.Exec("UPDATE user_todos SET role = ? WHERE todo_id = ? AND user_id = ?", types.RoleManager, id, fromUserID)
+		if err != nil {
+			return err
+		}
+
+		_, err = o.db.Exec(`
+			INSERT INTO user_todos (todo_id, user_id, role, position) `+appendPositionSelect+`
+			ON DUPLICATE KEY UPDATE role = VALUES(role), via_category_id = NULL, via_workspace_id = NULL,
+				via_parent_id = NULL`,
+			appendPositionArgs(id, toUserID, types.RoleOwner)...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dropPreviousOwner removes the access of the previous owner to the todo and its subtasks. Access the previous owner
+// has as member of the workspace or of the category of the todo is granted again.
+func (o *OwnershipRepo) dropPreviousOwner(todoID int, fromUserID int, workspaceID int) error {
+	subtaskIds, err := collectSubtaskIds(o.db, []int{todoID})
+	if err != nil {
+		return err
+	}
+
+	ids := append([]int{todoID}, subtaskIds...)
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, fromUserID)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	_, err = o.db.Exec("DELETE FROM user_todos WHERE user_id = ? AND todo_id IN ("+placeholders(len(ids))+")", args...)
+	if err != nil {
+		return err
+	}
+
+	err = grantWorkspaceAccess(o.db, workspaceID, ids)
+	if err != nil {
+		return err
+	}
+
+	var categoryID sql.NullInt64
+	err = o.db.QueryRow("SELECT category_id FROM todos WHERE id = ?", todoID).Scan(&categoryID)
+	if err != nil {
+		return err
+	}
+
+	if categoryID.Valid {
+		err = grantCategoryAccess(o.db, int(categoryID.Int64), ids)
+		if err != nil {
+			return err
+		}
+	}
+
+	return unassignWithoutAccess(o.db, ids)
+}
+
+func (o *OwnershipRepo) queryTransfers(conditions string, args ...interface{}) ([]types.OwnershipTransfer, error) {
+	rows, err := o.db.Query("SELECT "+ownershipTransferColumns+" FROM ownership_transfers o"+ownershipTransferJoins+conditions,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := []types.OwnershipTransfer{}
+	for rows.Next() {
+		var transfer types.OwnershipTransfer
+		var forcedBy, transferredAt sql.NullString
+		var createdAt string
+		err = rows.Scan(&transfer.ID, &transfer.TodoID, &transfer.TodoTitle, &transfer.From, &transfer.To, &forcedBy,
+			&createdAt, &transferredAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if forcedBy.Valid {
+			transfer.ForcedBy = &forcedBy.String
+		}
+
+		transfer.CreatedAt, err = time.Parse(dateTimeLayout, createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		transfer.TransferredAt, err = parseNullDateTime(transferredAt)
+		if err != nil {
+			return nil, err
+		}
+
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, rows.Err()
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/floxo05/todoapi/internal/types"
+	"testing"
+)
+
+func TestOwnershipRepo_AcceptTransfer(t *testing.T) {
+	t.Run("should make the recipient the owner and keep the previous owner as manager", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT o.todo_id, o.from_user_id, t.workspace_id FROM ownership_transfers").WithArgs(3, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"todo_id", "from_user_id", "workspace_id"}).AddRow(5, 1, nil))
+		mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM todos WHERE id = \\? AND owner_id = \\? AND workspace_id IS NULL").WithArgs(5, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("^SELECT parent_id, workspace_id FROM todos").WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"parent_id", "workspace_id"}).AddRow(nil, nil))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("^UPDATE todos SET owner_id").WithArgs(2, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^UPDATE user_todos SET role").WithArgs(types.RoleManager, 5, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^INSERT INTO user_todos").WithArgs(5, 2, types.RoleOwner, positionGap, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^UPDATE ownership_transfers SET transferred_at").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := NewOwnershipRepo(db, NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex()))
+
+		// Act
+		err = repo.AcceptTransfer(3, &types.User{ID: 2})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should fail if the todo has another owner by now", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT o.todo_id, o.from_user_id, t.workspace_id FROM ownership_transfers").WithArgs(3, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"todo_id", "from_user_id", "workspace_id"}).AddRow(5, 1, nil))
+		mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM todos WHERE id = \\? AND owner_id = \\? AND workspace_id IS NULL").WithArgs(5, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		repo := NewOwnershipRepo(db, NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex()))
+
+		// Act
+		err = repo.AcceptTransfer(3, &types.User{ID: 2})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestOwnershipRepo_ForceTransfer(t *testing.T) {
+	t.Run("should only let admins of the workspace force a transfer", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT owner_id, parent_id, workspace_id FROM todos").WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"owner_id", "parent_id", "workspace_id"}).AddRow(1, nil, 4))
+		mock.ExpectQuery("^SELECT role FROM workspace_members").WithArgs(4, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.WorkspaceMember))
+
+		repo := NewOwnershipRepo(db, NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex()))
+
+		// Act
+		err = repo.ForceTransfer(5, &types.User{ID: 3}, &types.User{ID: 2})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should take the todo away from the previous owner", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT owner_id, parent_id, workspace_id FROM todos").WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"owner_id", "parent_id", "workspace_id"}).AddRow(1, nil, 4))
+		mock.ExpectQuery("^SELECT role FROM workspace_members").WithArgs(4, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.WorkspaceAdmin))
+		mock.ExpectQuery("^SELECT parent_id, workspace_id FROM todos").WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"parent_id", "workspace_id"}).AddRow(nil, 4))
+		mock.ExpectQuery("^SELECT role FROM workspace_members").WithArgs(4, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.WorkspaceMember))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("^UPDATE todos SET owner_id = \\?").WithArgs(2, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^UPDATE user_todos SET role = \\?").WithArgs(types.RoleManager, 5, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^INSERT INTO user_todos").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("^DELETE FROM user_todos WHERE user_id = \\?").WithArgs(1, 5).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^INSERT IGNORE INTO user_todos").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("^SELECT category_id FROM todos").WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"category_id"}).AddRow(nil))
+		mock.ExpectExec("^DELETE a FROM todo_assignees a").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM ownership_transfers").WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^INSERT INTO ownership_transfers").WillReturnResult(sqlmock.NewResult(1, 1))
+
+		repo := NewOwnershipRepo(db, NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex()))
+
+		// Act
+		err = repo.ForceTransfer(5, &types.User{ID: 3}, &types.User{ID: 2})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/floxo05/todoapi/internal/types"
+)
+
+// pageCursor points behind the last row of a page. It is handed out base64 encoded, so clients treat it as opaque.
+type pageCursor struct {
+	// Sort identifies the ordering the cursor was created for
+	Sort string `json:"s"`
+	// Value is the sort value of the last row, nil for sql NULL or orderings by id only
+	Value *string `json:"v,omitempty"`
+	ID    int     `json:"id"`
+}
+
+func encodeCursor(cursor pageCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(value string, sort string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, types.ErrInvalidCursor
+	}
+
+	var cursor pageCursor
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return nil, types.ErrInvalidCursor
+	}
+
+	if cursor.Sort != sort {
+		return nil, fmt.Errorf("%w: it belongs to another sort order", types.ErrInvalidCursor)
+	}
+
+	return &cursor, nil
+}
@@ -0,0 +1,36 @@
+package repository
+
+// Every user orders the todos of their list in user_todos.position. Moving a todo only rewrites its own position:
+// it gets the middle between its new neighbours. New todos are appended behind the last one with a gap.
+const (
+	positionGap = 1024.0
+	// below this distance the neighbours can not be split anymore and the list of the user is renumbered
+	minPositionGap = 1e-6
+)
+
+// appendPositionSelect selects the values of a user_todos row (todo_id, user_id, role, position) that puts the todo
+// at the end of the list of the user, the arguments come from appendPositionArgs
+const appendPositionSelect = "SELECT ?, ?, ?, COALESCE(MAX(position), 0) + ? FROM user_todos WHERE user_id = ?"
+
+func appendPositionArgs(todoID int, userID int, role string) []interface{} {
+	return []interface{}{todoID, userID, role, positionGap, userID}
+}
+
+// positionBetween returns the position between two neighbours, nil stands for the start or the end of the list.
+// It reports false if there is no room left between the neighbours.
+func positionBetween(before *float64, after *float64) (float64, bool) {
+	switch {
+	case before == nil && after == nil:
+		return positionGap, true
+	case before == nil:
+		return *after - positionGap, true
+	case after == nil:
+		return *before + positionGap, true
+	}
+
+	if *after-*before < minPositionGap {
+		return 0, false
+	}
+
+	return *before + (*after-*before)/2, true
+}
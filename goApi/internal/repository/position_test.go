@@ -0,0 +1,42 @@
+package repository
+
+import "testing"
+
+func TestPositionBetween(t *testing.T) {
+	before, after := 1024.0, 2048.0
+
+	t.Run("should take the middle between two neighbours", func(t *testing.T) {
+		// Act
+		position, ok := positionBetween(&before, &after)
+
+		// Assert
+		if !ok || position != 1536 {
+			t.Errorf("Expected position 1536, but got %v", position)
+		}
+	})
+
+	t.Run("should leave a gap at the start and the end of the list", func(t *testing.T) {
+		// Act
+		first, _ := positionBetween(nil, &before)
+		last, _ := positionBetween(&after, nil)
+		only, _ := positionBetween(nil, nil)
+
+		// Assert
+		if first != 0 || last != 3072 || only != positionGap {
+			t.Errorf("Expected positions 0, 3072 and %v, but got %v, %v and %v", positionGap, first, last, only)
+		}
+	})
+
+	t.Run("should report when there is no room left", func(t *testing.T) {
+		// Arrange
+		next := before + minPositionGap/2
+
+		// Act
+		_, ok := positionBetween(&before, &next)
+
+		// Assert
+		if ok {
+			t.Errorf("Expected no room between %v and %v", before, next)
+		}
+	})
+}
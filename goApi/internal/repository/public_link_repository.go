@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/floxo05/todoapi/internal/types"
+	"time"
+)
+
+// number of random bytes of a link token, the token is their base64 encoding
+const publicLinkTokenBytes = 32
+
+const publicLinkColumns = `id, token, todo_id, category_id, created_user_id, password, workspace_id, created_at,
+	expires_at, access_count, last_accessed_at`
+
+type PublicLinkRepo struct {
+	db           dbtx
+	todoRepo     types.TodoRepository
+	categoryRepo types.CategoryRepository
+}
+
+func NewPublicLinkRepo(db *sql.DB, todoRepo types.TodoRepository, categoryRepo types.CategoryRepository) *PublicLinkRepo {
+	return &PublicLinkRepo{db: db, todoRepo: todoRepo, categoryRepo: categoryRepo}
+}
+
+// CreatePublicLink creates a link with a new token. Like sharing it needs the manager role on the todo or category.
+// The password of the link must already be hashed. The link shows the todos of the workspace of the todo or category.
+func (r *PublicLinkRepo) CreatePublicLink(link *types.PublicLink, user *types.User) error {
+	if (link.TodoID == nil) == (link.CategoryID == nil) {
+		return errors.New("a public link needs either a todo or a category")
+	}
+
+	err := r.requireLinkManager(link, user)
+	if err != nil {
+		return err
+	}
+
+	var workspaceID sql.NullInt64
+	if link.TodoID != nil {
+		err = r.db.QueryRow("SELECT workspace_id FROM todos WHERE id = ?", *link.TodoID).Scan(&workspaceID)
+	} else {
+		err = r.db.QueryRow("SELECT workspace_id FROM categories WHERE id = ?", *link.CategoryID).Scan(&workspaceID)
+	}
+	if err != nil {
+		return err
+	}
+
+	token := make([]byte, publicLinkTokenBytes)
+	_, err = rand.Read(token)
+	if err != nil {
+		return err
+	}
+
+	link.Token = base64.RawURLEncoding.EncodeToString(token)
+	link.CreatedUserID = user.ID
+	link.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	link.HasPassword = link.Password != ""
+	link.WorkspaceID = nil
+	if workspaceID.Valid {
+		id := int(workspaceID.Int64)
+		link.WorkspaceID = &id
+	}
+
+	var password *string
+	if link.HasPassword {
+		password = &link.Password
+	}
+
+	res, err := r.db.Exec(`
+		INSERT INTO public_links (token, todo_id, category_id, created_user_id, password, workspace_id, created_at,
+		                          expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		link.Token, link.TodoID, link.CategoryID, link.CreatedUserID, password, link.WorkspaceID, link.CreatedAt,
+		link.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	linkID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	link.ID = int(linkID)
+
+	return nil
+}
+
+// GetPublicLinksByUser returns the links the user created and all links of the todos and categories the user
+// manages, newest first
+func (r *PublicLinkRepo) GetPublicLinksByUser(user *types.User) ([]types.PublicLink, error) {
+	managerRoles := rolesFrom(types.RoleManager)
+	args := append([]interface{}{user.ID, user.ID}, managerRoles...)
+	args = append(args, user.ID, user.ID, types.RoleManager)
+	rows, err := r.db.Query(`
+		SELECT `+publicLinkColumns+`
+		FROM public_links
+		WHERE created_user_id = ?
+		   OR todo_id IN (SELECT todo_id FROM user_todos WHERE user_id = ? AND role IN (`+placeholders(len(managerRoles))+`))
+		   OR category_id IN (SELECT id FROM categories WHERE created_user_id = ?)
+		   OR category_id IN (SELECT category_id FROM category_members WHERE user_id = ? AND role = ?)
+		ORDER BY id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []types.PublicLink{}
+	for rows.Next() {
+		var link *types.PublicLink
+		link, err = scanPublicLink(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, *link)
+	}
+
+	return links, rows.Err()
+}
+
+// RevokePublicLink deletes the link, the token stops working right away. Besides its creator the managers of the
+// todo or category can revoke it.
+func (r *PublicLinkRepo) RevokePublicLink(linkID int, user *types.User) error {
+	link, err := scanPublicLink(r.db.QueryRow("SELECT "+publicLinkColumns+" FROM public_links WHERE id = ?", linkID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.ErrPublicLinkNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if link.CreatedUserID != user.ID {
+		err = r.requireLinkManager(link, user)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = r.db.Exec("DELETE FROM public_links WHERE id = ?", linkID)
+
+	return err
+}
+
+// GetPublicLinkByToken returns nil for unknown and expired tokens and for links whose creator is no manager of the
+// todo or category anymore
+func (r *PublicLinkRepo) GetPublicLinkByToken(token string) (*types.PublicLink, error) {
+	row := r.db.QueryRow("SELECT "+publicLinkColumns+" FROM public_links WHERE token = ?", token)
+
+	link, err := scanPublicLink(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now().UTC()) {
+		return nil, nil
+	}
+
+	// the link is checked with the workspace it was created in
+	creator := &types.User{ID: link.CreatedUserID, WorkspaceID: link.WorkspaceID}
+	err = r.requireLinkManager(link, creator)
+	if err != nil {
+		return nil, nil
+	}
+
+	return link, nil
+}
+
+// requireLinkManager fails unless the user is at least manager of the todo or the category of the link
+func (r *PublicLinkRepo) requireLinkManager(link *types.PublicLink, user *types.User) error {
+	if link.TodoID != nil {
+		return r.todoRepo.RequireRole(*link.TodoID, user, types.RoleManager)
+	}
+
+	role, err := r.categoryRepo.CategoryRole(*link.CategoryID, user)
+	if err != nil {
+		return err
+	}
+
+	if role == "" {
+		return fmt.Errorf("user does not have access to the category: %w", types.ErrPermissionDenied)
+	}
+
+	if !hasRole(role, types.RoleManager) {
+		return fmt.Errorf("user needs to be at least %s of the category: %w", types.RoleManager, types.ErrPermissionDenied)
+	}
+
+	return nil
+}
+
+// CountAccess records that the link was opened
+func (r *PublicLinkRepo) CountAccess(link *types.PublicLink) error {
+	_, err := r.db.Exec("UPDATE public_links SET access_count = access_count + 1, last_accessed_at = UTC_TIMESTAMP() WHERE id = ?",
+		link.ID)
+
+	return err
+}
+
+func scanPublicLink(row rowScanner) (*types.PublicLink, error) {
+	var link types.PublicLink
+	var todoID, categoryID, workspaceID sql.NullInt64
+	var password, expiresAt, lastAccessedAt sql.NullString
+	var createdAt string
+	err := row.Scan(&link.ID, &link.Token, &todoID, &categoryID, &link.CreatedUserID, &password, &workspaceID, &createdAt,
+		&expiresAt, &link.AccessCount, &lastAccessedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if todoID.Valid {
+		id := int(todoID.Int64)
+		link.TodoID = &id
+	}
+
+	if categoryID.Valid {
+		id := int(categoryID.Int64)
+		link.CategoryID = &id
+	}
+
+	if workspaceID.Valid {
+		id := int(workspaceID.Int64)
+		link.WorkspaceID = &id
+	}
+
+	link.Password = password.String
+	link.HasPassword = password.Valid
+
+	link.CreatedAt, err = time.Parse(dateTimeLayout, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	link.ExpiresAt, err = parseNullDateTime(expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	link.LastAccessedAt, err = parseNullDateTime(lastAccessedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &link, nil
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/floxo05/todoapi/internal/types"
+	"testing"
+	"time"
+)
+
+var publicLinkColumnNames = []string{"id", "token", "todo_id", "category_id", "created_user_id", "password",
+	"workspace_id", "created_at", "expires_at", "access_count", "last_accessed_at"}
+
+func TestPublicLinkRepo_GetPublicLinkByToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		expiresAt   interface{}
+		creatorRole string
+		expectNil   bool
+	}{
+		{name: "should return links without expiry", expiresAt: nil, creatorRole: types.RoleManager},
+		{name: "should return links that did not expire yet", expiresAt: time.Now().UTC().Add(time.Hour).Format(dateTimeLayout), creatorRole: types.RoleOwner},
+		{name: "should not return expired links", expiresAt: time.Now().UTC().Add(-time.Hour).Format(dateTimeLayout), expectNil: true},
+		{name: "should not return links of creators who are no manager anymore", expiresAt: nil, creatorRole: types.RoleEditor, expectNil: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectQuery("^SELECT (.+) FROM public_links WHERE token = ?").WithArgs("token").
+				WillReturnRows(sqlmock.NewRows(publicLinkColumnNames).
+					AddRow(1, "token", 2, nil, 1, nil, nil, "2022-01-01 00:00:00", tt.expiresAt, 0, nil))
+			if tt.creatorRole != "" {
+				mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(1, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(tt.creatorRole))
+			}
+
+			repo := NewPublicLinkRepo(db, NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex()), &mockCategoryRepo{})
+
+			// Act
+			link, err := repo.GetPublicLinkByToken("token")
+
+			// Assert
+			if err != nil {
+				t.Errorf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			if tt.expectNil && link != nil {
+				t.Errorf("Expected no link, but got link %d", link.ID)
+			}
+
+			if !tt.expectNil && (link == nil || *link.TodoID != 2 || link.HasPassword) {
+				t.Errorf("Expected the link of todo 2 without password, but got %v", link)
+			}
+		})
+	}
+}
+
+func TestPublicLinkRepo_CreatePublicLink(t *testing.T) {
+	t.Run("should need either a todo or a category", func(t *testing.T) {
+		// Arrange
+		db, _, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		repo := NewPublicLinkRepo(db, NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex()), &mockCategoryRepo{})
+		id := 1
+
+		// Act
+		err = repo.CreatePublicLink(&types.PublicLink{TodoID: &id, CategoryID: &id}, &types.User{ID: 1})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+
+	t.Run("should create a random token", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT workspace_id FROM categories").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"workspace_id"}).AddRow(nil))
+		mock.ExpectExec("^INSERT INTO public_links").WillReturnResult(sqlmock.NewResult(3, 1))
+
+		repo := NewPublicLinkRepo(db, NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex()), &mockCategoryRepo{})
+		categoryID := 1
+		link := types.PublicLink{CategoryID: &categoryID}
+
+		// Act
+		err = repo.CreatePublicLink(&link, &types.User{ID: 1})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if link.ID != 3 || len(link.Token) != 43 {
+			t.Errorf("Expected link 3 with a token of 43 characters, but got link %d with token %q", link.ID, link.Token)
+		}
+	})
+}
+
+func TestPublicLinkRepo_RevokePublicLink(t *testing.T) {
+	t.Run("should let managers of the todo revoke links of others", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT (.+) FROM public_links WHERE id = ?").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(publicLinkColumnNames).
+				AddRow(1, "token", 2, nil, 1, nil, nil, "2022-01-01 00:00:00", nil, 0, nil))
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(3, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleOwner))
+		mock.ExpectExec("^DELETE FROM public_links WHERE id = ?").WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := NewPublicLinkRepo(db, NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex()), &mockCategoryRepo{})
+
+		// Act
+		err = repo.RevokePublicLink(1, &types.User{ID: 3})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"github.com/floxo05/todoapi/internal/types"
+	"strings"
+)
+
+func errRoleRequired(role string) error {
+	return fmt.Errorf("user needs to be at least %s of the todo: %w", role, types.ErrPermissionDenied)
+}
+
+// roleRank returns the position of the role in types.Roles, -1 for unknown roles
+func roleRank(role string) int {
+	for rank, name := range types.Roles {
+		if name == role {
+			return rank
+		}
+	}
+
+	return -1
+}
+
+// keepHigherRole is the ON DUPLICATE KEY UPDATE clause of a user_todos insert that keeps the higher of the existing
+// and the inserted role. If the inserted role is higher, the access no longer depends on a category or workspace.
+// Inherited access becomes direct when the same role is shared on the todo itself.
+var keepHigherRole = func() string {
+	ranks := "'" + strings.Join(types.Roles, "', '") + "'"
+	existingIsHigher := "FIELD(role, " + ranks + ") >= FIELD(VALUES(role), " + ranks + ")"
+	sharedDirectly := "role = VALUES(role) AND VALUES(via_parent_id) IS NULL"
+
+	// the via columns are assigned first, so they still see the existing role
+	return "ON DUPLICATE KEY UPDATE via_category_id = IF(" + existingIsHigher + ", via_category_id, NULL), " +
+		"via_workspace_id = IF(" + existingIsHigher + ", via_workspace_id, NULL), " +
+		"via_parent_id = IF(" + existingIsHigher + " AND NOT (" + sharedDirectly + "), via_parent_id, VALUES(via_parent_id)), " +
+		"role = IF(" + existingIsHigher + ", role, VALUES(role))"
+}()
+
+// hasRole reports whether the role includes the rights of the required role
+func hasRole(role string, required string) bool {
+	return roleRank(role) >= 0 && roleRank(role) >= roleRank(required)
+}
+
+// rolesFrom returns the required role and all roles with more rights, e.g. for sql IN conditions
+func rolesFrom(required string) []interface{} {
+	var roles []interface{}
+	for _, role := range types.Roles {
+		if hasRole(role, required) {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+// requireGrant fails unless the user may give the role on the todo to someone else. Managers can share as viewer
+// or editor, only the owner can add managers and nobody can give away the owner role.
+func requireGrant(todoRepo types.TodoRepository, todoID int, user *types.User, role string) error {
+	if role == types.RoleOwner || roleRank(role) < 0 {
+		return errors.New("role can not be granted")
+	}
+
+	required := types.RoleManager
+	if role == types.RoleManager {
+		required = types.RoleOwner
+	}
+
+	return todoRepo.RequireRole(todoID, user, required)
+}
+
+// workspaceRoleRank returns the position of the role in types.WorkspaceRoles, -1 for unknown roles
+func workspaceRoleRank(role string) int {
+	for rank, name := range types.WorkspaceRoles {
+		if name == role {
+			return rank
+		}
+	}
+
+	return -1
+}
+
+// hasWorkspaceRole reports whether the workspace role includes the rights of the required role
+func hasWorkspaceRole(role string, required string) bool {
+	return workspaceRoleRank(role) >= 0 && workspaceRoleRank(role) >= workspaceRoleRank(required)
+}
+
+// workspaceTodoRole returns the role members of a workspace have on its todos, "" for guests
+func workspaceTodoRole(role string) string {
+	switch role {
+	case types.WorkspaceOwner, types.WorkspaceAdmin:
+		return types.RoleManager
+	case types.WorkspaceMember:
+		return types.RoleEditor
+	}
+
+	return ""
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"database/sql"
+	"github.com/floxo05/todoapi/internal/types"
+	"strings"
+	"unicode"
+)
+
+// MariaDBSearchIndex keeps the searchable text of every todo in the FULLTEXT indexed todo_search table
+type MariaDBSearchIndex struct {
+	db *sql.DB
+}
+
+func NewMariaDBSearchIndex(db *sql.DB) *MariaDBSearchIndex {
+	return &MariaDBSearchIndex{db: db}
+}
+
+func (m *MariaDBSearchIndex) IndexTodo(document types.SearchDocument) error {
+	_, err := m.db.Exec("REPLACE INTO todo_search (todo_id, content) VALUES (?, ?)",
+		document.TodoID, strings.Join(documentTokens(document), " "))
+
+	return err
+}
+
+func (m *MariaDBSearchIndex) RemoveTodo(todoID int) error {
+	_, err := m.db.Exec("DELETE FROM todo_search WHERE todo_id = ?", todoID)
+
+	return err
+}
+
+// SearchTodos limits the matches to the todos of the user, so the todos of others do not use up the limit
+func (m *MariaDBSearchIndex) SearchTodos(user *types.User, words []string, phrases []string, limit int) ([]int, error) {
+	// every word is required and matches as prefix, phrases are required as a whole
+	var terms []string
+	for _, word := range words {
+		for _, token := range tokenize(word) {
+			terms = append(terms, "+"+token+"*")
+		}
+	}
+	for _, phrase := range phrases {
+		if tokens := tokenize(phrase); len(tokens) > 0 {
+			terms = append(terms, `+"`+strings.Join(tokens, " ")+`"`)
+		}
+	}
+
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	match := strings.Join(terms, " ")
+	workspace, workspaceArgs := workspaceCondition("t.workspace_id", user)
+	args := append([]interface{}{user.ID, match}, workspaceArgs...)
+	rows, err := m.db.Query(`
+		SELECT s.todo_id
+		FROM todo_search s
+			JOIN todos t ON t.id = s.todo_id
+			JOIN user_todos ut ON ut.todo_id = s.todo_id AND ut.user_id = ?
+		WHERE MATCH(s.content) AGAINST (? IN BOOLEAN MODE) AND t.deleted_at IS NULL AND `+workspace+`
+		ORDER BY MATCH(s.content) AGAINST (? IN BOOLEAN MODE) DESC, s.todo_id
+		LIMIT ?`, append(args, match, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func documentTokens(document types.SearchDocument) []string {
+	return tokenize(document.Title + " " + document.Category + " " + document.Description)
+}
+
+// tokenize lowercases the text and splits it into words of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
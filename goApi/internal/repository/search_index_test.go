@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/floxo05/todoapi/internal/types"
+	"reflect"
+	"testing"
+)
+
+func TestMariaDBSearchIndex_SearchTodos(t *testing.T) {
+	t.Run("should only count the todos of the user towards the limit", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		workspaceID := 4
+		mock.ExpectQuery("^SELECT s.todo_id FROM todo_search s (.+) ut.user_id = \\? WHERE MATCH(.+) AND t.workspace_id = \\? ORDER BY (.+) LIMIT \\?$").
+			WithArgs(1, "+milk*", workspaceID, "+milk*", 10).
+			WillReturnRows(sqlmock.NewRows([]string{"todo_id"}).AddRow(3))
+
+		index := NewMariaDBSearchIndex(db)
+
+		// Act
+		ids, err := index.SearchTodos(&types.User{ID: 1, WorkspaceID: &workspaceID}, []string{"milk"}, nil, 10)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if !reflect.DeepEqual(ids, []int{3}) {
+			t.Errorf("Expected ids [3], but got %v", ids)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/floxo05/todoapi/internal/types"
+)
+
+type TagRepo struct {
+	db dbtx
+}
+
+func NewTagRepo(db *sql.DB) *TagRepo {
+	return &TagRepo{db: db}
+}
+
+func (r *TagRepo) CreateTag(tag *types.Tag) error {
+	exists, err := r.titleExists(tag.Title, tag.CreatedUserId)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return types.ErrTagExists
+	}
+
+	res, err := r.db.Exec("INSERT INTO tags (title, created_user_id) VALUES (?, ?)", tag.Title, tag.CreatedUserId)
+	if err != nil {
+		return err
+	}
+
+	tagID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	tag.ID = int(tagID)
+
+	return nil
+}
+
+func (r *TagRepo) GetTagsByUserId(userID int) ([]types.Tag, error) {
+	rows, err := r.db.Query("SELECT id, title, created_user_id FROM tags WHERE created_user_id = ? ORDER BY title", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []types.Tag{}
+	for rows.Next() {
+		var tag types.Tag
+		err = rows.Scan(&tag.ID, &tag.Title, &tag.CreatedUserId)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// GetTagsByTodoIds returns the tags of the user for each of the todos
+func (r *TagRepo) GetTagsByTodoIds(todoIDs []int, userID int) (map[int][]types.Tag, error) {
+	tags := map[int][]types.Tag{}
+	if len(todoIDs) == 0 {
+		return tags, nil
+	}
+
+	args := []interface{}{userID}
+	for _, id := range todoIDs {
+		args = append(args, id)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT tt.todo_id, g.id, g.title, g.created_user_id
+		FROM todo_tags tt
+			JOIN tags g ON g.id = tt.tag_id
+		WHERE g.created_user_id = ? AND tt.todo_id IN (`+placeholders(len(todoIDs))+`)
+		ORDER BY g.title`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoID int
+		var tag types.Tag
+		err = rows.Scan(&todoID, &tag.ID, &tag.Title, &tag.CreatedUserId)
+		if err != nil {
+			return nil, err
+		}
+		tags[todoID] = append(tags[todoID], tag)
+	}
+
+	return tags, nil
+}
+
+func (r *TagRepo) RenameTag(tag *types.Tag, user *types.User) error {
+	existing, err := r.getOwnTag(tag.ID, user)
+	if err != nil {
+		return err
+	}
+
+	if existing.Title != tag.Title {
+		var exists bool
+		exists, err = r.titleExists(tag.Title, user.ID)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return types.ErrTagExists
+		}
+	}
+
+	_, err = r.db.Exec("UPDATE tags SET title = ? WHERE id = ?", tag.Title, tag.ID)
+	if err != nil {
+		return err
+	}
+
+	tag.CreatedUserId = user.ID
+
+	return nil
+}
+
+// MergeTags moves all todos of the source tag to the target tag and deletes the source tag
+func (r *TagRepo) MergeTags(source *types.Tag, target *types.Tag, user *types.User) error {
+	if source.ID == target.ID {
+		return errors.New("a tag can not be merged into itself")
+	}
+
+	_, err := r.getOwnTag(source.ID, user)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.getOwnTag(target.ID, user)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(`
+		INSERT IGNORE INTO todo_tags (todo_id, tag_id)
+		SELECT todo_id, ? FROM todo_tags WHERE tag_id = ?`,
+		target.ID, source.ID)
+	if err != nil {
+		return err
+	}
+
+	return r.DeleteTag(source, user)
+}
+
+func (r *TagRepo) DeleteTag(tag *types.Tag, user *types.User) error {
+	_, err := r.getOwnTag(tag.ID, user)
+	if err != nil {
+		return err
+	}
+
+	// delete association
+	_, err = r.db.Exec("DELETE FROM todo_tags WHERE tag_id = ?", tag.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec("DELETE FROM tags WHERE id = ?", tag.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *TagRepo) AttachTag(todoID int, tag *types.Tag, user *types.User) error {
+	err := r.checkTodoAccess(todoID, user)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.getOwnTag(tag.ID, user)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec("INSERT IGNORE INTO todo_tags (todo_id, tag_id) VALUES (?, ?)", todoID, tag.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *TagRepo) DetachTag(todoID int, tag *types.Tag, user *types.User) error {
+	err := r.checkTodoAccess(todoID, user)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.getOwnTag(tag.ID, user)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec("DELETE FROM todo_tags WHERE todo_id = ? AND tag_id = ?", todoID, tag.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *TagRepo) getOwnTag(id int, user *types.User) (*types.Tag, error) {
+	var tag types.Tag
+	err := r.db.QueryRow("SELECT id, title, created_user_id FROM tags WHERE id = ? AND created_user_id = ?", id, user.ID).
+		Scan(&tag.ID, &tag.Title, &tag.CreatedUserId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("tag not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+func (r *TagRepo) titleExists(title string, userID int) (bool, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM tags WHERE title = ? AND created_user_id = ?", title, userID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (r *TagRepo) checkTodoAccess(todoID int, user *types.User) error {
+	workspace, workspaceArgs := workspaceCondition("t.workspace_id", user)
+	args := append([]interface{}{user.ID, todoID}, workspaceArgs...)
+
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM user_todos ut JOIN todos t ON t.id = ut.todo_id "+
+		"WHERE ut.user_id = ? AND ut.todo_id = ? AND "+workspace, args...).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return errors.New("user does not have access to the todo")
+	}
+
+	return nil
+}
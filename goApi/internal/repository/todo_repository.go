@@ -2,27 +2,134 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/floxo05/todoapi/internal/types"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// upper bound for the number of todos a search returns
+const maxSearchResults = 200
+
 type TodoRepo struct {
-	db           *sql.DB
+	db           dbtx
 	categoryRepo types.CategoryRepository
+	tagRepo      types.TagRepository
+	searchIndex  types.SearchIndex
 }
 
-func NewTodoRepo(db *sql.DB, repo types.CategoryRepository) *TodoRepo {
-	return &TodoRepo{db: db, categoryRepo: repo}
+func NewTodoRepo(db *sql.DB, repo types.CategoryRepository, tagRepo types.TagRepository, searchIndex types.SearchIndex) *TodoRepo {
+	return &TodoRepo{db: db, categoryRepo: repo, tagRepo: tagRepo, searchIndex: searchIndex}
 }
 
-func (t *TodoRepo) GetAllTodosByUser(user *types.User) ([]types.Todo, error) {
-	rows, err := t.db.Query(`
+const todoColumns = `t.id, t.title, t.completed, t.created_at, t.owner_id, t.category_id,
+			t.due_at, t.due_has_time, t.start_at, t.start_has_time, t.recurrence_rule, t.occurrence, t.parent_id,
+			(SELECT COUNT(*) FROM todos s WHERE s.parent_id = t.id AND s.deleted_at IS NULL) AS subtask_count,
+			(SELECT COUNT(*) FROM todos s WHERE s.parent_id = t.id AND s.deleted_at IS NULL AND s.completed = true) AS subtasks_done,
+			t.priority, t.description, ut.position, t.deleted_at, t.completed_at, ut.archived_at, ut.role, t.workspace_id,
+			(SELECT JSON_ARRAYAGG(u.username ORDER BY u.username)
+			 FROM todo_assignees a JOIN users u ON u.id = a.user_id WHERE a.todo_id = t.id) AS assignees, t.version`
+
+const dateTimeLayout = "2006-01-02 15:04:05"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (t *TodoRepo) GetAllTodosByUser(user *types.User, filter types.TodoFilter) ([]types.Todo, error) {
+	conditions, args, err := todoConditions(filter, user, clientNow(filter))
+	if err != nil {
+		return nil, err
+	}
+
+	orderBy, err := todoOrderBy(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.queryTodos(user, conditions, args, orderBy, 0)
+}
+
+// GetTodosPageByUser returns one page of the todos of GetAllTodosByUser using keyset pagination,
+// so pages stay stable while todos are added or removed
+func (t *TodoRepo) GetTodosPageByUser(user *types.User, filter types.TodoFilter, page types.PageRequest) (*types.Page[types.Todo], error) {
+	conditions, args, err := todoConditions(filter, user, clientNow(filter))
+	if err != nil {
+		return nil, err
+	}
+
+	orderBy, err := todoOrderBy(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	pageConditions := conditions
+	pageArgs := append([]interface{}{}, args...)
+	if page.Cursor != "" {
+		var cursor *pageCursor
+		cursor, err = decodeCursor(page.Cursor, todoSortKey(filter))
+		if err != nil {
+			return nil, err
+		}
+
+		condition, cursorArgs := todoCursorCondition(filter, cursor)
+		pageConditions += " AND " + condition
+		pageArgs = append(pageArgs, cursorArgs...)
+	}
+
+	// one more row tells whether there is a next page
+	todos, err := t.queryTodos(user, pageConditions, pageArgs, orderBy, page.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := types.Page[types.Todo]{Items: []types.Todo{}}
+	if len(todos) > page.Limit {
+		todos = todos[:page.Limit]
+
+		last := todos[len(todos)-1]
+		var next string
+		next, err = encodeCursor(pageCursor{Sort: todoSortKey(filter), Value: todoCursorValue(filter, &last), ID: last.ID})
+		if err != nil {
+			return nil, err
+		}
+		result.NextCursor = &next
+	}
+	result.Items = append(result.Items, todos...)
+
+	if page.WithTotal {
+		var total int
+		err = t.db.QueryRow(`
+			SELECT COUNT(*)
+			FROM todos t 
+			    JOIN user_todos ut ON t.id = ut.todo_id 
+			WHERE `+conditions, args...).Scan(&total)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	return &result, nil
+}
+
+// queryTodos selects the todos matching the conditions, a limit of 0 returns all of them
+func (t *TodoRepo) queryTodos(user *types.User, conditions string, args []interface{}, orderBy string, limit int) ([]types.Todo, error) {
+	query := `
 		SELECT 
-			t.id, t.title, t.completed, t.created_at, t.owner_id, t.category_id
+			` + todoColumns + `
 		FROM todos t 
 		    JOIN user_todos ut ON t.id = ut.todo_id 
-		WHERE ut.user_id = ?`, user.ID)
+		WHERE ` + conditions + `
+		ORDER BY ` + orderBy
+	if limit > 0 {
+		query += " LIMIT " + strconv.Itoa(limit)
+	}
+
+	rows, err := t.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -30,40 +137,416 @@ func (t *TodoRepo) GetAllTodosByUser(user *types.User) ([]types.Todo, error) {
 
 	var todos []types.Todo
 	for rows.Next() {
-		var todo types.Todo
-		var category types.Category
-		var createdAt string
-		var categoryID sql.NullInt64
-		err := rows.Scan(&todo.ID, &todo.Title, &todo.Completed, &createdAt, &todo.OwnerID, &categoryID)
+		var todo *types.Todo
+		todo, err = t.scanTodo(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		if categoryID.Valid {
-			var cat *types.Category
-			cat, err = t.categoryRepo.GetCategoryByID(int(categoryID.Int64))
+		todos = append(todos, *todo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = t.attachTags(todos, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// attachTags loads the tags the user gave the todos
+func (t *TodoRepo) attachTags(todos []types.Todo, user *types.User) error {
+	ids := make([]int, 0, len(todos))
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+
+	tags, err := t.tagRepo.GetTagsByTodoIds(ids, user.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range todos {
+		todos[i].Tags = tags[todos[i].ID]
+		if todos[i].Tags == nil {
+			todos[i].Tags = []types.Tag{}
+		}
+	}
+
+	return nil
+}
+
+// todoConditions builds the where clause for the todos visible to the user that match the filter
+func todoConditions(filter types.TodoFilter, user *types.User, now time.Time) (string, []interface{}, error) {
+	workspace, workspaceArgs := workspaceCondition("t.workspace_id", user)
+	conditions := []string{"ut.user_id = ?", "t.deleted_at IS NULL", workspace}
+	args := append([]interface{}{user.ID}, workspaceArgs...)
+
+	if filter.Archived {
+		conditions = append(conditions, "ut.archived_at IS NOT NULL")
+	} else {
+		conditions = append(conditions, "ut.archived_at IS NULL")
+	}
+
+	if filter.Due != "" {
+		condition, dueArgs, err := dueCondition(filter.Due, now)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, dueArgs...)
+	}
+
+	if filter.Completed != nil {
+		conditions = append(conditions, "t.completed = ?")
+		args = append(args, *filter.Completed)
+	}
+
+	if filter.CategoryID != nil {
+		if *filter.CategoryID == 0 {
+			conditions = append(conditions, "t.category_id IS NULL")
+		} else {
+			conditions = append(conditions, "t.category_id = ?")
+			args = append(args, *filter.CategoryID)
+		}
+	}
+
+	if len(filter.Priorities) > 0 {
+		conditions = append(conditions, "t.priority IN ("+placeholders(len(filter.Priorities))+")")
+		for _, priority := range filter.Priorities {
+			level, err := priorityLevel(priority)
 			if err != nil {
-				return nil, err
+				return "", nil, err
 			}
-			category = *cat
+			args = append(args, level)
+		}
+	}
+
+	if len(filter.Tags) > 0 {
+		// tag titles are compared case insensitive, a repeated tag is only required once
+		tagArgs := []interface{}{user.ID}
+		seen := map[string]bool{}
+		for _, tag := range filter.Tags {
+			if !seen[strings.ToLower(tag)] {
+				seen[strings.ToLower(tag)] = true
+				tagArgs = append(tagArgs, tag)
+			}
+		}
+		tagCount := len(tagArgs) - 1
+
+		tagged := `(SELECT COUNT(DISTINCT g.id)
+			FROM todo_tags tt
+				JOIN tags g ON g.id = tt.tag_id
+			WHERE tt.todo_id = t.id AND g.created_user_id = ? AND g.title IN (` + placeholders(tagCount) + `))`
+
+		switch filter.TagMode {
+		case "", types.TagModeAll:
+			conditions = append(conditions, tagged+" = ?")
+			args = append(append(args, tagArgs...), tagCount)
+		case types.TagModeAny:
+			conditions = append(conditions, tagged+" > 0")
+			args = append(args, tagArgs...)
+		default:
+			return "", nil, errors.New("unknown tag mode")
+		}
+	}
+
+	if filter.AssignedToMe {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM todo_assignees a WHERE a.todo_id = t.id AND a.user_id = ?)")
+		args = append(args, user.ID)
+	}
+
+	switch filter.Ownership {
+	case "":
+	case types.OwnershipOwn:
+		conditions = append(conditions, "t.owner_id = ?")
+		args = append(args, user.ID)
+	case types.OwnershipShared:
+		conditions = append(conditions, "t.owner_id <> ?")
+		args = append(args, user.ID)
+	default:
+		return "", nil, errors.New("unknown ownership filter")
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// todoOrderBy returns the order by clause for the sorting of the filter, the id keeps the order stable
+func todoOrderBy(filter types.TodoFilter) (string, error) {
+	direction := "ASC"
+	switch filter.SortOrder {
+	case "", types.SortAsc:
+	case types.SortDesc:
+		direction = "DESC"
+	default:
+		return "", errors.New("unknown sort order")
+	}
+
+	switch filter.SortBy {
+	case "", types.SortPosition:
+		return "ut.position " + direction + ", t.id " + direction, nil
+	case types.SortCreatedAt:
+		return "t.created_at " + direction + ", t.id " + direction, nil
+	case types.SortArchivedAt:
+		return "ut.archived_at " + direction + ", t.id " + direction, nil
+	case types.SortDueAt:
+		// todos without due date come last
+		return "t.due_at IS NULL, t.due_at " + direction + ", t.id " + direction, nil
+	case types.SortPriority:
+		return "t.priority " + direction + ", t.id " + direction, nil
+	case types.SortTitle:
+		return "t.title " + direction + ", t.id " + direction, nil
+	}
+
+	return "", errors.New("unknown sort field")
+}
+
+func todoSortKey(filter types.TodoFilter) string {
+	return filter.SortBy + ":" + filter.SortOrder
+}
+
+// todoCursorValue returns the value of the sort field of a todo as it is stored in the cursor
+func todoCursorValue(filter types.TodoFilter, todo *types.Todo) *string {
+	var value string
+	switch filter.SortBy {
+	case "", types.SortPosition:
+		value = strconv.FormatFloat(todo.Position, 'g', -1, 64)
+	case types.SortCreatedAt:
+		value = todo.CreatedAt.Format(dateTimeLayout)
+	case types.SortArchivedAt:
+		if todo.ArchivedAt == nil {
+			return nil
+		}
+		value = todo.ArchivedAt.Format(dateTimeLayout)
+	case types.SortDueAt:
+		if todo.DueAt == nil {
+			return nil
+		}
+		value = todo.DueAt.Format(dateTimeLayout)
+	case types.SortPriority:
+		level, _ := priorityLevel(todo.Priority)
+		value = strconv.Itoa(level)
+	case types.SortTitle:
+		value = todo.Title
+	default:
+		return nil
+	}
+
+	return &value
+}
+
+// todoCursorCondition selects the rows behind the cursor in the order of todoOrderBy
+func todoCursorCondition(filter types.TodoFilter, cursor *pageCursor) (string, []interface{}) {
+	compare := ">"
+	if filter.SortOrder == types.SortDesc {
+		compare = "<"
+	}
+
+	var column string
+	switch filter.SortBy {
+	case "", types.SortPosition:
+		column = "ut.position"
+	case types.SortCreatedAt:
+		column = "t.created_at"
+	case types.SortArchivedAt:
+		column = "ut.archived_at"
+	case types.SortDueAt:
+		// todos without due date come last
+		if cursor.Value == nil {
+			return "(t.due_at IS NULL AND t.id " + compare + " ?)", []interface{}{cursor.ID}
+		}
+
+		return "(t.due_at IS NULL OR t.due_at " + compare + " ? OR (t.due_at = ? AND t.id " + compare + " ?))",
+			[]interface{}{*cursor.Value, *cursor.Value, cursor.ID}
+	case types.SortPriority:
+		column = "t.priority"
+	case types.SortTitle:
+		column = "t.title"
+	default:
+		return "t.id > ?", []interface{}{cursor.ID}
+	}
+
+	var value interface{}
+	if cursor.Value != nil {
+		value = *cursor.Value
+	}
+
+	return "(" + column + " " + compare + " ? OR (" + column + " = ? AND t.id " + compare + " ?))",
+		[]interface{}{value, value, cursor.ID}
+}
+
+// clientNow returns the current time in the time zone of the client that sent the filter
+func clientNow(filter types.TodoFilter) time.Time {
+	if filter.Location == nil {
+		return time.Now().UTC()
+	}
+
+	return time.Now().In(filter.Location)
+}
+
+// dueCondition translates a due filter mode into a sql condition on the todos table.
+// now is in the time zone of the client, its calendar day decides what counts as today.
+func dueCondition(mode string, now time.Time) (string, []interface{}, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch mode {
+	case types.DueOverdue:
+		return `t.completed = false AND (
+			(t.due_has_time = true AND t.due_at < ?) OR (t.due_has_time = false AND t.due_at < ?))`,
+			[]interface{}{now.UTC(), today}, nil
+	case types.DueToday:
+		tomorrow := today.AddDate(0, 0, 1)
+		condition, args := dueRangeCondition(&today, &tomorrow, now.Location())
+		return condition, args, nil
+	case types.DueThisWeek:
+		// weeks start on monday
+		weekStart := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		weekEnd := weekStart.AddDate(0, 0, 7)
+		condition, args := dueRangeCondition(&weekStart, &weekEnd, now.Location())
+		return condition, args, nil
+	case types.DueNone:
+		return "t.due_at IS NULL", nil, nil
+	}
+
+	return "", nil, errors.New("unknown due filter")
+}
+
+// dueRangeCondition matches the todos due on the calendar days [from, before), either bound may be nil.
+// The days are given as midnight UTC. Date-only due dates are stored at midnight UTC and count as due for the
+// whole day, due dates with a time are compared with the day boundaries in the location of the client.
+func dueRangeCondition(from *time.Time, before *time.Time, location *time.Location) (string, []interface{}) {
+	dayStart := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, location).UTC()
+	}
+
+	dateOnly, timed := []string{"t.due_has_time = false"}, []string{"t.due_has_time = true"}
+	var dateOnlyArgs, timedArgs []interface{}
+	if from != nil {
+		dateOnly, timed = append(dateOnly, "t.due_at >= ?"), append(timed, "t.due_at >= ?")
+		dateOnlyArgs, timedArgs = append(dateOnlyArgs, *from), append(timedArgs, dayStart(*from))
+	}
+	if before != nil {
+		dateOnly, timed = append(dateOnly, "t.due_at < ?"), append(timed, "t.due_at < ?")
+		dateOnlyArgs, timedArgs = append(dateOnlyArgs, *before), append(timedArgs, dayStart(*before))
+	}
+
+	return "((" + strings.Join(dateOnly, " AND ") + ") OR (" + strings.Join(timed, " AND ") + "))",
+		append(dateOnlyArgs, timedArgs...)
+}
+
+// scanTodo reads a row selected with todoColumns into a todo
+func (t *TodoRepo) scanTodo(row rowScanner) (*types.Todo, error) {
+	var todo types.Todo
+	var createdAt string
+	var categoryID sql.NullInt64
+	var dueAt, startAt, deletedAt, completedAt, archivedAt, assignees sql.NullString
+	var parentID, workspaceID sql.NullInt64
+	var priority int
+	err := row.Scan(&todo.ID, &todo.Title, &todo.Completed, &createdAt, &todo.OwnerID, &categoryID,
+		&dueAt, &todo.DueHasTime, &startAt, &todo.StartHasTime, &todo.RecurrenceRule, &todo.Occurrence, &parentID,
+		&todo.SubtaskCount, &todo.SubtasksDone, &priority, &todo.Description, &todo.Position,
+		&deletedAt, &completedAt, &archivedAt, &todo.Role, &workspaceID, &assignees, &todo.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	todo.Assignees = []string{}
+	if assignees.Valid {
+		err = json.Unmarshal([]byte(assignees.String), &todo.Assignees)
+		if err != nil {
+			return nil, err
 		}
+	}
+
+	if workspaceID.Valid {
+		id := int(workspaceID.Int64)
+		todo.WorkspaceID = &id
+	}
+
+	if priority < 0 || priority >= len(types.Priorities) {
+		return nil, errors.New("invalid priority level")
+	}
+	todo.Priority = types.Priorities[priority]
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		todo.ParentID = &id
+	}
 
-		// Parse the string into a time.Time type
-		todo.CreatedAt, err = time.Parse("2006-01-02 15:04:05", createdAt)
+	if categoryID.Valid {
+		var cat *types.Category
+		cat, err = t.categoryRepo.GetCategoryByID(int(categoryID.Int64))
 		if err != nil {
 			return nil, err
 		}
+		todo.Category = *cat
+	}
 
-		todo.Category = category
+	// Parse the string into a time.Time type
+	todo.CreatedAt, err = time.Parse(dateTimeLayout, createdAt)
+	if err != nil {
+		return nil, err
+	}
 
-		todos = append(todos, todo)
+	todo.DueAt, err = parseNullDateTime(dueAt)
+	if err != nil {
+		return nil, err
 	}
 
-	return todos, nil
+	todo.StartAt, err = parseNullDateTime(startAt)
+	if err != nil {
+		return nil, err
+	}
+
+	todo.DeletedAt, err = parseNullDateTime(deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	todo.CompletedAt, err = parseNullDateTime(completedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	todo.ArchivedAt, err = parseNullDateTime(archivedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &todo, nil
+}
+
+func parseNullDateTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(dateTimeLayout, value.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsed, nil
 }
 
 func (t *TodoRepo) CreateTodo(todo *types.Todo) error {
-	res, err := t.db.Exec("INSERT INTO todos (title, completed, created_at, owner_id) VALUES (?, ?, ?, ?)", todo.Title, todo.Completed, todo.CreatedAt, todo.OwnerID)
+	priority, err := priorityLevel(todo.Priority)
+	if err != nil {
+		return err
+	}
+
+	res, err := t.db.Exec(`
+		INSERT INTO todos (title, completed, created_at, owner_id, category_id, due_at, due_has_time, start_at,
+		                   start_has_time, recurrence_rule, occurrence, parent_id, priority, description, completed_at,
+		                   workspace_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, IF(?, UTC_TIMESTAMP(), NULL), ?)`,
+		todo.Title, todo.Completed, todo.CreatedAt, todo.OwnerID, nullableID(todo.Category.ID),
+		todo.DueAt, todo.DueHasTime, todo.StartAt, todo.StartHasTime, todo.RecurrenceRule, todo.Occurrence,
+		todo.ParentID, priority, todo.Description, todo.Completed, todo.WorkspaceID)
 	if err != nil {
 		return err
 	}
@@ -74,79 +557,1443 @@ func (t *TodoRepo) CreateTodo(todo *types.Todo) error {
 	}
 
 	todo.ID = int(todoID)
+	todo.Version = 1
 
-	_, err = t.db.Exec("INSERT INTO user_todos (user_id, todo_id) VALUES (?, ?)", todo.OwnerID, todo.ID)
+	_, err = t.db.Exec("INSERT INTO user_todos (todo_id, user_id, role, position) "+appendPositionSelect,
+		appendPositionArgs(todo.ID, todo.OwnerID, types.RoleOwner)...)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// subtasks are visible to everyone who can see the parent
+	if todo.ParentID != nil {
+		err = t.inheritAccess(*todo.ParentID, todo.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if todo.Category.ID != 0 {
+		err = grantCategoryAccess(t.db, todo.Category.ID, []int{todo.ID})
+		if err != nil {
+			return err
+		}
+	}
+
+	if todo.WorkspaceID != nil {
+		err = grantWorkspaceAccess(t.db, *todo.WorkspaceID, []int{todo.ID})
+		if err != nil {
+			return err
+		}
+	}
+
+	return t.indexTodo(todo)
 }
 
-func (t *TodoRepo) UpdateTodoById(todo *types.Todo, user *types.User) error {
-	var count int
-	var err error
+func (t *TodoRepo) indexTodo(todo *types.Todo) error {
+	return t.searchIndex.IndexTodo(types.SearchDocument{
+		TodoID:      todo.ID,
+		Title:       todo.Title,
+		Category:    todo.Category.Title,
+		Description: todo.Description,
+	})
+}
 
-	// check if user has access to the todo
-	err = t.db.QueryRow("SELECT COUNT(*) FROM user_todos WHERE user_id = ? AND todo_id = ?", user.ID, todo.ID).Scan(&count)
+// CreateNextOccurrence creates the next todo of a recurring series and shares it with everyone who had access to the
+// previous one, the assignees stay responsible for it. The follow-up is recorded on the previous todo, so completing
+// it again after reopening does not create another one.
+func (t *TodoRepo) CreateNextOccurrence(next *types.Todo, previous *types.Todo) error {
+	var nextOccurrenceID sql.NullInt64
+	err := t.db.QueryRow("SELECT next_occurrence_id FROM todos WHERE id = ? FOR UPDATE", previous.ID).
+		Scan(&nextOccurrenceID)
 	if err != nil {
 		return err
 	}
+	if nextOccurrenceID.Valid {
+		return types.ErrOccurrenceExists
+	}
 
-	if count == 0 {
-		return errors.New("user does not have access to update the todo")
+	err = t.CreateTodo(next)
+	if err != nil {
+		return err
 	}
 
-	err = t.categoryRepo.UpsertCategory(&todo.Category)
+	_, err = t.db.Exec("UPDATE todos SET next_occurrence_id = ? WHERE id = ?", next.ID, previous.ID)
 	if err != nil {
 		return err
 	}
 
-	_, err = t.db.Exec("UPDATE todos SET title = ?, completed = ?, category_id = ? WHERE id = ?", todo.Title, todo.Completed, todo.Category.ID, todo.ID)
+	err = t.copyAccess(previous.ID, next.ID)
+	if err != nil {
+		return err
+	}
 
-	// update
+	_, err = t.db.Exec("INSERT INTO todo_assignees (todo_id, user_id) SELECT ?, user_id FROM todo_assignees WHERE todo_id = ?",
+		next.ID, previous.ID)
 	if err != nil {
 		return err
 	}
 
+	next.Assignees = previous.Assignees
+
 	return nil
 }
 
-func (t *TodoRepo) DeleteTodoById(todo *types.Todo, user *types.User) error {
-	// check if user is the owner of the todo
-	isOwner, err := t.IsOwner(todo, user)
-	if err != nil {
-		return err
-	}
+// copyAccess gives every user with access to one todo the same role on another todo, it is appended to their lists
+func (t *TodoRepo) copyAccess(fromTodoID int, toTodoID int) error {
+	return t.insertAccess(fromTodoID, toTodoID, nil)
+}
 
-	if !isOwner {
-		return errors.New("user does not have right to delete the todo")
+// inheritAccess gives everyone who can see the parent access to the subtask, the access remembers the ancestor it
+// was shared on, so it can be taken away if the subtask moves to another parent
+func (t *TodoRepo) inheritAccess(parentID int, todoID int) error {
+	return t.insertAccess(parentID, todoID, &parentID)
+}
+
+func (t *TodoRepo) insertAccess(fromTodoID int, toTodoID int, viaParentID *int) error {
+	_, err := t.db.Exec(`
+		INSERT IGNORE INTO user_todos (user_id, todo_id, role, position, via_category_id, via_workspace_id, via_parent_id)
+		SELECT u.user_id, ?, u.role, (SELECT COALESCE(MAX(p.position), 0) + ? FROM user_todos p WHERE p.user_id = u.user_id),
+		       u.via_category_id, u.via_workspace_id, COALESCE(u.via_parent_id, ?)
+		FROM user_todos u WHERE u.todo_id = ?`,
+		toTodoID, positionGap, viaParentID, fromTodoID)
+
+	return err
+}
+
+// GetTodoById returns the todo if it belongs to the active workspace of the user
+func (t *TodoRepo) GetTodoById(id int, user *types.User) (*types.Todo, error) {
+	workspace, workspaceArgs := workspaceCondition("t.workspace_id", user)
+	row := t.db.QueryRow(`
+		SELECT 
+			`+todoColumns+`
+		FROM todos t 
+		    JOIN user_todos ut ON t.id = ut.todo_id 
+		WHERE t.id = ? AND ut.user_id = ? AND t.deleted_at IS NULL AND `+workspace,
+		append([]interface{}{id, user.ID}, workspaceArgs...)...)
+
+	todo, err := t.scanTodo(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("todo not found")
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// delete association
-	_, err = t.db.Exec("DELETE FROM user_todos where todo_id = ?", todo.ID)
+	todos := []types.Todo{*todo}
+	err = t.attachTags(todos, user)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = t.db.Exec("DELETE FROM todos WHERE id = ?", todo.ID)
+	return &todos[0], nil
+}
+
+func (t *TodoRepo) UpdateTodoById(todo *types.Todo, user *types.User) error {
+	return t.UpdateTodoFields(todo, types.TodoFields, user)
+}
+
+// UpdateTodoFields only writes the columns of the given fields, the other fields of the todo are only read for the
+// search index
+func (t *TodoRepo) UpdateTodoFields(todo *types.Todo, fields []string, user *types.User) error {
+	role, err := t.todoRole(todo.ID, user)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	if !hasRole(role, types.RoleEditor) {
+		return errRoleRequired(types.RoleEditor)
+	}
 
-func (t *TodoRepo) IsOwner(todo *types.Todo, user *types.User) (bool, error) {
-	var count int
-	err := t.db.QueryRow("SELECT COUNT(*) FROM todos WHERE id = ? AND owner_id = ?", todo.ID, user.ID).Scan(&count)
+	var previousCategoryID, workspaceID sql.NullInt64
+	var version int
+	err = t.db.QueryRow("SELECT category_id, workspace_id, version FROM todos WHERE id = ?", todo.ID).
+		Scan(&previousCategoryID, &workspaceID, &version)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	if count == 0 {
-		return false, nil
+	if todo.Version != 0 && todo.Version != version {
+		return types.ErrVersionConflict
 	}
 
-	return true, nil
+	var assignments []string
+	var args []interface{}
+	categoryChanged, indexChanged := false, false
+	for _, field := range fields {
+		switch field {
+		case types.FieldTitle:
+			assignments = append(assignments, "title = ?")
+			args = append(args, todo.Title)
+			indexChanged = true
+		case types.FieldCompleted:
+			// completed_at comes first, the following assignments would already see the new value of completed
+			assignments = append(assignments,
+				"completed_at = IF(? = completed, completed_at, IF(?, UTC_TIMESTAMP(), NULL))", "completed = ?")
+			args = append(args, todo.Completed, todo.Completed, todo.Completed)
+		case types.FieldCategory:
+			// a todo that stays in its category keeps it, even if the user could not put it there
+			var unchanged bool
+			unchanged, err = t.sameCategory(&todo.Category, previousCategoryID)
+			if err != nil {
+				return err
+			}
+			if unchanged {
+				continue
+			}
+
+			// the members of a category get access to its todos, so only managers may put a todo into one
+			if todo.Category.ID != 0 || todo.Category.Title != "" {
+				if !hasRole(role, types.RoleManager) {
+					return errRoleRequired(types.RoleManager)
+				}
+			}
+
+			err = t.resolveCategory(&todo.Category, workspaceID, user)
+			if err != nil {
+				return err
+			}
+			assignments = append(assignments, "category_id = ?")
+			args = append(args, nullableID(todo.Category.ID))
+			categoryChanged, indexChanged = true, true
+		case types.FieldDueAt:
+			assignments = append(assignments, "due_at = ?")
+			args = append(args, todo.DueAt)
+		case types.FieldDueHasTime:
+			assignments = append(assignments, "due_has_time = ?")
+			args = append(args, todo.DueHasTime)
+		case types.FieldStartAt:
+			assignments = append(assignments, "start_at = ?")
+			args = append(args, todo.StartAt)
+		case types.FieldStartHasTime:
+			assignments = append(assignments, "start_has_time = ?")
+			args = append(args, todo.StartHasTime)
+		case types.FieldRecurrenceRule:
+			assignments = append(assignments, "recurrence_rule = ?")
+			args = append(args, todo.RecurrenceRule)
+		case types.FieldPriority:
+			var priority int
+			priority, err = priorityLevel(todo.Priority)
+			if err != nil {
+				return err
+			}
+			assignments = append(assignments, "priority = ?")
+			args = append(args, priority)
+		case types.FieldDescription:
+			assignments = append(assignments, "description = ?")
+			args = append(args, todo.Description)
+			indexChanged = true
+		default:
+			return fmt.Errorf("'%s' can not be updated", field)
+		}
+	}
+
+	todo.Version = version
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	err = t.saveRevision(todo.ID, user)
+	if err != nil {
+		return err
+	}
+
+	// the version is checked again in case the todo was changed since it was read above
+	res, err := t.db.Exec("UPDATE todos SET "+strings.Join(assignments, ", ")+", version = version + 1 "+
+		"WHERE id = ? AND version = ?", append(args, todo.ID, version)...)
+	if err != nil {
+		return err
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return types.ErrVersionConflict
+	}
+
+	todo.Version = version + 1
+
+	if categoryChanged {
+		err = t.changeCategoryAccess(todo.ID, int(previousCategoryID.Int64), todo.Category.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !indexChanged {
+		return nil
+	}
+
+	return t.indexTodo(todo)
+}
+
+// saveRevision keeps the current version of the todo as a revision, it is called before the todo is overwritten
+func (t *TodoRepo) saveRevision(todoID int, user *types.User) error {
+	_, err := t.db.Exec(`
+		INSERT INTO todo_revisions (todo_id, title, completed, category_id, category_title, due_at, due_has_time,
+		                            start_at, start_has_time, recurrence_rule, priority, description, replaced_by,
+		                            replaced_at)
+		SELECT t.id, t.title, t.completed, t.category_id, c.title, t.due_at, t.due_has_time, t.start_at,
+		       t.start_has_time, t.recurrence_rule, t.priority, t.description, ?, UTC_TIMESTAMP()
+		FROM todos t
+			LEFT JOIN categories c ON c.id = t.category_id
+		WHERE t.id = ?`, user.ID, todoID)
+
+	return err
+}
+
+// sameCategory reports whether the category of an update is the stored one of the todo, it is then completed with
+// the stored category
+func (t *TodoRepo) sameCategory(category *types.Category, storedCategoryID sql.NullInt64) (bool, error) {
+	if !storedCategoryID.Valid {
+		return category.ID == 0 && category.Title == "", nil
+	}
+
+	stored, err := t.categoryRepo.GetCategoryByID(int(storedCategoryID.Int64))
+	if err != nil {
+		return false, err
+	}
+
+	if (category.ID != 0 && category.ID != stored.ID) || category.Title != stored.Title {
+		return false, nil
+	}
+
+	*category = *stored
+
+	return true, nil
+}
+
+// resolveCategory finds the category a todo is put into. A category without id and title means no category.
+func (t *TodoRepo) resolveCategory(category *types.Category, workspaceID sql.NullInt64, user *types.User) error {
+	if category.ID == 0 && category.Title == "" {
+		return nil
+	}
+
+	// the category belongs to the workspace of the todo
+	category.WorkspaceID = nil
+	if workspaceID.Valid {
+		id := int(workspaceID.Int64)
+		category.WorkspaceID = &id
+	}
+
+	// a todo in a category shared with the user stays there, other categories are categories of the user
+	shared, err := t.sharedCategory(category, user)
+	if err != nil {
+		return err
+	}
+
+	if shared {
+		return nil
+	}
+
+	return t.categoryRepo.UpsertCategory(category)
+}
+
+func (t *TodoRepo) GetTodoRevisions(todoID int, user *types.User) ([]types.TodoRevision, error) {
+	err := t.RequireRole(todoID, user, types.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.queryRevisions("WHERE r.todo_id = ? ORDER BY r.replaced_at DESC, r.id DESC", todoID)
+}
+
+// RevertTodo writes the fields of the revision through UpdateTodoById, so the same permission checks apply
+func (t *TodoRepo) RevertTodo(todo *types.Todo, revisionID int, user *types.User) error {
+	current, err := t.GetTodoById(todo.ID, user)
+	if err != nil {
+		return err
+	}
+
+	if todo.Version != 0 && todo.Version != current.Version {
+		return types.ErrVersionConflict
+	}
+
+	revisions, err := t.queryRevisions("WHERE r.id = ? AND r.todo_id = ?", revisionID, todo.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(revisions) == 0 {
+		return errors.New("revision not found")
+	}
+
+	revision := revisions[0]
+	*todo = *current
+	todo.Title = revision.Title
+	todo.Completed = revision.Completed
+	todo.DueAt = revision.DueAt
+	todo.DueHasTime = revision.DueHasTime
+	todo.StartAt = revision.StartAt
+	todo.StartHasTime = revision.StartHasTime
+	todo.RecurrenceRule = revision.RecurrenceRule
+	todo.Priority = revision.Priority
+	todo.Description = revision.Description
+
+	// like in an update request the category is one of the user unless it is shared with them,
+	// a category that was deleted since is created again from its title
+	todo.Category = types.Category{CreatedUserId: user.ID}
+	if revision.CategoryID != nil {
+		var category *types.Category
+		category, err = t.categoryRepo.GetCategoryByID(*revision.CategoryID)
+		if err != nil {
+			return err
+		}
+
+		if category.ID != 0 {
+			todo.Category.ID = category.ID
+			todo.Category.Title = category.Title
+		} else if revision.CategoryTitle != nil {
+			todo.Category.Title = *revision.CategoryTitle
+		}
+	}
+
+	return t.UpdateTodoById(todo, user)
+}
+
+// queryRevisions reads the revisions matching the given condition and ordering
+func (t *TodoRepo) queryRevisions(condition string, args ...interface{}) ([]types.TodoRevision, error) {
+	rows, err := t.db.Query(`
+		SELECT r.id, r.todo_id, r.title, r.completed, r.category_id, r.category_title, r.due_at, r.due_has_time,
+		       r.start_at, r.start_has_time, r.recurrence_rule, r.priority, r.description, u.username, r.replaced_at
+		FROM todo_revisions r
+			JOIN users u ON u.id = r.replaced_by
+		`+condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []types.TodoRevision{}
+	for rows.Next() {
+		var revision types.TodoRevision
+		var categoryID sql.NullInt64
+		var categoryTitle, dueAt, startAt sql.NullString
+		var replacedAt string
+		var priority int
+		err = rows.Scan(&revision.ID, &revision.TodoID, &revision.Title, &revision.Completed, &categoryID,
+			&categoryTitle, &dueAt, &revision.DueHasTime, &startAt, &revision.StartHasTime, &revision.RecurrenceRule,
+			&priority, &revision.Description, &revision.ReplacedBy, &replacedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if categoryID.Valid {
+			id := int(categoryID.Int64)
+			revision.CategoryID = &id
+		}
+
+		if categoryTitle.Valid {
+			revision.CategoryTitle = &categoryTitle.String
+		}
+
+		if priority < 0 || priority >= len(types.Priorities) {
+			return nil, errors.New("invalid priority level")
+		}
+		revision.Priority = types.Priorities[priority]
+
+		revision.DueAt, err = parseNullDateTime(dueAt)
+		if err != nil {
+			return nil, err
+		}
+
+		revision.StartAt, err = parseNullDateTime(startAt)
+		if err != nil {
+			return nil, err
+		}
+
+		revision.ReplacedAt, err = time.Parse(dateTimeLayout, replacedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+// sharedCategory reports whether the category is a category of another user the user may put todos into,
+// it is then completed from the database
+func (t *TodoRepo) sharedCategory(category *types.Category, user *types.User) (bool, error) {
+	if category.ID == 0 {
+		return false, nil
+	}
+
+	current, err := t.categoryRepo.GetCategoryByID(category.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if current.ID == 0 || current.CreatedUserId == user.ID || current.Title != category.Title ||
+		!sameWorkspace(current.WorkspaceID, category.WorkspaceID) {
+		return false, nil
+	}
+
+	role, err := t.categoryRepo.CategoryRole(current.ID, user)
+	if err != nil {
+		return false, err
+	}
+
+	if !hasRole(role, types.RoleEditor) {
+		return false, nil
+	}
+
+	*category = *current
+
+	return true, nil
+}
+
+// changeCategoryAccess moves the access through shared categories along when the todo changes its category
+func (t *TodoRepo) changeCategoryAccess(todoID int, previousCategoryID int, categoryID int) error {
+	if previousCategoryID == categoryID {
+		return nil
+	}
+
+	subtaskIds, err := t.GetSubtaskIds(&types.Todo{ID: todoID})
+	if err != nil {
+		return err
+	}
+
+	todoIds := append([]int{todoID}, subtaskIds...)
+	if previousCategoryID != 0 {
+		err = revokeCategoryAccess(t.db, previousCategoryID, todoIds)
+		if err != nil {
+			return err
+		}
+	}
+
+	if categoryID != 0 {
+		return grantCategoryAccess(t.db, categoryID, todoIds)
+	}
+
+	return nil
+}
+
+// SetCompleted only changes the completion of the todo, unlike UpdateTodoById which writes all fields
+func (t *TodoRepo) SetCompleted(todo *types.Todo, completed bool, user *types.User) error {
+	current, err := t.GetTodoById(todo.ID, user)
+	if err != nil {
+		return err
+	}
+
+	if !hasRole(current.Role, types.RoleEditor) {
+		return errRoleRequired(types.RoleEditor)
+	}
+
+	if current.Completed != completed {
+		err = t.saveRevision(todo.ID, user)
+		if err != nil {
+			return err
+		}
+
+		_, err = t.db.Exec(`
+			UPDATE todos
+			SET completed = ?, completed_at = IF(?, UTC_TIMESTAMP(), NULL), version = version + 1
+			WHERE id = ?`,
+			completed, completed, todo.ID)
+		if err != nil {
+			return err
+		}
+		current.Completed = completed
+		current.Version++
+	}
+
+	*todo = *current
+
+	return nil
+}
+
+// DeleteTodoById moves the todo and its subtasks to the trash of the owner
+func (t *TodoRepo) DeleteTodoById(todo *types.Todo, user *types.User) error {
+	// check if user is the owner of the todo
+	isOwner, err := t.IsOwner(todo, user)
+	if err != nil {
+		return err
+	}
+
+	if !isOwner {
+		return errors.New("user does not have right to delete the todo")
+	}
+
+	subtaskIds, err := t.GetSubtaskIds(todo)
+	if err != nil {
+		return err
+	}
+
+	// the whole tree shares the deletion time, so it can be restored together.
+	// Subtasks that were already in the trash keep their own time.
+	deletedAt := time.Now().UTC().Truncate(time.Second)
+	res, err := t.db.Exec("UPDATE todos SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", deletedAt, todo.ID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return errors.New("todo not found")
+	}
+
+	if len(subtaskIds) == 0 {
+		return nil
+	}
+
+	_, err = t.db.Exec("UPDATE todos SET deleted_at = ? WHERE deleted_at IS NULL AND id IN ("+joinIds(subtaskIds)+")", deletedAt)
+
+	return err
+}
+
+// GetTrashByUser returns the todos the user moved to the trash, subtasks that were deleted with their parent are left out
+func (t *TodoRepo) GetTrashByUser(user *types.User) ([]types.Todo, error) {
+	workspace, workspaceArgs := workspaceCondition("t.workspace_id", user)
+	conditions := `ut.user_id = ? AND t.owner_id = ? AND t.deleted_at IS NOT NULL AND ` + workspace + `
+		AND NOT EXISTS (SELECT 1 FROM todos p WHERE p.id = t.parent_id AND p.deleted_at = t.deleted_at)`
+	args := append([]interface{}{user.ID, user.ID}, workspaceArgs...)
+
+	return t.queryTodos(user, conditions, args, "t.deleted_at DESC, t.id DESC", 0)
+}
+
+// RestoreTodo takes the todo out of the trash together with the subtasks that were deleted with it
+func (t *TodoRepo) RestoreTodo(todo *types.Todo, user *types.User) error {
+	isOwner, err := t.IsOwner(todo, user)
+	if err != nil {
+		return err
+	}
+
+	if !isOwner {
+		return errors.New("user does not have right to restore the todo")
+	}
+
+	deletedAt, parentID, err := t.trashState(todo)
+	if err != nil {
+		return err
+	}
+
+	if parentID.Valid {
+		var parentDeleted int
+		err = t.db.QueryRow("SELECT COUNT(*) FROM todos WHERE id = ? AND deleted_at IS NOT NULL", parentID.Int64).
+			Scan(&parentDeleted)
+		if err != nil {
+			return err
+		}
+
+		if parentDeleted > 0 {
+			return errors.New("the parent todo is in the trash, restore it first")
+		}
+	}
+
+	subtaskIds, err := t.GetSubtaskIds(todo)
+	if err != nil {
+		return err
+	}
+
+	ids := append([]int{todo.ID}, subtaskIds...)
+	_, err = t.db.Exec("UPDATE todos SET deleted_at = NULL WHERE deleted_at = ? AND id IN ("+joinIds(ids)+")", deletedAt)
+
+	return err
+}
+
+// PurgeTodo removes a todo in the trash and its subtasks permanently. The title of the todo is kept in todo, so the
+// purge can be recorded.
+func (t *TodoRepo) PurgeTodo(todo *types.Todo, user *types.User) error {
+	isOwner, err := t.IsOwner(todo, user)
+	if err != nil {
+		return err
+	}
+
+	if !isOwner {
+		return errors.New("user does not have right to delete the todo")
+	}
+
+	_, _, err = t.trashState(todo)
+	if err != nil {
+		return err
+	}
+
+	err = t.db.QueryRow("SELECT title FROM todos WHERE id = ?", todo.ID).Scan(&todo.Title)
+	if err != nil {
+		return err
+	}
+
+	return t.deleteTodoTree(todo.ID)
+}
+
+// PurgeDeletedTodos permanently removes all todos that were moved to the trash before the given time
+func (t *TodoRepo) PurgeDeletedTodos(deletedBefore time.Time) (int, error) {
+	rows, err := t.db.Query("SELECT id FROM todos WHERE deleted_at < ? ORDER BY id", deletedBefore.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		err = t.deleteTodoTree(id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(ids), nil
+}
+
+// ArchiveTodo archives the todo together with its subtasks in the list of the user, the archive is personal and
+// does not hide the todo from its other collaborators
+func (t *TodoRepo) ArchiveTodo(todo *types.Todo, user *types.User) error {
+	current, err := t.GetTodoById(todo.ID, user)
+	if err != nil {
+		return err
+	}
+
+	if current.ArchivedAt != nil {
+		return errors.New("todo is already archived")
+	}
+
+	subtaskIds, err := t.GetSubtaskIds(todo)
+	if err != nil {
+		return err
+	}
+
+	ids := append([]int{todo.ID}, subtaskIds...)
+	_, err = t.db.Exec("UPDATE user_todos SET archived_at = ? WHERE user_id = ? AND archived_at IS NULL AND todo_id IN ("+joinIds(ids)+")",
+		time.Now().UTC(), user.ID)
+
+	return err
+}
+
+// UnarchiveTodo brings the todo and its subtasks back to the todo list of the user
+func (t *TodoRepo) UnarchiveTodo(todo *types.Todo, user *types.User) error {
+	current, err := t.GetTodoById(todo.ID, user)
+	if err != nil {
+		return err
+	}
+
+	if current.ArchivedAt == nil {
+		return errors.New("todo is not archived")
+	}
+
+	subtaskIds, err := t.GetSubtaskIds(todo)
+	if err != nil {
+		return err
+	}
+
+	ids := append([]int{todo.ID}, subtaskIds...)
+	_, err = t.db.Exec("UPDATE user_todos SET archived_at = NULL WHERE user_id = ? AND todo_id IN ("+joinIds(ids)+")", user.ID)
+
+	return err
+}
+
+// ArchiveCompletedTodos archives the completed todos in the list of the user, a category limits it to the todos of
+// the category (0 for todos without category). It returns the number of archived todos.
+func (t *TodoRepo) ArchiveCompletedTodos(user *types.User, categoryID *int) (int, error) {
+	query := `
+		UPDATE user_todos ut
+			JOIN todos t ON t.id = ut.todo_id
+		SET ut.archived_at = ?
+		WHERE ut.user_id = ? AND t.completed = true AND ut.archived_at IS NULL AND t.deleted_at IS NULL`
+	args := []interface{}{time.Now().UTC(), user.ID}
+
+	workspace, workspaceArgs := workspaceCondition("t.workspace_id", user)
+	query += " AND " + workspace
+	args = append(args, workspaceArgs...)
+
+	if categoryID != nil {
+		if *categoryID == 0 {
+			query += " AND t.category_id IS NULL"
+		} else {
+			query += " AND t.category_id = ?"
+			args = append(args, *categoryID)
+		}
+	}
+
+	res, err := t.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	archived, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(archived), nil
+}
+
+// AutoArchiveCompletedTodos archives the completed todos in the lists of the users who turned on auto archiving once
+// their days have passed
+func (t *TodoRepo) AutoArchiveCompletedTodos(now time.Time) (int, error) {
+	res, err := t.db.Exec(`
+		UPDATE user_todos ut
+			JOIN todos t ON t.id = ut.todo_id
+			JOIN users u ON u.id = ut.user_id
+		SET ut.archived_at = ?
+		WHERE t.completed = true AND ut.archived_at IS NULL AND t.deleted_at IS NULL
+		  AND u.auto_archive_days IS NOT NULL AND t.completed_at <= DATE_SUB(?, INTERVAL u.auto_archive_days DAY)`,
+		now.UTC(), now.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	archived, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(archived), nil
+}
+
+// trashState returns the deletion time and the parent of a todo, it fails if the todo is not in the trash
+func (t *TodoRepo) trashState(todo *types.Todo) (string, sql.NullInt64, error) {
+	var deletedAt sql.NullString
+	var parentID sql.NullInt64
+	err := t.db.QueryRow("SELECT deleted_at, parent_id FROM todos WHERE id = ?", todo.ID).Scan(&deletedAt, &parentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", parentID, errors.New("todo not found")
+	}
+	if err != nil {
+		return "", parentID, err
+	}
+
+	if !deletedAt.Valid {
+		return "", parentID, errors.New("todo is not in the trash")
+	}
+
+	return deletedAt.String, parentID, nil
+}
+
+// deleteTodoTree permanently deletes a todo and its subtasks, the deepest ones first
+func (t *TodoRepo) deleteTodoTree(todoID int) error {
+	subtaskIds, err := t.GetSubtaskIds(&types.Todo{ID: todoID})
+	if err != nil {
+		return err
+	}
+
+	ids := append([]int{todoID}, subtaskIds...)
+	for i := len(ids) - 1; i >= 0; i-- {
+		_, err = t.db.Exec("DELETE FROM todo_tags WHERE todo_id = ?", ids[i])
+		if err != nil {
+			return err
+		}
+
+		_, err = t.db.Exec("DELETE FROM invitations WHERE todo_id = ?", ids[i])
+		if err != nil {
+			return err
+		}
+
+		_, err = t.db.Exec("DELETE FROM public_links WHERE todo_id = ?", ids[i])
+		if err != nil {
+			return err
+		}
+
+		_, err = t.db.Exec("DELETE FROM ownership_transfers WHERE todo_id = ?", ids[i])
+		if err != nil {
+			return err
+		}
+
+		_, err = t.db.Exec("DELETE FROM todo_assignees WHERE todo_id = ?", ids[i])
+		if err != nil {
+			return err
+		}
+
+		// revisions and mentions of the comments are deleted with them
+		_, err = t.db.Exec("DELETE FROM comments WHERE todo_id = ?", ids[i])
+		if err != nil {
+			return err
+		}
+
+		_, err = t.db.Exec("DELETE FROM todo_events WHERE todo_id = ?", ids[i])
+		if err != nil {
+			return err
+		}
+
+		_, err = t.db.Exec("DELETE FROM todo_revisions WHERE todo_id = ?", ids[i])
+		if err != nil {
+			return err
+		}
+
+		// delete association
+		_, err = t.db.Exec("DELETE FROM user_todos where todo_id = ?", ids[i])
+		if err != nil {
+			return err
+		}
+
+		_, err = t.db.Exec("DELETE FROM todos WHERE id = ?", ids[i])
+		if err != nil {
+			return err
+		}
+
+		// within a transaction the index only forgets the todo once the rows are gone for good
+		err = t.searchIndex.RemoveTodo(ids[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SearchTodos returns the todos of the user matching the query, best text matches first. At most maxSearchResults
+// todos are returned, truncated tells if there are more.
+func (t *TodoRepo) SearchTodos(user *types.User, query types.SearchQuery) ([]types.Todo, bool, error) {
+	workspace, workspaceArgs := workspaceCondition("t.workspace_id", user)
+	conditions := []string{"ut.user_id = ?", "t.deleted_at IS NULL", workspace}
+	args := append([]interface{}{user.ID}, workspaceArgs...)
+	orderBy := "t.id"
+	truncated := false
+
+	if len(query.Words) > 0 || len(query.Phrases) > 0 {
+		// one more than needed tells if the index has further matches
+		ids, err := t.searchIndex.SearchTodos(user, query.Words, query.Phrases, maxSearchResults+1)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if len(ids) == 0 {
+			return nil, false, nil
+		}
+
+		if len(ids) > maxSearchResults {
+			ids, truncated = ids[:maxSearchResults], true
+		}
+
+		idArgs := make([]interface{}, 0, len(ids))
+		for _, id := range ids {
+			idArgs = append(idArgs, id)
+		}
+
+		// keep the ranking of the index
+		conditions = append(conditions, "t.id IN ("+placeholders(len(ids))+")")
+		args = append(args, idArgs...)
+		orderBy = "FIELD(t.id, " + joinIds(ids) + ")"
+	}
+
+	if query.Category != "" {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM categories c WHERE c.id = t.category_id AND c.title = ?)")
+		args = append(args, query.Category)
+	}
+
+	if query.Completed != nil {
+		conditions = append(conditions, "t.completed = ?")
+		args = append(args, *query.Completed)
+	}
+
+	if query.Shared != nil {
+		// a todo is shared if someone besides the owner has access
+		if *query.Shared {
+			conditions = append(conditions, "EXISTS (SELECT 1 FROM user_todos o WHERE o.todo_id = t.id AND o.user_id <> t.owner_id)")
+		} else {
+			conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM user_todos o WHERE o.todo_id = t.id AND o.user_id <> t.owner_id)")
+		}
+	}
+
+	if query.DueFrom != nil || query.DueBefore != nil {
+		location := query.Location
+		if location == nil {
+			location = time.UTC
+		}
+
+		condition, dueArgs := dueRangeCondition(query.DueFrom, query.DueBefore, location)
+		conditions = append(conditions, condition)
+		args = append(args, dueArgs...)
+	}
+
+	todos, err := t.queryTodos(user, strings.Join(conditions, " AND "), args, orderBy, maxSearchResults+1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(todos) > maxSearchResults {
+		todos, truncated = todos[:maxSearchResults], true
+	}
+
+	return todos, truncated, nil
+}
+
+// GetTodoTree returns the todo with all subtasks the user has access to nested below it
+func (t *TodoRepo) GetTodoTree(id int, user *types.User) (*types.Todo, error) {
+	root, err := t.GetTodoById(id, user)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[int]*types.Todo{root.ID: root}
+	levels := [][]*types.Todo{{root}}
+	for {
+		parentIds := make([]interface{}, 0, len(levels[len(levels)-1]))
+		for _, parent := range levels[len(levels)-1] {
+			parentIds = append(parentIds, parent.ID)
+		}
+
+		var rows *sql.Rows
+		rows, err = t.db.Query(`
+			SELECT 
+				`+todoColumns+`
+			FROM todos t 
+				JOIN user_todos ut ON t.id = ut.todo_id 
+			WHERE ut.user_id = ? AND t.deleted_at IS NULL AND t.parent_id IN (`+placeholders(len(parentIds))+`)
+			ORDER BY ut.position, t.id`,
+			append([]interface{}{user.ID}, parentIds...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		var level []*types.Todo
+		for rows.Next() {
+			var todo *types.Todo
+			todo, err = t.scanTodo(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			if _, seen := byID[todo.ID]; !seen {
+				byID[todo.ID] = todo
+				level = append(level, todo)
+			}
+		}
+		rows.Close()
+
+		if len(level) == 0 {
+			break
+		}
+		levels = append(levels, level)
+	}
+
+	tags, err := t.tagRepo.GetTagsByTodoIds(mapKeys(byID), user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, todo := range byID {
+		todo.Tags = tags[id]
+		if todo.Tags == nil {
+			todo.Tags = []types.Tag{}
+		}
+	}
+
+	// attach the children bottom-up, so every copied subtask already holds its own subtasks
+	for i := len(levels) - 1; i > 0; i-- {
+		for _, todo := range levels[i] {
+			parent := byID[*todo.ParentID]
+			parent.Subtasks = append(parent.Subtasks, *todo)
+		}
+	}
+
+	return root, nil
+}
+
+// GetSubtaskIds returns the ids of all subtasks below the todo ordered by their depth
+func (t *TodoRepo) GetSubtaskIds(todo *types.Todo) ([]int, error) {
+	return collectSubtaskIds(t.db, []int{todo.ID})
+}
+
+// MoveTodo places the todo below a new parent, a nil parent turns it into a top level todo. Collaborators of the
+// previous parent lose the access they only had through it.
+func (t *TodoRepo) MoveTodo(todo *types.Todo, parentID *int, user *types.User) error {
+	isOwner, err := t.IsOwner(todo, user)
+	if err != nil {
+		return err
+	}
+
+	if !isOwner {
+		return errors.New("user does not have right to move the todo")
+	}
+
+	if parentID != nil {
+		// the todo and its new parent are both in the active workspace
+		_, err = t.GetTodoById(todo.ID, user)
+		if err != nil {
+			return err
+		}
+
+		var parent *types.Todo
+		parent, err = t.GetTodoById(*parentID, user)
+		if err != nil {
+			return err
+		}
+
+		if parent.OwnerID != user.ID {
+			return errors.New("subtasks must have the same owner as their parent")
+		}
+
+		// the new parent must not be the todo itself or one of its subtasks
+		for ancestor := parent; ; {
+			if ancestor.ID == todo.ID {
+				return errors.New("a todo can not be moved below itself")
+			}
+
+			if ancestor.ParentID == nil {
+				break
+			}
+
+			ancestor, err = t.GetTodoById(*ancestor.ParentID, user)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = t.db.Exec("UPDATE todos SET parent_id = ?, version = version + 1 WHERE id = ?", parentID, todo.ID)
+	if err != nil {
+		return err
+	}
+
+	subtaskIds, err := t.GetSubtaskIds(todo)
+	if err != nil {
+		return err
+	}
+
+	// the access inherited from the previous ancestors goes away, shares on the moved todos themselves stay
+	ids := append([]int{todo.ID}, subtaskIds...)
+	args := make([]interface{}, 0, 2*len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, args...)
+
+	_, err = t.db.Exec("DELETE FROM user_todos WHERE todo_id IN ("+placeholders(len(ids))+") AND via_parent_id IS NOT NULL "+
+		"AND via_parent_id NOT IN ("+placeholders(len(ids))+")", args...)
+	if err != nil {
+		return err
+	}
+
+	// the moved todo and its subtasks become visible to everyone who can see the new parent
+	if parentID != nil {
+		for _, id := range ids {
+			err = t.inheritAccess(*parentID, id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return unassignWithoutAccess(t.db, ids)
+}
+
+// ReorderTodo places the todo behind another todo in the list of the user, without afterID it becomes the first one.
+// With a category the todo is moved into it first and only todos of the category are neighbours.
+func (t *TodoRepo) ReorderTodo(todo *types.Todo, afterID *int, categoryID *int, user *types.User) error {
+	current, err := t.GetTodoById(todo.ID, user)
+	if err != nil {
+		return err
+	}
+
+	if afterID != nil && *afterID == todo.ID {
+		return errors.New("a todo can not be placed after itself")
+	}
+
+	if categoryID != nil && *categoryID != current.Category.ID {
+		// the position is personal, but the category is shared with the collaborators. The members of a category get
+		// access to its todos, so only managers may put a todo into one.
+		required := types.RoleEditor
+		if *categoryID != 0 {
+			required = types.RoleManager
+		}
+
+		if !hasRole(current.Role, required) {
+			return errRoleRequired(required)
+		}
+
+		category := types.Category{}
+		if *categoryID != 0 {
+			var cat *types.Category
+			cat, err = t.categoryRepo.GetCategoryByID(*categoryID)
+			if err != nil {
+				return err
+			}
+
+			if cat.ID == 0 || !sameWorkspace(cat.WorkspaceID, current.WorkspaceID) {
+				return errors.New("category not found")
+			}
+
+			var role string
+			role, err = t.categoryRepo.CategoryRole(cat.ID, user)
+			if err != nil {
+				return err
+			}
+
+			if !hasRole(role, types.RoleEditor) {
+				return errors.New("category not found")
+			}
+			category = *cat
+		}
+
+		err = t.saveRevision(todo.ID, user)
+		if err != nil {
+			return err
+		}
+
+		_, err = t.db.Exec("UPDATE todos SET category_id = ?, version = version + 1 WHERE id = ?", nullableID(category.ID),
+			todo.ID)
+		if err != nil {
+			return err
+		}
+
+		err = t.changeCategoryAccess(todo.ID, current.Category.ID, category.ID)
+		if err != nil {
+			return err
+		}
+
+		current.Category = category
+		err = t.indexTodo(current)
+		if err != nil {
+			return err
+		}
+	}
+
+	before, after, err := t.positionNeighbours(todo.ID, afterID, categoryID, user)
+	if err != nil {
+		return err
+	}
+
+	position, ok := positionBetween(before, after)
+	if !ok {
+		err = t.renumberPositions(user)
+		if err != nil {
+			return err
+		}
+
+		before, after, err = t.positionNeighbours(todo.ID, afterID, categoryID, user)
+		if err != nil {
+			return err
+		}
+		position, _ = positionBetween(before, after)
+	}
+
+	_, err = t.db.Exec("UPDATE user_todos SET position = ? WHERE user_id = ? AND todo_id = ?", position, user.ID, todo.ID)
+	if err != nil {
+		return err
+	}
+
+	todo.Position = position
+
+	return nil
+}
+
+// positionNeighbours returns the positions the todo is placed between, nil stands for the start or the end of the list
+func (t *TodoRepo) positionNeighbours(todoID int, afterID *int, categoryID *int, user *types.User) (*float64, *float64, error) {
+	if afterID != nil {
+		var position float64
+		var afterCategoryID sql.NullInt64
+		err := t.db.QueryRow(`
+			SELECT ut.position, t.category_id
+			FROM user_todos ut
+				JOIN todos t ON t.id = ut.todo_id
+			WHERE ut.user_id = ? AND ut.todo_id = ?`, user.ID, *afterID).Scan(&position, &afterCategoryID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, errors.New("todo to place after not found")
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if categoryID != nil && int(afterCategoryID.Int64) != *categoryID {
+			return nil, nil, errors.New("the todo to place after must be in the category")
+		}
+
+		next, err := t.positionAggregate("MIN", "ut.position > ?", []interface{}{position}, todoID, user)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return &position, next, nil
+	}
+
+	if categoryID == nil {
+		first, err := t.positionAggregate("MIN", "", nil, todoID, user)
+		return nil, first, err
+	}
+
+	// the first todo of the category is the new neighbour, an empty category places the todo at the end
+	condition, args := "t.category_id = ?", []interface{}{*categoryID}
+	if *categoryID == 0 {
+		condition, args = "t.category_id IS NULL", nil
+	}
+
+	first, err := t.positionAggregate("MIN", condition, args, todoID, user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if first == nil {
+		last, err := t.positionAggregate("MAX", "", nil, todoID, user)
+		return last, nil, err
+	}
+
+	previous, err := t.positionAggregate("MAX", "ut.position < ?", []interface{}{*first}, todoID, user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return previous, first, nil
+}
+
+// positionAggregate returns the MIN or MAX position of the other todos of the user matching the condition
+func (t *TodoRepo) positionAggregate(aggregate string, condition string, args []interface{}, todoID int, user *types.User) (*float64, error) {
+	query := `
+		SELECT ` + aggregate + `(ut.position)
+		FROM user_todos ut
+			JOIN todos t ON t.id = ut.todo_id
+		WHERE ut.user_id = ? AND ut.todo_id <> ?`
+	if condition != "" {
+		query += " AND " + condition
+	}
+
+	var position sql.NullFloat64
+	err := t.db.QueryRow(query, append([]interface{}{user.ID, todoID}, args...)...).Scan(&position)
+	if err != nil {
+		return nil, err
+	}
+
+	if !position.Valid {
+		return nil, nil
+	}
+
+	return &position.Float64, nil
+}
+
+// renumberPositions restores the gaps between all todos of the user, keeping their order
+func (t *TodoRepo) renumberPositions(user *types.User) error {
+	rows, err := t.db.Query("SELECT todo_id FROM user_todos WHERE user_id = ? ORDER BY position, todo_id", user.ID)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		_, err = t.db.Exec("UPDATE user_todos SET position = ? WHERE user_id = ? AND todo_id = ?",
+			float64(i+1)*positionGap, user.ID, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RequireRole fails unless the user has at least the given role on the todo
+func (t *TodoRepo) RequireRole(todoID int, user *types.User, role string) error {
+	userRole, err := t.todoRole(todoID, user)
+	if err != nil {
+		return err
+	}
+
+	if !hasRole(userRole, role) {
+		return errRoleRequired(role)
+	}
+
+	return nil
+}
+
+// todoRole returns the role of the user on the todo, it fails if the user has no access or the todo is not in the
+// active workspace of the user
+func (t *TodoRepo) todoRole(todoID int, user *types.User) (string, error) {
+	workspace, workspaceArgs := workspaceCondition("t.workspace_id", user)
+	args := append([]interface{}{user.ID, todoID}, workspaceArgs...)
+
+	var role string
+	err := t.db.QueryRow(`
+		SELECT ut.role
+		FROM user_todos ut
+			JOIN todos t ON t.id = ut.todo_id
+		WHERE ut.user_id = ? AND ut.todo_id = ? AND `+workspace, args...).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("user does not have access to the todo: %w", types.ErrPermissionDenied)
+	}
+
+	return role, err
+}
+
+// IsOwner reports whether the user owns the todo, todos outside of the active workspace of the user do not count
+func (t *TodoRepo) IsOwner(todo *types.Todo, user *types.User) (bool, error) {
+	workspace, workspaceArgs := workspaceCondition("workspace_id", user)
+	args := append([]interface{}{todo.ID, user.ID}, workspaceArgs...)
+
+	var count int
+	err := t.db.QueryRow("SELECT COUNT(*) FROM todos WHERE id = ? AND owner_id = ? AND "+workspace, args...).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	if count == 0 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// priorityLevel converts a priority name into the level stored in the database, an empty priority is none
+func priorityLevel(priority string) (int, error) {
+	if priority == "" {
+		return 0, nil
+	}
+
+	for level, name := range types.Priorities {
+		if name == priority {
+			return level, nil
+		}
+	}
+
+	return 0, errors.New("unknown priority")
+}
+
+// joinIds formats ids for sql, they are integers and therefore safe to inline
+func joinIds(ids []int) string {
+	formatted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		formatted = append(formatted, strconv.Itoa(id))
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+func mapKeys(todos map[int]*types.Todo) []int {
+	keys := make([]int, 0, len(todos))
+	for key := range todos {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// nullableID stores an unset id as NULL in optional foreign key columns
+func nullableID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+
+	return id
+}
+
+// placeholders returns n comma separated bind parameters for an IN clause
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
 }
@@ -1,9 +1,11 @@
 package repository
 
 import (
+	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/floxo05/todoapi/internal/types"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -11,7 +13,7 @@ import (
 func TestTodoRepo_NewTodoRepo(t *testing.T) {
 	t.Run("should return a new TodoRepo", func(t *testing.T) {
 		// Act
-		repo := NewTodoRepo(nil, &mockCategoryRepo{})
+		repo := NewTodoRepo(nil, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
 
 		// Assert
 		expectedType := "*repository.TodoRepo"
@@ -32,16 +34,16 @@ func TestTodoRepo_GetAllTodosByUser(t *testing.T) {
 
 		// case 1
 		t.Run("should return a list of todos", func(t *testing.T) {
-			rows := sqlmock.NewRows([]string{"id", "title", "completed", "created_at", "owner_id", "category_id"}).
-				AddRow(1, "Test Todo", false, "2022-01-01 00:00:00", 1, 1).
-				AddRow(2, "Test Todo 2", true, "2022-01-01 00:00:00", 2, 2)
+			rows := sqlmock.NewRows(todoColumnNames).
+				AddRow(1, "Test Todo", false, "2022-01-01 00:00:00", 1, 1, nil, false, nil, false, "", 1, nil, 0, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1).
+				AddRow(2, "Test Todo 2", true, "2022-01-01 00:00:00", 2, 2, "2022-01-05 00:00:00", false, nil, false, "FREQ=DAILY", 1, 1, 0, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1)
 
 			mock.ExpectQuery("^SELECT (.+) FROM todos").WillReturnRows(rows)
 
-			repo := NewTodoRepo(db, &mockCategoryRepo{})
+			repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
 
 			// Act
-			todos, err := repo.GetAllTodosByUser(&types.User{ID: 1})
+			todos, err := repo.GetAllTodosByUser(&types.User{ID: 1}, types.TodoFilter{})
 
 			// Assert
 			if err != nil {
@@ -64,10 +66,10 @@ func TestTodoRepo_GetAllTodosByUser(t *testing.T) {
 
 			mock.ExpectQuery("^SELECT (.+) FROM todos").WillReturnRows(rows)
 
-			repo := NewTodoRepo(db, &mockCategoryRepo{})
+			repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
 
 			// Act
-			_, err := repo.GetAllTodosByUser(&types.User{ID: 1})
+			_, err := repo.GetAllTodosByUser(&types.User{ID: 1}, types.TodoFilter{})
 
 			// Assert
 			if err == nil {
@@ -81,10 +83,10 @@ func TestTodoRepo_GetAllTodosByUser(t *testing.T) {
 
 			mock.ExpectQuery("^SELECT (.+) FROM todos").WillReturnRows(rows)
 
-			repo := NewTodoRepo(db, &mockCategoryRepo{})
+			repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
 
 			// Act
-			todos, err := repo.GetAllTodosByUser(&types.User{ID: 1})
+			todos, err := repo.GetAllTodosByUser(&types.User{ID: 1}, types.TodoFilter{})
 
 			// Assert
 			if err != nil {
@@ -95,11 +97,761 @@ func TestTodoRepo_GetAllTodosByUser(t *testing.T) {
 				t.Errorf("Expected todos to be nil")
 			}
 		})
+
+		// case 4
+		t.Run("should filter todos without due date", func(t *testing.T) {
+			rows := sqlmock.NewRows(todoColumnNames).
+				AddRow(1, "Test Todo", false, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, nil, 0, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1)
+
+			mock.ExpectQuery("^SELECT (.+) FROM todos (.+) AND t.due_at IS NULL ORDER BY ut.position ASC, t.id ASC$").WithArgs(1).WillReturnRows(rows)
+
+			repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+			// Act
+			todos, err := repo.GetAllTodosByUser(&types.User{ID: 1}, types.TodoFilter{Due: types.DueNone})
+
+			// Assert
+			if err != nil {
+				t.Errorf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			if len(todos) != 1 {
+				t.Errorf("Expected 1 todo, but got %d", len(todos))
+			}
+		})
+	})
+}
+
+func TestTodoRepo_GetTodosPageByUser(t *testing.T) {
+	t.Run("Test GetTodosPageByUser", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		filter := types.TodoFilter{SortBy: types.SortTitle}
+		var cursor string
+
+		// case 1
+		t.Run("should return a cursor if there are more todos", func(t *testing.T) {
+			rows := sqlmock.NewRows(todoColumnNames).
+				AddRow(1, "A", false, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, nil, 0, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1).
+				AddRow(2, "B", false, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, nil, 0, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1)
+
+			mock.ExpectQuery("^SELECT (.+) ORDER BY t.title ASC, t.id ASC LIMIT 2$").WithArgs(1).WillReturnRows(rows)
+			mock.ExpectQuery("^SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+			repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+			// Act
+			page, err := repo.GetTodosPageByUser(&types.User{ID: 1}, filter, types.PageRequest{Limit: 1, WithTotal: true})
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			if len(page.Items) != 1 || page.NextCursor == nil || page.Total == nil || *page.Total != 3 {
+				t.Fatalf("Expected 1 of 3 todos and a cursor, but got %+v", page)
+			}
+			cursor = *page.NextCursor
+		})
+
+		// case 2
+		t.Run("should continue behind the cursor", func(t *testing.T) {
+			rows := sqlmock.NewRows(todoColumnNames).
+				AddRow(2, "B", false, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, nil, 0, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1)
+
+			mock.ExpectQuery("^SELECT (.+) AND \\(t.title > \\? OR \\(t.title = \\? AND t.id > \\?\\)\\) ORDER BY").
+				WithArgs(1, "A", "A", 1).WillReturnRows(rows)
+
+			repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+			// Act
+			page, err := repo.GetTodosPageByUser(&types.User{ID: 1}, filter, types.PageRequest{Limit: 1, Cursor: cursor})
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			if len(page.Items) != 1 || page.NextCursor != nil || page.Total != nil {
+				t.Errorf("Expected the last todo without cursor, but got %+v", page)
+			}
+		})
+
+		// case 3
+		t.Run("should reject a cursor of another sort order", func(t *testing.T) {
+			repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+			// Act
+			_, err := repo.GetTodosPageByUser(&types.User{ID: 1}, types.TodoFilter{}, types.PageRequest{Limit: 1, Cursor: cursor})
+
+			// Assert
+			if !errors.Is(err, types.ErrInvalidCursor) {
+				t.Errorf("Expected an invalid cursor error, but got %v", err)
+			}
+		})
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestTodoRepo_todoConditions(t *testing.T) {
+	t.Run("should push filter and sorting into the query", func(t *testing.T) {
+		completed := false
+		categoryID := 0
+		filter := types.TodoFilter{
+			Completed:  &completed,
+			CategoryID: &categoryID,
+			Priorities: []string{types.PriorityHigh, types.PriorityUrgent},
+			Ownership:  types.OwnershipShared,
+			SortBy:     types.SortDueAt,
+			SortOrder:  types.SortDesc,
+		}
+
+		// Act
+		conditions, args, err := todoConditions(filter, &types.User{ID: 7}, time.Now())
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+		orderBy, err := todoOrderBy(filter)
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		// Assert
+		expectedConditions := "ut.user_id = ? AND t.deleted_at IS NULL AND t.workspace_id IS NULL AND ut.archived_at IS NULL AND t.completed = ? AND t.category_id IS NULL AND t.priority IN (?, ?) AND t.owner_id <> ?"
+		if conditions != expectedConditions {
+			t.Errorf("Expected conditions %s, but got %s", expectedConditions, conditions)
+		}
+
+		expectedArgs := []interface{}{7, false, 3, 4, 7}
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("Expected args %v, but got %v", expectedArgs, args)
+		}
+
+		expectedOrderBy := "t.due_at IS NULL, t.due_at DESC, t.id DESC"
+		if orderBy != expectedOrderBy {
+			t.Errorf("Expected order by %s, but got %s", expectedOrderBy, orderBy)
+		}
+	})
+
+	t.Run("should select archived todos by archive date", func(t *testing.T) {
+		filter := types.TodoFilter{Archived: true, SortBy: types.SortArchivedAt, SortOrder: types.SortDesc}
+
+		// Act
+		conditions, _, err := todoConditions(filter, &types.User{ID: 7}, time.Now())
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+		orderBy, err := todoOrderBy(filter)
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		// Assert
+		expectedConditions := "ut.user_id = ? AND t.deleted_at IS NULL AND t.workspace_id IS NULL AND ut.archived_at IS NOT NULL"
+		if conditions != expectedConditions {
+			t.Errorf("Expected conditions %s, but got %s", expectedConditions, conditions)
+		}
+
+		expectedOrderBy := "ut.archived_at DESC, t.id DESC"
+		if orderBy != expectedOrderBy {
+			t.Errorf("Expected order by %s, but got %s", expectedOrderBy, orderBy)
+		}
+	})
+
+	t.Run("should require all tags by default", func(t *testing.T) {
+		// Act
+		conditions, args, err := todoConditions(types.TodoFilter{Tags: []string{"home", "urgent"}}, &types.User{ID: 7}, time.Now())
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if !strings.Contains(conditions, "g.title IN (?, ?)) = ?") {
+			t.Errorf("Expected a tag count condition, but got %s", conditions)
+		}
+
+		expectedArgs := []interface{}{7, 7, "home", "urgent", 2}
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("Expected args %v, but got %v", expectedArgs, args)
+		}
+	})
+
+	t.Run("should require repeated tags only once", func(t *testing.T) {
+		// Act
+		_, args, err := todoConditions(types.TodoFilter{Tags: []string{"home", "Home", "home"}}, &types.User{ID: 7}, time.Now())
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		expectedArgs := []interface{}{7, 7, "home", 1}
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("Expected args %v, but got %v", expectedArgs, args)
+		}
+	})
+
+	t.Run("should accept any tag", func(t *testing.T) {
+		// Act
+		conditions, args, err := todoConditions(types.TodoFilter{Tags: []string{"home"}, TagMode: types.TagModeAny}, &types.User{ID: 7}, time.Now())
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if !strings.Contains(conditions, "g.title IN (?)) > 0") {
+			t.Errorf("Expected a tag condition, but got %s", conditions)
+		}
+
+		expectedArgs := []interface{}{7, 7, "home"}
+		if !reflect.DeepEqual(args, expectedArgs) {
+			t.Errorf("Expected args %v, but got %v", expectedArgs, args)
+		}
+	})
+
+	t.Run("should reject unknown priorities", func(t *testing.T) {
+		_, _, err := todoConditions(types.TodoFilter{Priorities: []string{"later"}}, &types.User{ID: 1}, time.Now())
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+}
+
+func TestTodoRepo_dueCondition(t *testing.T) {
+	// a wednesday
+	now := time.Date(2024, 5, 15, 13, 30, 0, 0, time.UTC)
+
+	t.Run("should use monday as start of the week", func(t *testing.T) {
+		_, args, err := dueCondition(types.DueThisWeek, now)
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		expectedStart := time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)
+		if args[0] != expectedStart || args[1] != expectedStart.AddDate(0, 0, 7) {
+			t.Errorf("Expected week from %s, but got %v", expectedStart, args)
+		}
+	})
+
+	t.Run("should use the calendar day of the client", func(t *testing.T) {
+		location, err := time.LoadLocation("Pacific/Auckland")
+		if err != nil {
+			t.Skipf("time zone data not available: %s", err.Error())
+		}
+
+		_, args, err := dueCondition(types.DueToday, now.In(location))
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		expectedDate := time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)
+		expectedStart := time.Date(2024, 5, 16, 0, 0, 0, 0, location).UTC()
+		if args[0] != expectedDate || args[2] != expectedStart {
+			t.Errorf("Expected today to be %s starting at %s, but got %v", expectedDate, expectedStart, args)
+		}
+	})
+
+	t.Run("should return an error for unknown modes", func(t *testing.T) {
+		_, _, err := dueCondition("tomorrow", now)
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+}
+
+func TestTodoRepo_dueRangeCondition(t *testing.T) {
+	t.Run("should compare due dates with a time with the day boundaries of the client", func(t *testing.T) {
+		location, err := time.LoadLocation("Europe/Berlin")
+		if err != nil {
+			t.Skipf("time zone data not available: %s", err.Error())
+		}
+
+		before := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+		_, args := dueRangeCondition(nil, &before, location)
+
+		expectedStart := time.Date(2024, 5, 31, 22, 0, 0, 0, time.UTC)
+		if len(args) != 2 || args[0] != before || args[1] != expectedStart {
+			t.Errorf("Expected the due dates before %s and %s, but got %v", before, expectedStart, args)
+		}
+	})
+}
+
+func TestTodoRepo_CreateTodo(t *testing.T) {
+	t.Run("Test CreateTodo", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		// case 1
+		t.Run("should create a new todo", func(t *testing.T) {
+			mock.ExpectExec("^INSERT INTO todos").WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec("^INSERT INTO user_todos").WillReturnResult(sqlmock.NewResult(1, 1))
+
+			repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+			// Act
+			date, _ := time.Parse("2006-01-02 15:04:05", "2022-01-01 00:00:00")
+			err := repo.CreateTodo(&types.Todo{
+				Title:     "Test Todo",
+				Completed: false,
+				CreatedAt: date,
+				OwnerID:   1,
+			})
+
+			// Assert
+			if err != nil {
+				t.Errorf("Expected error to be nil, but got %s", err.Error())
+			}
+		})
+
+		// case 2
+		t.Run("should return an error", func(t *testing.T) {
+			mock.ExpectExec("^INSERT INTO todos").WillReturnError(err)
+
+			repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+			// Act
+			date, _ := time.Parse("2006-01-02 15:04:05", "2022-01-01 00:00:00")
+			err := repo.CreateTodo(&types.Todo{
+				Title:     "Test Todo",
+				Completed: false,
+				CreatedAt: date,
+				OwnerID:   1,
+			})
+
+			// Assert
+			if err == nil {
+				t.Errorf("Expected an error, but got nil")
+			}
+		})
+	})
+}
+
+func TestTodoRepo_GetTodoTree(t *testing.T) {
+	t.Run("should nest subtasks below their parent", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT (.+) FROM todos (.+) WHERE t.id = ?").WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(todoColumnNames).
+				AddRow(1, "Parent", false, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, nil, 2, 1, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1))
+		mock.ExpectQuery("^SELECT (.+) FROM todos (.+) AND t.parent_id IN").WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(todoColumnNames).
+				AddRow(2, "Child", true, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, 1, 0, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1).
+				AddRow(3, "Child 2", false, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, 1, 1, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1))
+		mock.ExpectQuery("^SELECT (.+) FROM todos (.+) AND t.parent_id IN").WithArgs(1, 2, 3).
+			WillReturnRows(sqlmock.NewRows(todoColumnNames).
+				AddRow(4, "Grandchild", false, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, 3, 0, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1))
+		mock.ExpectQuery("^SELECT (.+) FROM todos (.+) AND t.parent_id IN").WithArgs(1, 4).
+			WillReturnRows(sqlmock.NewRows(todoColumnNames))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		todo, err := repo.GetTodoTree(1, &types.User{ID: 1})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if len(todo.Subtasks) != 2 || len(todo.Subtasks[1].Subtasks) != 1 {
+			t.Errorf("Expected a tree with 2 subtasks and 1 nested subtask, but got %+v", todo.Subtasks)
+		}
+
+		if todo.SubtaskCount != 2 || todo.SubtasksDone != 1 {
+			t.Errorf("Expected 1/2 subtasks done, but got %d/%d", todo.SubtasksDone, todo.SubtaskCount)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestTodoRepo_DeleteTodoById(t *testing.T) {
+	t.Run("should move the todo and its subtasks to the trash", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("^UPDATE todos SET deleted_at = \\? WHERE id = \\?").WithArgs(sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^UPDATE todos SET deleted_at = \\? WHERE deleted_at IS NULL AND id IN \\(2\\)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		err = repo.DeleteTodoById(&types.Todo{ID: 1}, &types.User{ID: 1})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestTodoRepo_RestoreTodo(t *testing.T) {
+	t.Run("should not restore a subtask while its parent is in the trash", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("^SELECT deleted_at, parent_id FROM todos").WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"deleted_at", "parent_id"}).AddRow("2022-01-01 00:00:00", 1))
+		mock.ExpectQuery("^SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		err = repo.RestoreTodo(&types.Todo{ID: 2}, &types.User{ID: 1})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestTodoRepo_PurgeTodo(t *testing.T) {
+	t.Run("should delete subtasks before their parent", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("^SELECT deleted_at, parent_id FROM todos").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"deleted_at", "parent_id"}).AddRow("2022-01-01 00:00:00", nil))
+		mock.ExpectQuery("^SELECT title FROM todos").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"title"}).AddRow("Old todo"))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("^DELETE FROM todo_tags").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM invitations").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM public_links").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM ownership_transfers").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM todo_assignees").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM comments").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM todo_events").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM todo_revisions").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM user_todos").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^DELETE FROM todos").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^DELETE FROM todo_tags").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM invitations").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM public_links").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM ownership_transfers").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM todo_assignees").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM comments").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM todo_events").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM todo_revisions").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM user_todos").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^DELETE FROM todos").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		todo := types.Todo{ID: 1}
+		err = repo.PurgeTodo(&todo, &types.User{ID: 1})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if todo.Title != "Old todo" {
+			t.Errorf("Expected the title Old todo to be kept, but got %s", todo.Title)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should only delete todos in the trash", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("^SELECT deleted_at, parent_id FROM todos").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"deleted_at", "parent_id"}).AddRow(nil, nil))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		err = repo.PurgeTodo(&types.Todo{ID: 1}, &types.User{ID: 1})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+}
+
+func TestTodoRepo_MoveTodo(t *testing.T) {
+	t.Run("should take away the access inherited from the previous parent", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT COUNT").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectExec("^UPDATE todos SET parent_id = \\?").WithArgs(nil, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("^DELETE FROM user_todos WHERE todo_id IN \\(\\?, \\?\\) AND via_parent_id IS NOT NULL "+
+			"AND via_parent_id NOT IN \\(\\?, \\?\\)").
+			WithArgs(2, 3, 2, 3).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("^DELETE a FROM todo_assignees a").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		err = repo.MoveTodo(&types.Todo{ID: 2}, nil, &types.User{ID: 1})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should only be allowed for the owner", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT COUNT").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		err = repo.MoveTodo(&types.Todo{ID: 2}, nil, &types.User{ID: 1})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestTodoRepo_RequireRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		role      string
+		required  string
+		expectErr bool
+	}{
+		{name: "should allow the required role", role: types.RoleEditor, required: types.RoleEditor},
+		{name: "should allow a higher role", role: types.RoleOwner, required: types.RoleManager},
+		{name: "should deny a lower role", role: types.RoleViewer, required: types.RoleEditor, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(1, 2).
+				WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(tt.role))
+
+			repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+			// Act
+			err = repo.RequireRole(2, &types.User{ID: 1}, tt.required)
+
+			// Assert
+			if tt.expectErr && err == nil {
+				t.Errorf("Expected an error, but got nil")
+			}
+
+			if !tt.expectErr && err != nil {
+				t.Errorf("Expected error to be nil, but got %s", err.Error())
+			}
+		})
+	}
+
+	t.Run("should deny users without access", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		err = repo.RequireRole(2, &types.User{ID: 1}, types.RoleViewer)
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+
+	t.Run("should only check todos of the active workspace", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		workspaceID := 4
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t (.+) AND t.workspace_id = \\?$").WithArgs(1, 2, workspaceID).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		err = repo.RequireRole(2, &types.User{ID: 1, WorkspaceID: &workspaceID}, types.RoleViewer)
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestTodoRepo_ReorderTodo(t *testing.T) {
+	t.Run("should place the todo between its new neighbours", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT (.+) FROM todos (.+) WHERE t.id = ?").WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(todoColumnNames).
+				AddRow(1, "Test Todo", false, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, nil, 0, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1))
+		mock.ExpectQuery("^SELECT ut.position, t.category_id").WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"position", "category_id"}).AddRow(2048.0, nil))
+		mock.ExpectQuery("^SELECT MIN\\(ut.position\\)").WithArgs(1, 1, 2048.0).
+			WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(3072.0))
+		mock.ExpectExec("^UPDATE user_todos SET position").WithArgs(2560.0, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+		afterID := 2
+
+		// Act
+		todo := types.Todo{ID: 1}
+		err = repo.ReorderTodo(&todo, &afterID, nil, &types.User{ID: 1})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if todo.Position != 2560 {
+			t.Errorf("Expected position 2560, but got %v", todo.Position)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should not place a todo after itself", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT (.+) FROM todos (.+) WHERE t.id = ?").WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(todoColumnNames).
+				AddRow(1, "Test Todo", false, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, nil, 0, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+		afterID := 1
+
+		// Act
+		err = repo.ReorderTodo(&types.Todo{ID: 1}, &afterID, nil, &types.User{ID: 1})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
 	})
 }
 
-func TestTodoRepo_CreateTodo(t *testing.T) {
-	t.Run("Test CreateTodo", func(t *testing.T) {
+func TestTodoRepo_SetCompleted(t *testing.T) {
+	t.Run("should keep the previous version as revision", func(t *testing.T) {
 		// Arrange
 		db, mock, err := sqlmock.New()
 		if err != nil {
@@ -107,48 +859,296 @@ func TestTodoRepo_CreateTodo(t *testing.T) {
 		}
 		defer db.Close()
 
-		// case 1
-		t.Run("should create a new todo", func(t *testing.T) {
-			mock.ExpectExec("^INSERT INTO todos").WillReturnResult(sqlmock.NewResult(1, 1))
-			mock.ExpectExec("^INSERT INTO user_todos").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("^SELECT (.+) FROM todos (.+) WHERE t.id = ?").WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(todoColumnNames).
+				AddRow(1, "Test Todo", false, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, nil, 0, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1))
+		mock.ExpectExec("^INSERT INTO todo_revisions").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("^UPDATE todos").WithArgs(true, true, 1).WillReturnResult(sqlmock.NewResult(0, 1))
 
-			repo := NewTodoRepo(db, &mockCategoryRepo{})
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
 
-			// Act
-			date, _ := time.Parse("2006-01-02 15:04:05", "2022-01-01 00:00:00")
-			err := repo.CreateTodo(&types.Todo{
-				Title:     "Test Todo",
-				Completed: false,
-				CreatedAt: date,
-				OwnerID:   1,
-			})
+		// Act
+		todo := types.Todo{ID: 1}
+		err = repo.SetCompleted(&todo, true, &types.User{ID: 1})
 
-			// Assert
-			if err != nil {
-				t.Errorf("Expected error to be nil, but got %s", err.Error())
-			}
-		})
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
 
-		// case 2
-		t.Run("should return an error", func(t *testing.T) {
-			mock.ExpectExec("^INSERT INTO todos").WillReturnError(err)
+		if !todo.Completed || todo.Version != 2 {
+			t.Errorf("Expected a completed todo in version 2, but got %v in version %d", todo.Completed, todo.Version)
+		}
 
-			repo := NewTodoRepo(db, &mockCategoryRepo{})
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
 
-			// Act
-			date, _ := time.Parse("2006-01-02 15:04:05", "2022-01-01 00:00:00")
-			err := repo.CreateTodo(&types.Todo{
-				Title:     "Test Todo",
-				Completed: false,
-				CreatedAt: date,
-				OwnerID:   1,
-			})
+func TestTodoRepo_ArchiveTodo(t *testing.T) {
+	t.Run("should only archive the todo in the list of the user", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
 
-			// Assert
-			if err == nil {
-				t.Errorf("Expected an error, but got nil")
-			}
-		})
+		mock.ExpectQuery("^SELECT (.+) FROM todos (.+) WHERE t.id = ?").WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows(todoColumnNames).
+				AddRow(1, "Test Todo", true, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, nil, 0, 0, 0, "", 1024.0, nil, nil, nil, "viewer", nil, nil, 1))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("^UPDATE user_todos SET archived_at = \\? WHERE user_id = \\? AND archived_at IS NULL AND todo_id IN \\(1\\)$").
+			WithArgs(sqlmock.AnyArg(), 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		err = repo.ArchiveTodo(&types.Todo{ID: 1}, &types.User{ID: 2})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestTodoRepo_CreateNextOccurrence(t *testing.T) {
+	t.Run("should not spawn a second follow-up of the same todo", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT next_occurrence_id FROM todos WHERE id = \\? FOR UPDATE").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"next_occurrence_id"}).AddRow(2))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		err = repo.CreateNextOccurrence(&types.Todo{Title: "Test Todo"}, &types.Todo{ID: 1})
+
+		// Assert
+		if !errors.Is(err, types.ErrOccurrenceExists) {
+			t.Errorf("Expected error %v, but got %v", types.ErrOccurrenceExists, err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestTodoRepo_UpdateTodoByIdConflict(t *testing.T) {
+	t.Run("should reject updates of an outdated version", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleEditor))
+		mock.ExpectQuery("^SELECT category_id, workspace_id, version FROM todos").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"category_id", "workspace_id", "version"}).AddRow(nil, nil, 3))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		err = repo.UpdateTodoById(&types.Todo{ID: 1, Title: "Todo", Priority: types.PriorityNone, Version: 2},
+			&types.User{ID: 1})
+
+		// Assert
+		if !errors.Is(err, types.ErrVersionConflict) {
+			t.Errorf("Expected a version conflict, but got %v", err)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestTodoRepo_UpdateTodoByIdSharedCategory(t *testing.T) {
+	t.Run("should keep the category when a direct editor who is no member of it updates the todo", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		// user 2 was invited to the todo, the category belongs to user 1
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleEditor))
+		mock.ExpectQuery("^SELECT category_id, workspace_id, version FROM todos").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"category_id", "workspace_id", "version"}).AddRow(5, nil, 3))
+		mock.ExpectExec("^INSERT INTO todo_revisions").WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("^UPDATE todos SET title = \\?, completed_at = (.+), completed = \\?, due_at = \\?").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+		todo := types.Todo{ID: 1, Title: "Todo", Completed: true, Priority: types.PriorityNone,
+			Category: types.Category{ID: 5, Title: "Test Category", CreatedUserId: 2}}
+
+		// Act
+		err = repo.UpdateTodoById(&todo, &types.User{ID: 2})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if todo.Category.ID != 5 || todo.Category.CreatedUserId != 1 {
+			t.Errorf("Expected the todo to stay in category 5 of user 1, but got %+v", todo.Category)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should only let managers move the todo into a category", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleEditor))
+		mock.ExpectQuery("^SELECT category_id, workspace_id, version FROM todos").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"category_id", "workspace_id", "version"}).AddRow(nil, nil, 3))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+		todo := types.Todo{ID: 1, Title: "Todo", Priority: types.PriorityNone,
+			Category: types.Category{Title: "Mine", CreatedUserId: 2}}
+
+		// Act
+		err = repo.UpdateTodoById(&todo, &types.User{ID: 2})
+
+		// Assert
+		if err == nil || err.Error() != errRoleRequired(types.RoleManager).Error() {
+			t.Errorf("Expected error %v, but got %v", errRoleRequired(types.RoleManager), err)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestTodoRepo_RevertTodo(t *testing.T) {
+	t.Run("should not revert to a revision of another todo", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT (.+) FROM todos (.+) WHERE t.id = ?").WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(todoColumnNames).
+				AddRow(1, "Todo", false, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, nil, 0, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 1))
+		mock.ExpectQuery("^SELECT (.+) FROM todo_revisions r").WithArgs(9, 1).
+			WillReturnRows(sqlmock.NewRows(revisionColumnNames))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		err = repo.RevertTodo(&types.Todo{ID: 1}, 9, &types.User{ID: 1})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should not revert an outdated version", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT (.+) FROM todos (.+) WHERE t.id = ?").WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(todoColumnNames).
+				AddRow(1, "Todo", false, "2022-01-01 00:00:00", 1, nil, nil, false, nil, false, "", 1, nil, 0, 0, 0, "", 1024.0, nil, nil, nil, "owner", nil, nil, 3))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		err = repo.RevertTodo(&types.Todo{ID: 1, Version: 2}, 9, &types.User{ID: 1})
+
+		// Assert
+		if !errors.Is(err, types.ErrVersionConflict) {
+			t.Errorf("Expected error %v, but got %v", types.ErrVersionConflict, err)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestTodoRepo_GetTodoRevisions(t *testing.T) {
+	t.Run("should read the previous versions of the todo", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleViewer))
+		mock.ExpectQuery("^SELECT (.+) FROM todo_revisions r").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(revisionColumnNames).
+				AddRow(4, 1, "Old title", true, 2, "Work", "2022-01-03 00:00:00", false, nil, false, "", 3, "", "anna", "2022-01-02 10:00:00"))
+
+		repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+
+		// Act
+		revisions, err := repo.GetTodoRevisions(1, &types.User{ID: 1})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if len(revisions) != 1 {
+			t.Fatalf("Expected 1 revision, but got %d", len(revisions))
+		}
+
+		revision := revisions[0]
+		if revision.Title != "Old title" || !revision.Completed || revision.Priority != types.PriorityHigh ||
+			revision.ReplacedBy != "anna" {
+			t.Errorf("Expected the old version replaced by anna, but got %+v", revision)
+		}
+
+		if revision.CategoryID == nil || *revision.CategoryID != 2 || revision.DueAt == nil {
+			t.Errorf("Expected category 2 and a due date, but got %+v", revision)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
 	})
 }
 
@@ -166,7 +1166,7 @@ func TestTodoRepo_CreateTodo(t *testing.T) {
 //			mock.ExpectExec("^UPDATE todos").WillReturnResult(sqlmock.NewResult(1, 1))
 //			mock.ExpectQuery("^SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 //
-//			repo := NewTodoRepo(db, &mockCategoryRepo{})
+//			repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
 //
 //			// Act
 //			date, _ := time.Parse("2006-01-02 15:04:05", "2022-01-01 00:00:00")
@@ -188,7 +1188,7 @@ func TestTodoRepo_CreateTodo(t *testing.T) {
 //		t.Run("should return an error", func(t *testing.T) {
 //			mock.ExpectExec("^UPDATE todos").WillReturnError(err)
 //
-//			repo := NewTodoRepo(db, &mockCategoryRepo{})
+//			repo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
 //
 //			// Act
 //			date, _ := time.Parse("2006-01-02 15:04:05", "2022-01-01 00:00:00")
@@ -208,6 +1208,15 @@ func TestTodoRepo_CreateTodo(t *testing.T) {
 //	})
 //}
 
+var todoColumnNames = []string{"id", "title", "completed", "created_at", "owner_id", "category_id",
+	"due_at", "due_has_time", "start_at", "start_has_time", "recurrence_rule", "occurrence",
+	"parent_id", "subtask_count", "subtasks_done", "priority", "description", "position", "deleted_at", "completed_at", "archived_at",
+	"role", "workspace_id", "assignees", "version"}
+
+var revisionColumnNames = []string{"id", "todo_id", "title", "completed", "category_id", "category_title", "due_at",
+	"due_has_time", "start_at", "start_has_time", "recurrence_rule", "priority", "description", "username",
+	"replaced_at"}
+
 type mockCategoryRepo struct{}
 
 func (m *mockCategoryRepo) GetCategoryByID(id int) (*types.Category, error) {
@@ -218,10 +1227,88 @@ func (m *mockCategoryRepo) GetCategoryFromDB(category *types.Category) (*types.C
 	return &types.Category{ID: 1, Title: "Test Category", CreatedUserId: 1}, nil
 }
 
-func (m *mockCategoryRepo) GetCategoriesByUserId(userID int) ([]types.Category, error) {
+func (m *mockCategoryRepo) GetCategoriesByUserId(userID int, workspaceID *int) ([]types.Category, error) {
 	return []types.Category{{ID: 1, Title: "Test Category", CreatedUserId: 1}}, nil
 }
 
+func (m *mockCategoryRepo) GetCategoriesPageByUserId(userID int, workspaceID *int, page types.PageRequest) (*types.Page[types.Category], error) {
+	return &types.Page[types.Category]{Items: []types.Category{{ID: 1, Title: "Test Category", CreatedUserId: 1}}}, nil
+}
+
 func (m *mockCategoryRepo) UpsertCategory(category *types.Category) error {
 	return nil
 }
+
+func (m *mockCategoryRepo) CategoryRole(categoryID int, user *types.User) (string, error) {
+	if user.ID == 1 {
+		return types.RoleOwner, nil
+	}
+
+	return "", nil
+}
+
+func (m *mockCategoryRepo) GetCategoryMembers(categoryID int, user *types.User) ([]types.Collaborator, error) {
+	return []types.Collaborator{}, nil
+}
+
+func (m *mockCategoryRepo) SetCategoryMember(categoryID int, user *types.User, member *types.User, role string) error {
+	return nil
+}
+
+func (m *mockCategoryRepo) RemoveCategoryMember(categoryID int, user *types.User, member *types.User) error {
+	return nil
+}
+
+func (m *mockCategoryRepo) InviteCategoryMember(categoryID int, user *types.User, invitee *types.User, role string) error {
+	return nil
+}
+
+func (m *mockCategoryRepo) GetCategoryInvitationsByUser(user *types.User) ([]types.CategoryInvitation, error) {
+	return []types.CategoryInvitation{}, nil
+}
+
+func (m *mockCategoryRepo) AcceptCategoryInvitation(invitationID int, user *types.User) error {
+	return nil
+}
+
+func (m *mockCategoryRepo) DeclineCategoryInvitation(invitationID int, user *types.User) error {
+	return nil
+}
+
+func (m *mockCategoryRepo) GetCategoryTodoIds(categoryID int) ([]int, error) {
+	return []int{}, nil
+}
+
+type mockTagRepo struct{}
+
+func (m *mockTagRepo) CreateTag(tag *types.Tag) error {
+	return nil
+}
+
+func (m *mockTagRepo) GetTagsByUserId(userID int) ([]types.Tag, error) {
+	return []types.Tag{}, nil
+}
+
+func (m *mockTagRepo) GetTagsByTodoIds(todoIDs []int, userID int) (map[int][]types.Tag, error) {
+	return map[int][]types.Tag{}, nil
+}
+
+func (m *mockTagRepo) RenameTag(tag *types.Tag, user *types.User) error {
+	return nil
+}
+
+func (m *mockTagRepo) MergeTags(source *types.Tag, target *types.Tag, user *types.User) error {
+	return nil
+}
+
+func (m *mockTagRepo) DeleteTag(tag *types.Tag, user *types.User) error {
+	return nil
+}
+
+func (m *mockTagRepo) AttachTag(todoID int, tag *types.Tag, user *types.User) error {
+	return nil
+}
+
+func (m *mockTagRepo) DetachTag(todoID int, tag *types.Tag, user *types.User) error {
+	return nil
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"database/sql"
+	"github.com/floxo05/todoapi/internal/types"
+	"strconv"
+)
+
+// dbtx is implemented by *sql.DB and *sql.Tx, so the repositories also work inside a transaction
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type TransactionManager struct {
+	db          *sql.DB
+	searchIndex types.SearchIndex
+}
+
+func NewTransactionManager(db *sql.DB, searchIndex types.SearchIndex) *TransactionManager {
+	return &TransactionManager{db: db, searchIndex: searchIndex}
+}
+
+// WithTransaction runs fn with repositories bound to a new transaction.
+// The search index is not part of the database transaction, its changes are applied after the commit.
+func (m *TransactionManager) WithTransaction(fn func(tx types.Transaction) error) error {
+	sqlTx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	index := &pendingIndex{searchIndex: m.searchIndex}
+	tagRepo := &TagRepo{db: sqlTx}
+	categoryRepo := &CategoryRepo{db: sqlTx}
+	todoRepo := &TodoRepo{db: sqlTx, categoryRepo: categoryRepo, tagRepo: tagRepo, searchIndex: index}
+	userRepo := &UserRepo{db: sqlTx, todoRepo: todoRepo}
+	invitationRepo := &InvitationRepo{db: sqlTx, todoRepo: todoRepo, userRepo: userRepo}
+	ownershipRepo := &OwnershipRepo{db: sqlTx, todoRepo: todoRepo}
+	commentRepo := &CommentRepo{db: sqlTx, todoRepo: todoRepo, userRepo: userRepo}
+	activityRepo := &ActivityRepo{db: sqlTx, todoRepo: todoRepo}
+
+	tx := &transaction{tx: sqlTx, todoRepo: todoRepo, tagRepo: tagRepo, userRepo: userRepo,
+		invitationRepo: invitationRepo, ownershipRepo: ownershipRepo, categoryRepo: categoryRepo,
+		commentRepo: commentRepo, activityRepo: activityRepo, index: index}
+	err = fn(tx)
+	if err != nil {
+		rollbackErr := sqlTx.Rollback()
+		if rollbackErr != nil {
+			return rollbackErr
+		}
+
+		return err
+	}
+
+	err = sqlTx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return index.apply()
+}
+
+type transaction struct {
+	tx             *sql.Tx
+	todoRepo       *TodoRepo
+	tagRepo        *TagRepo
+	userRepo       *UserRepo
+	invitationRepo *InvitationRepo
+	ownershipRepo  *OwnershipRepo
+	categoryRepo   *CategoryRepo
+	commentRepo    *CommentRepo
+	activityRepo   *ActivityRepo
+	index          *pendingIndex
+	savepoints     int
+}
+
+func (t *transaction) Todos() types.TodoRepository {
+	return t.todoRepo
+}
+
+func (t *transaction) Tags() types.TagRepository {
+	return t.tagRepo
+}
+
+func (t *transaction) Users() types.UserRepository {
+	return t.userRepo
+}
+
+func (t *transaction) Invitations() types.InvitationRepository {
+	return t.invitationRepo
+}
+
+func (t *transaction) Ownership() types.OwnershipRepository {
+	return t.ownershipRepo
+}
+
+func (t *transaction) Categories() types.CategoryRepository {
+	return t.categoryRepo
+}
+
+func (t *transaction) Comments() types.CommentRepository {
+	return t.commentRepo
+}
+
+func (t *transaction) Activity() types.ActivityRepository {
+	return t.activityRepo
+}
+
+func (t *transaction) Savepoint(fn func() error) error {
+	t.savepoints++
+	name := "savepoint_" + strconv.Itoa(t.savepoints)
+
+	_, err := t.tx.Exec("SAVEPOINT " + name)
+	if err != nil {
+		return err
+	}
+
+	indexChanges := len(t.index.changes)
+	err = fn()
+	if err != nil {
+		_, rollbackErr := t.tx.Exec("ROLLBACK TO SAVEPOINT " + name)
+		if rollbackErr != nil {
+			return rollbackErr
+		}
+		t.index.changes = t.index.changes[:indexChanges]
+
+		return err
+	}
+
+	_, err = t.tx.Exec("RELEASE SAVEPOINT " + name)
+
+	return err
+}
+
+// pendingIndex collects the changes to the search index during a transaction, searches go to the real index
+type pendingIndex struct {
+	searchIndex types.SearchIndex
+	changes     []func(index types.SearchIndex) error
+}
+
+func (p *pendingIndex) IndexTodo(document types.SearchDocument) error {
+	p.changes = append(p.changes, func(index types.SearchIndex) error {
+		return index.IndexTodo(document)
+	})
+
+	return nil
+}
+
+func (p *pendingIndex) RemoveTodo(todoID int) error {
+	p.changes = append(p.changes, func(index types.SearchIndex) error {
+		return index.RemoveTodo(todoID)
+	})
+
+	return nil
+}
+
+func (p *pendingIndex) SearchTodos(user *types.User, words []string, phrases []string, limit int) ([]int, error) {
+	return p.searchIndex.SearchTodos(user, words, phrases, limit)
+}
+
+func (p *pendingIndex) apply() error {
+	for _, change := range p.changes {
+		err := change(p.searchIndex)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/floxo05/todoapi/internal/types"
+	"testing"
+)
+
+func TestTransactionManager_WithTransaction(t *testing.T) {
+	t.Run("should undo a failed savepoint and index the rest after the commit", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("^SAVEPOINT savepoint_1$").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^ROLLBACK TO SAVEPOINT savepoint_1$").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^SAVEPOINT savepoint_2$").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^RELEASE SAVEPOINT savepoint_2$").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		searchIndex := NewMemorySearchIndex()
+		manager := NewTransactionManager(db, searchIndex)
+
+		// Act
+		var savepointErr error
+		err = manager.WithTransaction(func(tx types.Transaction) error {
+			index := tx.(*transaction).index
+			savepointErr = tx.Savepoint(func() error {
+				_ = index.IndexTodo(types.SearchDocument{TodoID: 1, Title: "failed"})
+				return errors.New("error")
+			})
+
+			return tx.Savepoint(func() error {
+				return index.IndexTodo(types.SearchDocument{TodoID: 2, Title: "kept"})
+			})
+		})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if savepointErr == nil {
+			t.Errorf("Expected the first savepoint to fail, but got nil")
+		}
+
+		ids, _ := searchIndex.SearchTodos(&types.User{ID: 1}, []string{"failed"}, nil, 10)
+		if len(ids) != 0 {
+			t.Errorf("Expected the failed change to be dropped, but got %v", ids)
+		}
+
+		ids, _ = searchIndex.SearchTodos(&types.User{ID: 1}, []string{"kept"}, nil, 10)
+		if len(ids) != 1 || ids[0] != 2 {
+			t.Errorf("Expected todo 2 to be indexed, but got %v", ids)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should roll back if the function fails", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		searchIndex := NewMemorySearchIndex()
+		manager := NewTransactionManager(db, searchIndex)
+
+		// Act
+		err = manager.WithTransaction(func(tx types.Transaction) error {
+			_ = tx.(*transaction).index.IndexTodo(types.SearchDocument{TodoID: 1, Title: "rolled back"})
+			return errors.New("error")
+		})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		ids, _ := searchIndex.SearchTodos(&types.User{ID: 1}, []string{"rolled"}, nil, 10)
+		if len(ids) != 0 {
+			t.Errorf("Expected nothing to be indexed, but got %v", ids)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestTransactionManager_PurgeTodo(t *testing.T) {
+	t.Run("should keep the todo searchable if the purge fails", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("^SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("^SELECT deleted_at, parent_id FROM todos").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"deleted_at", "parent_id"}).AddRow("2022-01-01 00:00:00", nil))
+		mock.ExpectQuery("^SELECT title FROM todos").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"title"}).AddRow("Old todo"))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("^DELETE FROM todo_tags").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM invitations").WithArgs(2).WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+
+		searchIndex := NewMemorySearchIndex()
+		_ = searchIndex.IndexTodo(types.SearchDocument{TodoID: 2, Title: "subtask"})
+		manager := NewTransactionManager(db, searchIndex)
+
+		// Act
+		err = manager.WithTransaction(func(tx types.Transaction) error {
+			return tx.Todos().PurgeTodo(&types.Todo{ID: 1}, &types.User{ID: 1})
+		})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		ids, _ := searchIndex.SearchTodos(&types.User{ID: 1}, []string{"subtask"}, nil, 10)
+		if len(ids) != 1 || ids[0] != 2 {
+			t.Errorf("Expected todo 2 to stay in the index, but got %v", ids)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
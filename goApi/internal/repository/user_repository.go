@@ -7,7 +7,7 @@ import (
 )
 
 type UserRepo struct {
-	db       *sql.DB
+	db       dbtx
 	todoRepo types.TodoRepository
 }
 
@@ -17,11 +17,18 @@ func NewUserRepo(db *sql.DB, repo types.TodoRepository) *UserRepo {
 
 func (u *UserRepo) GetUserByUsername(username string) (*types.User, error) {
 	var user types.User
-	err := u.db.QueryRow("SELECT id, username, password FROM users WHERE username = ?", username).Scan(&user.ID, &user.Username, &user.Password)
+	var autoArchiveDays sql.NullInt64
+	err := u.db.QueryRow("SELECT id, username, password, auto_archive_days FROM users WHERE username = ?", username).
+		Scan(&user.ID, &user.Username, &user.Password, &autoArchiveDays)
 	if err != nil {
 		return &types.User{}, err
 	}
 
+	if autoArchiveDays.Valid {
+		days := int(autoArchiveDays.Int64)
+		user.AutoArchiveDays = &days
+	}
+
 	return &user, nil
 }
 
@@ -35,21 +42,249 @@ func (u *UserRepo) CreateUser(user *types.User) error {
 	return nil
 }
 
-func (u *UserRepo) ShareTodoWithUser(todoID int, user *types.User, shareUser *types.User) error {
+// ShareTodoWithUser gives another user the role on the todo and its subtasks, see requireGrant for who may do this
+func (u *UserRepo) ShareTodoWithUser(todoID int, user *types.User, shareUser *types.User, role string) error {
+	err := requireGrant(u.todoRepo, todoID, user, role)
+	if err != nil {
+		return err
+	}
+
+	// subtasks are shared together with their parent
 	todo := types.Todo{ID: todoID}
-	isOwner, err := u.todoRepo.IsOwner(&todo, user)
+	subtaskIds, err := u.todoRepo.GetSubtaskIds(&todo)
+	if err != nil {
+		return err
+	}
+
+	// someone who already has access, e.g. through a category, keeps the higher role
+	_, err = u.db.Exec("INSERT INTO user_todos (todo_id, user_id, role, position) "+appendPositionSelect+" "+keepHigherRole,
+		appendPositionArgs(todoID, shareUser.ID, role)...)
 	if err != nil {
 		return err
 	}
 
-	if !isOwner {
-		return errors.New("user does not have access to share the todo")
+	// the subtasks inherit the access, it goes away again if they are moved to another parent
+	for _, id := range subtaskIds {
+		_, err = u.db.Exec(`
+			INSERT INTO user_todos (todo_id, user_id, role, position, via_parent_id)
+			SELECT ?, ?, ?, COALESCE(MAX(position), 0) + ?, ? FROM user_todos WHERE user_id = ? `+keepHigherRole,
+			id, shareUser.ID, role, positionGap, todoID, shareUser.ID)
+		if err != nil {
+			return err
+		}
 	}
 
-	_, err = u.db.Exec("INSERT INTO user_todos (todo_id, user_id) VALUES (?, ?)", todoID, shareUser.ID)
+	return nil
+}
+
+// SetCollaboratorRole changes the role of a collaborator on the todo and its subtasks, only the owner can do this.
+// The role is granted directly afterwards, it does not depend on a category or workspace anymore.
+func (u *UserRepo) SetCollaboratorRole(todoID int, user *types.User, collaborator *types.User, role string) error {
+	err := u.todoRepo.RequireRole(todoID, user, types.RoleOwner)
 	if err != nil {
 		return err
 	}
 
+	if role == types.RoleOwner || roleRank(role) < 0 {
+		return errors.New("role can not be granted")
+	}
+
+	var currentRole string
+	err = u.db.QueryRow("SELECT role FROM user_todos WHERE todo_id = ? AND user_id = ?", todoID, collaborator.ID).
+		Scan(&currentRole)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("user is not a collaborator of the todo")
+	}
+	if err != nil {
+		return err
+	}
+
+	if currentRole == types.RoleOwner {
+		return errors.New("the role of the owner can not be changed")
+	}
+
+	todo := types.Todo{ID: todoID}
+	subtaskIds, err := u.todoRepo.GetSubtaskIds(&todo)
+	if err != nil {
+		return err
+	}
+
+	todoIds := append([]int{todoID}, subtaskIds...)
+	args := []interface{}{role, todoID, todoID, collaborator.ID, types.RoleOwner}
+	for _, id := range todoIds {
+		args = append(args, id)
+	}
+
+	// the subtasks keep the access through the todo, like after sharing it
+	_, err = u.db.Exec(`
+		UPDATE user_todos
+		SET role = ?, via_category_id = NULL, via_workspace_id = NULL, via_parent_id = IF(todo_id = ?, NULL, ?)
+		WHERE user_id = ? AND role <> ? AND todo_id IN (`+placeholders(len(todoIds))+")", args...)
+	if err != nil {
+		return err
+	}
+
+	return unassignWithoutAccess(u.db, todoIds)
+}
+
+// GetCollaborators returns all users with access to the todo, every collaborator can see the others
+func (u *UserRepo) GetCollaborators(todoID int, user *types.User) ([]types.Collaborator, error) {
+	err := u.todoRepo.RequireRole(todoID, user, types.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := u.db.Query(`
+		SELECT u.username, ut.role
+		FROM user_todos ut
+		JOIN users u ON u.id = ut.user_id
+		WHERE ut.todo_id = ?
+		ORDER BY ut.role = ? DESC, u.username`, todoID, types.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collaborators := []types.Collaborator{}
+	for rows.Next() {
+		var collaborator types.Collaborator
+		err = rows.Scan(&collaborator.Username, &collaborator.Role)
+		if err != nil {
+			return nil, err
+		}
+
+		collaborators = append(collaborators, collaborator)
+	}
+
+	return collaborators, rows.Err()
+}
+
+// RemoveCollaborator takes the access to the todo and its subtasks away from a collaborator, only the owner can do this
+func (u *UserRepo) RemoveCollaborator(todoID int, user *types.User, collaborator *types.User) error {
+	err := u.todoRepo.RequireRole(todoID, user, types.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	if collaborator.ID == user.ID {
+		return errors.New("the owner can not be removed from the todo")
+	}
+
+	return u.revokeAccess(todoID, collaborator)
+}
+
+// LeaveTodo removes a todo that was shared with the user from their list, the owner can not leave
+func (u *UserRepo) LeaveTodo(todoID int, user *types.User) error {
+	workspace, workspaceArgs := workspaceCondition("t.workspace_id", user)
+	args := append([]interface{}{todoID, user.ID}, workspaceArgs...)
+
+	var role string
+	err := u.db.QueryRow("SELECT ut.role FROM user_todos ut JOIN todos t ON t.id = ut.todo_id "+
+		"WHERE ut.todo_id = ? AND ut.user_id = ? AND "+workspace, args...).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("user does not have access to the todo")
+	}
+	if err != nil {
+		return err
+	}
+
+	if role == types.RoleOwner {
+		return errors.New("the owner can not leave the todo")
+	}
+
+	return u.revokeAccess(todoID, user)
+}
+
+// AssignTodo makes a collaborator responsible for the todo, editors can assign everyone with at least editor access
+func (u *UserRepo) AssignTodo(todoID int, user *types.User, assignee *types.User) error {
+	err := u.todoRepo.RequireRole(todoID, user, types.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	var role string
+	err = u.db.QueryRow("SELECT role FROM user_todos WHERE todo_id = ? AND user_id = ?", todoID, assignee.ID).Scan(&role)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if !hasRole(role, types.RoleEditor) {
+		return errors.New("assignees need to be at least editor of the todo")
+	}
+
+	_, err = u.db.Exec("INSERT IGNORE INTO todo_assignees (todo_id, user_id) VALUES (?, ?)", todoID, assignee.ID)
+
+	return err
+}
+
+func (u *UserRepo) UnassignTodo(todoID int, user *types.User, assignee *types.User) error {
+	err := u.todoRepo.RequireRole(todoID, user, types.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	result, err := u.db.Exec("DELETE FROM todo_assignees WHERE todo_id = ? AND user_id = ?", todoID, assignee.ID)
+	if err != nil {
+		return err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if removed == 0 {
+		return errors.New("user is not assigned to the todo")
+	}
+
+	return nil
+}
+
+// revokeAccess deletes the rows of the user for the todo and its subtasks, together with the tags the user put on them
+func (u *UserRepo) revokeAccess(todoID int, collaborator *types.User) error {
+	todo := types.Todo{ID: todoID}
+	subtaskIds, err := u.todoRepo.GetSubtaskIds(&todo)
+	if err != nil {
+		return err
+	}
+
+	todoIds := append([]int{todoID}, subtaskIds...)
+	args := []interface{}{collaborator.ID, types.RoleOwner}
+	for _, id := range todoIds {
+		args = append(args, id)
+	}
+
+	result, err := u.db.Exec("DELETE FROM user_todos WHERE user_id = ? AND role <> ? AND todo_id IN ("+
+		placeholders(len(todoIds))+")", args...)
+	if err != nil {
+		return err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if removed == 0 {
+		return errors.New("user is not a collaborator of the todo")
+	}
+
+	err = deleteUserTags(u.db, collaborator.ID, todoIds)
+	if err != nil {
+		return err
+	}
+
+	return unassignWithoutAccess(u.db, todoIds)
+}
+
+// SetAutoArchiveDays changes after how many days completed todos of the user are archived, nil turns it off
+func (u *UserRepo) SetAutoArchiveDays(user *types.User, days *int) error {
+	_, err := u.db.Exec("UPDATE users SET auto_archive_days = ? WHERE id = ?", days, user.ID)
+	if err != nil {
+		return err
+	}
+
+	user.AutoArchiveDays = days
+
 	return nil
 }
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/floxo05/todoapi/internal/types"
+	"testing"
+)
+
+func TestUserRepo_AssignTodo(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       string
+		wantAssign bool
+	}{
+		{name: "should assign editors", role: types.RoleEditor, wantAssign: true},
+		{name: "should assign the owner", role: types.RoleOwner, wantAssign: true},
+		{name: "should not assign viewers", role: types.RoleViewer, wantAssign: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(1, 5).
+				WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleEditor))
+			mock.ExpectQuery("^SELECT role FROM user_todos").WithArgs(5, 2).
+				WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(tt.role))
+			if tt.wantAssign {
+				mock.ExpectExec("^INSERT IGNORE INTO todo_assignees").WithArgs(5, 2).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			todoRepo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+			repo := NewUserRepo(db, todoRepo)
+
+			// Act
+			err = repo.AssignTodo(5, &types.User{ID: 1}, &types.User{ID: 2})
+
+			// Assert
+			if tt.wantAssign && err != nil {
+				t.Errorf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			if !tt.wantAssign && err == nil {
+				t.Errorf("Expected an error, but got nil")
+			}
+
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestUserRepo_SetCollaboratorRole(t *testing.T) {
+	t.Run("should grant the role directly", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT ut.role FROM user_todos ut JOIN todos t").WithArgs(1, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleOwner))
+		mock.ExpectQuery("^SELECT role FROM user_todos").WithArgs(5, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.RoleViewer))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE parent_id IN").WithArgs(6).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("UPDATE user_todos\\s+SET role = \\?, via_category_id = NULL, via_workspace_id = NULL").
+			WithArgs(types.RoleEditor, 5, 5, 2, types.RoleOwner, 5, 6).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("^DELETE a FROM todo_assignees a").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		todoRepo := NewTodoRepo(db, &mockCategoryRepo{}, &mockTagRepo{}, NewMemorySearchIndex())
+		repo := NewUserRepo(db, todoRepo)
+
+		// Act
+		err = repo.SetCollaboratorRole(5, &types.User{ID: 1}, &types.User{ID: 2}, types.RoleEditor)
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
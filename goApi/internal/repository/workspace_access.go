@@ -0,0 +1,67 @@
+package repository
+
+import "github.com/floxo05/todoapi/internal/types"
+
+// Every query works in the active workspace of the user, see workspaceCondition. Members of a workspace who are no
+// guests get a user_todos row for every todo of the workspace, marked with via_workspace_id like the access through
+// shared categories.
+
+// workspaceCondition limits a query to the rows of the active workspace of the user, without workspace to the
+// rows of the personal space
+func workspaceCondition(column string, user *types.User) (string, []interface{}) {
+	if user.WorkspaceID == nil {
+		return column + " IS NULL", nil
+	}
+
+	return column + " = ?", []interface{}{*user.WorkspaceID}
+}
+
+// sameWorkspace reports whether two workspace ids are equal, nil stands for the personal space
+func sameWorkspace(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return *a == *b
+}
+
+// grantWorkspaceAccess gives the members of the workspace access to the todos with the role of their membership
+func grantWorkspaceAccess(db dbtx, workspaceID int, todoIds []int) error {
+	for _, todoID := range todoIds {
+		_, err := db.Exec(`
+			INSERT IGNORE INTO user_todos (user_id, todo_id, role, position, via_workspace_id)
+			SELECT m.user_id, ?, IF(m.role IN (?, ?), ?, ?),
+			       (SELECT COALESCE(MAX(p.position), 0) + ? FROM user_todos p WHERE p.user_id = m.user_id), m.workspace_id
+			FROM workspace_members m
+			WHERE m.workspace_id = ? AND m.role <> ?`,
+			todoID, types.WorkspaceOwner, types.WorkspaceAdmin, types.RoleManager, types.RoleEditor, positionGap,
+			workspaceID, types.WorkspaceGuest)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// workspaceTodoIds returns the ids of all todos of the workspace
+func workspaceTodoIds(db dbtx, workspaceID int) ([]int, error) {
+	rows, err := db.Query("SELECT id FROM todos WHERE workspace_id = ?", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
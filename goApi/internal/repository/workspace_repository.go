@@ -0,0 +1,462 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/floxo05/todoapi/internal/types"
+	"time"
+)
+
+type WorkspaceRepo struct {
+	db dbtx
+}
+
+func NewWorkspaceRepo(db *sql.DB) *WorkspaceRepo {
+	return &WorkspaceRepo{db: db}
+}
+
+// CreateWorkspace creates a workspace with the user as its owner
+func (w *WorkspaceRepo) CreateWorkspace(workspace *types.Workspace, user *types.User) error {
+	workspace.CreatedUserId = user.ID
+	workspace.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	res, err := w.db.Exec("INSERT INTO workspaces (title, created_user_id, created_at) VALUES (?, ?, ?)",
+		workspace.Title, workspace.CreatedUserId, workspace.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	workspaceID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	workspace.ID = int(workspaceID)
+	workspace.Role = types.WorkspaceOwner
+
+	_, err = w.db.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, ?, ?)",
+		workspace.ID, user.ID, types.WorkspaceOwner)
+
+	return err
+}
+
+// GetWorkspacesByUser returns the workspaces the user is a member of
+func (w *WorkspaceRepo) GetWorkspacesByUser(user *types.User) ([]types.Workspace, error) {
+	rows, err := w.db.Query(`
+		SELECT w.id, w.title, w.created_user_id, w.created_at, m.role
+		FROM workspaces w
+			JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = ?
+		ORDER BY w.title, w.id`, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := []types.Workspace{}
+	for rows.Next() {
+		var workspace types.Workspace
+		var createdAt string
+		err = rows.Scan(&workspace.ID, &workspace.Title, &workspace.CreatedUserId, &createdAt, &workspace.Role)
+		if err != nil {
+			return nil, err
+		}
+
+		workspace.CreatedAt, err = time.Parse(dateTimeLayout, createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		workspaces = append(workspaces, workspace)
+	}
+
+	return workspaces, rows.Err()
+}
+
+func (w *WorkspaceRepo) WorkspaceRole(workspaceID int, user *types.User) (string, error) {
+	var role string
+	err := w.db.QueryRow("SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceID, user.ID).
+		Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return role, err
+}
+
+// GetWorkspaceMembers returns the members of the workspace, owner and admins first
+func (w *WorkspaceRepo) GetWorkspaceMembers(workspaceID int, user *types.User) ([]types.Collaborator, error) {
+	role, err := w.WorkspaceRole(workspaceID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == "" {
+		return nil, errors.New("workspace not found")
+	}
+
+	rows, err := w.db.Query(`
+		SELECT u.username, m.role
+		FROM workspace_members m
+			JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = ?
+		ORDER BY FIELD(m.role, ?, ?, ?, ?), u.username`,
+		workspaceID, types.WorkspaceOwner, types.WorkspaceAdmin, types.WorkspaceMember, types.WorkspaceGuest)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []types.Collaborator{}
+	for rows.Next() {
+		var member types.Collaborator
+		err = rows.Scan(&member.Username, &member.Role)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// SetWorkspaceMember changes the role of a member. Admins can manage members and guests, only the owner can manage
+// admins. Members who are no guests see all todos of the workspace. Other users have to be invited with
+// InviteWorkspaceMember.
+func (w *WorkspaceRepo) SetWorkspaceMember(workspaceID int, user *types.User, member *types.User, role string) error {
+	if role == types.WorkspaceOwner || workspaceRoleRank(role) < 0 {
+		return errors.New("role can not be granted")
+	}
+
+	if member.ID == user.ID {
+		return errors.New("users can not change their own role")
+	}
+
+	err := w.requireManagement(workspaceID, user, member, role)
+	if err != nil {
+		return err
+	}
+
+	memberRole, err := w.WorkspaceRole(workspaceID, member)
+	if err != nil {
+		return err
+	}
+
+	if memberRole == "" {
+		return types.ErrNotWorkspaceMember
+	}
+
+	_, err = w.db.Exec("UPDATE workspace_members SET role = ? WHERE workspace_id = ? AND user_id = ?",
+		role, workspaceID, member.ID)
+	if err != nil {
+		return err
+	}
+
+	return w.syncWorkspaceAccess(workspaceID, member, role)
+}
+
+// InviteWorkspaceMember invites another user to the workspace with the role. Admins can invite members and guests,
+// only the owner can invite admins. Inviting the user again replaces the pending invitation and starts its validity
+// anew.
+func (w *WorkspaceRepo) InviteWorkspaceMember(workspaceID int, user *types.User, invitee *types.User, role string) error {
+	if role == types.WorkspaceOwner || workspaceRoleRank(role) < 0 {
+		return errors.New("role can not be granted")
+	}
+
+	if invitee.ID == user.ID {
+		return errors.New("user can not invite themselves")
+	}
+
+	err := w.requireManagement(workspaceID, user, invitee, role)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = w.db.QueryRow("SELECT COUNT(*) FROM user_blocks WHERE user_id = ? AND blocked_user_id = ?", invitee.ID, user.ID).
+		Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errors.New("user does not accept invitations from you")
+	}
+
+	inviteeRole, err := w.WorkspaceRole(workspaceID, invitee)
+	if err != nil {
+		return err
+	}
+
+	if inviteeRole != "" {
+		return errors.New("user is already a member of the workspace")
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err = w.db.Exec(`
+		INSERT INTO workspace_invitations (workspace_id, inviter_id, invitee_id, role, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE inviter_id = VALUES(inviter_id), role = VALUES(role),
+			created_at = VALUES(created_at), expires_at = VALUES(expires_at)`,
+		workspaceID, user.ID, invitee.ID, role, now, now.Add(invitationValidity))
+
+	return err
+}
+
+// GetWorkspaceInvitationsByUser returns the pending invitations of the user to workspaces that did not expire yet,
+// newest first
+func (w *WorkspaceRepo) GetWorkspaceInvitationsByUser(user *types.User) ([]types.WorkspaceInvitation, error) {
+	rows, err := w.db.Query(`
+		SELECT i.id, i.workspace_id, ws.title, u.username, i.role, i.created_at, i.expires_at
+		FROM workspace_invitations i
+			JOIN workspaces ws ON ws.id = i.workspace_id
+			JOIN users u ON u.id = i.inviter_id
+		WHERE i.invitee_id = ? AND i.expires_at > ?
+		ORDER BY i.created_at DESC, i.id DESC`, user.ID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []types.WorkspaceInvitation{}
+	for rows.Next() {
+		var invitation types.WorkspaceInvitation
+		var createdAt, expiresAt string
+		err = rows.Scan(&invitation.ID, &invitation.WorkspaceID, &invitation.WorkspaceTitle, &invitation.InvitedBy,
+			&invitation.Role, &createdAt, &expiresAt)
+		if err != nil {
+			return nil, err
+		}
+
+		invitation.CreatedAt, err = time.Parse(dateTimeLayout, createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		invitation.ExpiresAt, err = time.Parse(dateTimeLayout, expiresAt)
+		if err != nil {
+			return nil, err
+		}
+
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+// AcceptWorkspaceInvitation makes the user a member of the workspace. The inviter has to be allowed to grant the
+// role still. The invitation is deleted last, so a failed accept can be repeated.
+func (w *WorkspaceRepo) AcceptWorkspaceInvitation(invitationID int, user *types.User) error {
+	var workspaceID, inviterID int
+	var role, expiresAt string
+	err := w.db.QueryRow(`
+		SELECT workspace_id, inviter_id, role, expires_at
+		FROM workspace_invitations
+		WHERE id = ? AND invitee_id = ?`, invitationID, user.ID).Scan(&workspaceID, &inviterID, &role, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("invitation not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	expires, err := time.Parse(dateTimeLayout, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	if !expires.After(time.Now().UTC()) {
+		return errors.New("invitation has expired")
+	}
+
+	err = w.requireManagement(workspaceID, &types.User{ID: inviterID}, user, role)
+	if err != nil {
+		return err
+	}
+
+	// an outdated invitation must not demote someone who became a member with more rights in the meantime
+	currentRole, err := w.WorkspaceRole(workspaceID, user)
+	if err != nil {
+		return err
+	}
+
+	if !hasWorkspaceRole(currentRole, role) {
+		_, err = w.db.Exec(`
+			INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE role = VALUES(role)`, workspaceID, user.ID, role)
+		if err != nil {
+			return err
+		}
+
+		err = w.syncWorkspaceAccess(workspaceID, user, role)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = w.db.Exec("DELETE FROM workspace_invitations WHERE id = ?", invitationID)
+
+	return err
+}
+
+func (w *WorkspaceRepo) DeclineWorkspaceInvitation(invitationID int, user *types.User) error {
+	result, err := w.db.Exec("DELETE FROM workspace_invitations WHERE id = ? AND invitee_id = ?", invitationID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return errors.New("invitation not found")
+	}
+
+	return nil
+}
+
+// RemoveWorkspaceMember takes the workspace and all todos of it away from a member, except the todos they own.
+// Owners and admins can remove others, every member except the owner can leave the workspace.
+func (w *WorkspaceRepo) RemoveWorkspaceMember(workspaceID int, user *types.User, member *types.User) error {
+	memberRole, err := w.WorkspaceRole(workspaceID, member)
+	if err != nil {
+		return err
+	}
+
+	if memberRole == "" {
+		return errors.New("user is not a member of the workspace")
+	}
+
+	if memberRole == types.WorkspaceOwner {
+		return errors.New("the owner can not leave the workspace")
+	}
+
+	if member.ID != user.ID {
+		err = w.requireManagement(workspaceID, user, member, memberRole)
+		if err != nil {
+			return err
+		}
+	}
+
+	rows, err := w.db.Query(`
+		SELECT ut.todo_id
+		FROM user_todos ut
+			JOIN todos t ON t.id = ut.todo_id
+		WHERE ut.user_id = ? AND t.workspace_id = ? AND ut.role <> ?`, member.ID, workspaceID, types.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	var todoIds []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		todoIds = append(todoIds, id)
+	}
+	rows.Close()
+
+	_, err = w.db.Exec(`
+		DELETE ut FROM user_todos ut
+			JOIN todos t ON t.id = ut.todo_id
+		WHERE ut.user_id = ? AND t.workspace_id = ? AND ut.role <> ?`, member.ID, workspaceID, types.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	err = deleteUserTags(w.db, member.ID, todoIds)
+	if err != nil {
+		return err
+	}
+
+	err = unassignWithoutAccess(w.db, todoIds)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.db.Exec(`
+		DELETE m FROM category_members m
+			JOIN categories g ON g.id = m.category_id
+		WHERE m.user_id = ? AND g.workspace_id = ?`, member.ID, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.db.Exec(`
+		DELETE i FROM invitations i
+			JOIN todos t ON t.id = i.todo_id
+		WHERE i.invitee_id = ? AND t.workspace_id = ?`, member.ID, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.db.Exec("DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceID, member.ID)
+
+	return err
+}
+
+// syncWorkspaceAccess gives the member access to the todos of the workspace with the role of their membership,
+// guests lose the access they had through the workspace
+func (w *WorkspaceRepo) syncWorkspaceAccess(workspaceID int, member *types.User, role string) error {
+	todoIds, err := workspaceTodoIds(w.db, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	todoRole := workspaceTodoRole(role)
+	if todoRole == "" {
+		_, err = w.db.Exec("DELETE FROM user_todos WHERE user_id = ? AND via_workspace_id = ?", member.ID, workspaceID)
+		if err != nil {
+			return err
+		}
+
+		return unassignWithoutAccess(w.db, todoIds)
+	}
+
+	_, err = w.db.Exec("UPDATE user_todos SET role = ? WHERE user_id = ? AND via_workspace_id = ?",
+		todoRole, member.ID, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	return grantWorkspaceAccess(w.db, workspaceID, todoIds)
+}
+
+// requireManagement fails unless the user may manage a member with the role: owners manage everyone,
+// admins only members and guests
+func (w *WorkspaceRepo) requireManagement(workspaceID int, user *types.User, member *types.User, role string) error {
+	userRole, err := w.WorkspaceRole(workspaceID, user)
+	if err != nil {
+		return err
+	}
+
+	if userRole == "" {
+		return errors.New("workspace not found")
+	}
+
+	if !hasWorkspaceRole(userRole, types.WorkspaceAdmin) {
+		return errors.New("only owners and admins can manage the members of the workspace")
+	}
+
+	if userRole == types.WorkspaceOwner {
+		return nil
+	}
+
+	memberRole, err := w.WorkspaceRole(workspaceID, member)
+	if err != nil {
+		return err
+	}
+
+	if hasWorkspaceRole(memberRole, types.WorkspaceAdmin) || hasWorkspaceRole(role, types.WorkspaceAdmin) {
+		return errors.New("only the owner can manage admins")
+	}
+
+	return nil
+}
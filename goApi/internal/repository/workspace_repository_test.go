@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/floxo05/todoapi/internal/types"
+	"testing"
+	"time"
+)
+
+func TestWorkspaceRepo_SetWorkspaceMember(t *testing.T) {
+	t.Run("should only let the owner add admins", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT role FROM workspace_members").WithArgs(4, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.WorkspaceAdmin))
+		mock.ExpectQuery("^SELECT role FROM workspace_members").WithArgs(4, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.WorkspaceMember))
+
+		repo := NewWorkspaceRepo(db)
+
+		// Act
+		err = repo.SetWorkspaceMember(4, &types.User{ID: 1}, &types.User{ID: 2}, types.WorkspaceAdmin)
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should take the todos of the workspace away from guests", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT role FROM workspace_members").WithArgs(4, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.WorkspaceOwner))
+		mock.ExpectQuery("^SELECT role FROM workspace_members").WithArgs(4, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.WorkspaceMember))
+		mock.ExpectExec("^UPDATE workspace_members SET role = \\?").WithArgs(types.WorkspaceGuest, 4, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("^SELECT id FROM todos WHERE workspace_id = \\?").WithArgs(4).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectExec("^DELETE FROM user_todos WHERE user_id = \\? AND via_workspace_id = \\?").WithArgs(2, 4).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec("^DELETE a FROM todo_assignees a").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := NewWorkspaceRepo(db)
+
+		// Act
+		err = repo.SetWorkspaceMember(4, &types.User{ID: 1}, &types.User{ID: 2}, types.WorkspaceGuest)
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should not add users who were not invited", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT role FROM workspace_members").WithArgs(4, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.WorkspaceOwner))
+		mock.ExpectQuery("^SELECT role FROM workspace_members").WithArgs(4, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}))
+
+		repo := NewWorkspaceRepo(db)
+
+		// Act
+		err = repo.SetWorkspaceMember(4, &types.User{ID: 1}, &types.User{ID: 2}, types.WorkspaceMember)
+
+		// Assert
+		if !errors.Is(err, types.ErrNotWorkspaceMember) {
+			t.Errorf("Expected error %v, but got %v", types.ErrNotWorkspaceMember, err)
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestWorkspaceRepo_AcceptWorkspaceInvitation(t *testing.T) {
+	t.Run("should reject invitations of admins who were demoted", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		expiresAt := time.Now().UTC().Add(time.Hour).Format(dateTimeLayout)
+		mock.ExpectQuery("^SELECT workspace_id, inviter_id, role, expires_at FROM workspace_invitations").WithArgs(3, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"workspace_id", "inviter_id", "role", "expires_at"}).
+				AddRow(4, 1, types.WorkspaceMember, expiresAt))
+		mock.ExpectQuery("^SELECT role FROM workspace_members").WithArgs(4, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.WorkspaceMember))
+
+		repo := NewWorkspaceRepo(db)
+
+		// Act
+		err = repo.AcceptWorkspaceInvitation(3, &types.User{ID: 2})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("should keep the higher role of an existing member", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		expiresAt := time.Now().UTC().Add(time.Hour).Format(dateTimeLayout)
+		mock.ExpectQuery("^SELECT workspace_id, inviter_id, role, expires_at FROM workspace_invitations").WithArgs(3, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"workspace_id", "inviter_id", "role", "expires_at"}).
+				AddRow(4, 1, types.WorkspaceGuest, expiresAt))
+		mock.ExpectQuery("^SELECT role FROM workspace_members").WithArgs(4, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.WorkspaceOwner))
+		mock.ExpectQuery("^SELECT role FROM workspace_members").WithArgs(4, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.WorkspaceAdmin))
+		mock.ExpectExec("^DELETE FROM workspace_invitations WHERE id = \\?").WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := NewWorkspaceRepo(db)
+
+		// Act
+		err = repo.AcceptWorkspaceInvitation(3, &types.User{ID: 2})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestWorkspaceRepo_RemoveWorkspaceMember(t *testing.T) {
+	t.Run("should not let the owner leave the workspace", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT role FROM workspace_members").WithArgs(4, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(types.WorkspaceOwner))
+
+		repo := NewWorkspaceRepo(db)
+
+		// Act
+		err = repo.RemoveWorkspaceMember(4, &types.User{ID: 1}, &types.User{ID: 1})
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
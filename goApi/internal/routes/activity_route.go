@@ -0,0 +1,175 @@
+package routes
+
+import (
+	"errors"
+	"github.com/floxo05/todoapi/internal/types"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+type ActivityRoute struct {
+	activityRepository types.ActivityRepository
+	userContextHelper  types.UserContextInterface
+}
+
+func NewActivityRoute(activityRepository types.ActivityRepository, userContextHelper types.UserContextInterface) *ActivityRoute {
+	return &ActivityRoute{activityRepository: activityRepository, userContextHelper: userContextHelper}
+}
+
+// GetTodoHistory lists the changes made to a todo, oldest first
+func (a *ActivityRoute) GetTodoHistory(c *gin.Context) {
+	user, err := a.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo id"})
+		return
+	}
+
+	events, err := a.activityRepository.GetTodoHistory(todoID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// GetActivity lists the changes made to the todos the user can see, newest first. It is always paginated.
+func (a *ActivityRoute) GetActivity(c *gin.Context) {
+	user, err := a.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := parsePageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if page == nil {
+		page = &types.PageRequest{Limit: defaultPageLimit}
+	}
+
+	activity, err := a.activityRepository.GetActivityByUser(user, *page)
+	if errors.Is(err, types.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, activity)
+}
+
+// recordEvent adds a change to the history of a todo within the transaction of the change, so a change is never
+// committed without its event
+func recordEvent(tx types.Transaction, event types.TodoEvent, actor *types.User) error {
+	event.ActorID = actor.ID
+	event.Actor = actor.Username
+
+	return tx.Activity().RecordEvent(&event)
+}
+
+// moveCategory moves the todo into another category within the transaction and describes the change, it returns a
+// nil event if the category stayed the same
+func moveCategory(tx types.Transaction, todo *types.Todo, afterID *int, categoryID *int, user *types.User) (*types.TodoEvent, error) {
+	before, err := tx.Todos().GetTodoById(todo.ID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Todos().ReorderTodo(todo, afterID, categoryID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := tx.Todos().GetTodoById(todo.ID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return updateEvent(before, after), nil
+}
+
+// updateEvent describes the changes between two versions of a todo, it returns nil if nothing changed
+func updateEvent(before *types.Todo, after *types.Todo) *types.TodoEvent {
+	changes := todoChanges(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	event := types.TodoEvent{TodoID: after.ID, Action: types.EventUpdated, Changes: changes}
+	if _, ok := changes[types.FieldCompleted]; ok {
+		event.Action = types.EventReopened
+		if after.Completed {
+			event.Action = types.EventCompleted
+		}
+	}
+
+	return &event
+}
+
+// todoChanges returns the fields that differ between two versions of a todo with their old and new value
+func todoChanges(before *types.Todo, after *types.Todo) map[string]types.FieldChange {
+	fields := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{types.FieldTitle, before.Title, after.Title},
+		{types.FieldCompleted, before.Completed, after.Completed},
+		{types.FieldCategory, eventCategory(before), eventCategory(after)},
+		{types.FieldDueAt, before.DueAt, after.DueAt},
+		{types.FieldDueHasTime, before.DueHasTime, after.DueHasTime},
+		{types.FieldStartAt, before.StartAt, after.StartAt},
+		{types.FieldStartHasTime, before.StartHasTime, after.StartHasTime},
+		{types.FieldPriority, before.Priority, after.Priority},
+		{types.FieldDescription, before.Description, after.Description},
+		{types.FieldRecurrenceRule, before.RecurrenceRule, after.RecurrenceRule},
+	}
+
+	changes := map[string]types.FieldChange{}
+	for _, field := range fields {
+		if !equalFieldValues(field.old, field.new) {
+			changes[field.name] = types.FieldChange{Old: field.old, New: field.new}
+		}
+	}
+
+	return changes
+}
+
+// equalFieldValues compares dates by their instant, a date read from the database and the same date of a request
+// may differ in their location
+func equalFieldValues(a interface{}, b interface{}) bool {
+	aTime, aIsTime := a.(*time.Time)
+	bTime, bIsTime := b.(*time.Time)
+	if aIsTime && bIsTime {
+		if aTime == nil || bTime == nil {
+			return aTime == bTime
+		}
+
+		return aTime.Equal(*bTime)
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// eventCategory is the id and title of the category of the todo, nil for todos without a category
+func eventCategory(todo *types.Todo) interface{} {
+	if todo.Category.ID == 0 && todo.Category.Title == "" {
+		return nil
+	}
+
+	return map[string]interface{}{"id": todo.Category.ID, "title": todo.Category.Title}
+}
@@ -0,0 +1,78 @@
+package routes
+
+import (
+	"github.com/floxo05/todoapi/internal/types"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUpdateEvent(t *testing.T) {
+	due := time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)
+	sameDue := due.In(time.FixedZone("CEST", 2*60*60))
+	before := types.Todo{ID: 1, Title: "Todo", Priority: types.PriorityNone, DueAt: &due}
+
+	testcases := []struct {
+		name           string
+		after          types.Todo
+		expectedAction string
+		expectedFields []string
+	}{
+		{
+			name:           "should record changed fields",
+			after:          types.Todo{ID: 1, Title: "Renamed", Priority: types.PriorityHigh, DueAt: &due},
+			expectedAction: types.EventUpdated,
+			expectedFields: []string{"priority", "title"},
+		},
+		{
+			name:           "should record completions",
+			after:          types.Todo{ID: 1, Title: "Todo", Completed: true, Priority: types.PriorityNone, DueAt: &due},
+			expectedAction: types.EventCompleted,
+			expectedFields: []string{"completed"},
+		},
+		{
+			name:           "should record removed dates",
+			after:          types.Todo{ID: 1, Title: "Todo", Priority: types.PriorityNone},
+			expectedAction: types.EventUpdated,
+			expectedFields: []string{"due_at"},
+		},
+		{
+			name:  "should ignore the location of dates",
+			after: types.Todo{ID: 1, Title: "Todo", Priority: types.PriorityNone, DueAt: &sameDue},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			event := updateEvent(&before, &tc.after)
+
+			// Assert
+			if tc.expectedFields == nil {
+				if event != nil {
+					t.Errorf("Expected no event, but got %v", event)
+				}
+				return
+			}
+
+			if event == nil {
+				t.Fatalf("Expected an event, but got nil")
+			}
+
+			if event.Action != tc.expectedAction {
+				t.Errorf("Expected action %s, but got %s", tc.expectedAction, event.Action)
+			}
+
+			var fields []string
+			for _, field := range []string{"category", "completed", "due_at", "priority", "title"} {
+				if _, ok := event.Changes[field]; ok {
+					fields = append(fields, field)
+				}
+			}
+
+			if !reflect.DeepEqual(fields, tc.expectedFields) || len(event.Changes) != len(tc.expectedFields) {
+				t.Errorf("Expected changed fields %v, but got %v", tc.expectedFields, event.Changes)
+			}
+		})
+	}
+}
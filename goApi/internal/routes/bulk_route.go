@@ -0,0 +1,193 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/floxo05/todoapi/internal/types"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+const maxBulkOperations = 100
+
+// errBulkAborted rolls back an all-or-nothing request after the first failed operation
+var errBulkAborted = errors.New("bulk request aborted")
+
+type BulkRoute struct {
+	transactionManager types.TransactionManager
+	userContextHelper  types.UserContextInterface
+	recurrence         types.RecurrenceInterface
+}
+
+func NewBulkRoute(transactionManager types.TransactionManager, userContextHelper types.UserContextInterface, recurrence types.RecurrenceInterface) *BulkRoute {
+	return &BulkRoute{
+		transactionManager: transactionManager,
+		userContextHelper:  userContextHelper,
+		recurrence:         recurrence,
+	}
+}
+
+// ExecuteBulk applies all operations in one transaction and reports the result of every operation.
+// A failed operation only undoes its own changes, unless the request is all or nothing.
+func (b *BulkRoute) ExecuteBulk(c *gin.Context) {
+	user, err := b.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req types.BulkRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if len(req.Operations) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'operations' must not be empty"})
+		return
+	}
+
+	if len(req.Operations) > maxBulkOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'operations' must not contain more than 100 operations"})
+		return
+	}
+
+	results := make([]types.BulkResult, len(req.Operations))
+	for i, operation := range req.Operations {
+		if err = validateBulkOperation(operation); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("operation %d: %s", i, err.Error())})
+			return
+		}
+
+		results[i] = types.BulkResult{TodoID: operation.TodoID, Action: operation.Action, Status: types.BulkStatusSkipped}
+	}
+
+	// an operation and its event are undone together
+	err = b.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		for i, operation := range req.Operations {
+			err := tx.Savepoint(func() error {
+				event, err := b.applyOperation(tx, operation, user)
+				if err != nil || event == nil {
+					return err
+				}
+
+				return recordEvent(tx, *event, user)
+			})
+			if err != nil {
+				results[i].Status = types.BulkStatusFailed
+				results[i].Error = err.Error()
+
+				if req.AllOrNothing {
+					return errBulkAborted
+				}
+				continue
+			}
+
+			results[i].Status = types.BulkStatusDone
+		}
+
+		return nil
+	})
+
+	committed := true
+	if errors.Is(err, errBulkAborted) {
+		committed = false
+		for i := range results {
+			if results[i].Status == types.BulkStatusDone {
+				results[i].Status = types.BulkStatusRolledBack
+			}
+		}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"committed": committed, "results": results})
+}
+
+// applyOperation changes the todo of the operation and describes the change for the history of the todo. Sharing
+// only invites the user, the todo is shared once they accept.
+func (b *BulkRoute) applyOperation(tx types.Transaction, operation types.BulkOperation, user *types.User) (*types.TodoEvent, error) {
+	todo := types.Todo{ID: operation.TodoID}
+
+	switch operation.Action {
+	case types.BulkComplete, types.BulkReopen:
+		previous, err := tx.Todos().GetTodoById(todo.ID, user)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.Todos().SetCompleted(&todo, operation.Action == types.BulkComplete, user)
+		if err != nil {
+			return nil, err
+		}
+
+		if todo.Completed && !previous.Completed && todo.RecurrenceRule != "" {
+			_, err = createNextOccurrence(b.recurrence, tx.Todos(), &todo)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return updateEvent(previous, &todo), nil
+	case types.BulkMoveCategory:
+		return moveCategory(tx, &todo, nil, operation.CategoryID, user)
+	case types.BulkDelete:
+		err := tx.Todos().DeleteTodoById(&todo, user)
+		if err != nil {
+			return nil, err
+		}
+
+		return &types.TodoEvent{TodoID: todo.ID, Action: types.EventDeleted}, nil
+	case types.BulkShare:
+		invitee, err := tx.Users().GetUserByUsername(operation.Username)
+		if err != nil {
+			return nil, errors.New("user to share with not found")
+		}
+
+		role := operation.Role
+		if role == "" {
+			role = types.RoleEditor
+		}
+
+		return nil, tx.Invitations().CreateInvitation(todo.ID, user, invitee, role)
+	case types.BulkTag:
+		err := tx.Tags().AttachTag(todo.ID, &types.Tag{ID: operation.TagID}, user)
+		if err != nil {
+			return nil, err
+		}
+
+		return &types.TodoEvent{TodoID: todo.ID, Action: types.EventTagged}, nil
+	}
+
+	return nil, errors.New("unknown action")
+}
+
+func validateBulkOperation(operation types.BulkOperation) error {
+	if operation.TodoID <= 0 {
+		return errors.New("'todo_id' must be a todo id")
+	}
+
+	switch operation.Action {
+	case types.BulkComplete, types.BulkReopen, types.BulkDelete:
+	case types.BulkMoveCategory:
+		if operation.CategoryID == nil || *operation.CategoryID < 0 {
+			return errors.New("'category_id' must be a category id or 0")
+		}
+	case types.BulkShare:
+		if operation.Username == "" {
+			return errors.New("'username' must not be empty")
+		}
+		if operation.Role != "" && !validShareRole(operation.Role) {
+			return errors.New("'role' must be one of viewer, editor or manager")
+		}
+	case types.BulkTag:
+		if operation.TagID <= 0 {
+			return errors.New("'tag_id' must be a tag id")
+		}
+	default:
+		return errors.New("'action' must be one of complete, reopen, move_category, delete, share or tag")
+	}
+
+	return nil
+}
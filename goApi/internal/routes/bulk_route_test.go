@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"github.com/floxo05/todoapi/internal/types"
+	"testing"
+)
+
+func TestValidateBulkOperation(t *testing.T) {
+	categoryID := 3
+	noCategory := 0
+	negativeCategory := -1
+
+	testcases := []struct {
+		name      string
+		operation types.BulkOperation
+		valid     bool
+	}{
+		{name: "complete", operation: types.BulkOperation{Action: types.BulkComplete, TodoID: 1}, valid: true},
+		{name: "missing todo", operation: types.BulkOperation{Action: types.BulkDelete}, valid: false},
+		{name: "unknown action", operation: types.BulkOperation{Action: "archive", TodoID: 1}, valid: false},
+		{name: "move category", operation: types.BulkOperation{Action: types.BulkMoveCategory, TodoID: 1, CategoryID: &categoryID}, valid: true},
+		{name: "move without category", operation: types.BulkOperation{Action: types.BulkMoveCategory, TodoID: 1, CategoryID: &noCategory}, valid: true},
+		{name: "move missing category", operation: types.BulkOperation{Action: types.BulkMoveCategory, TodoID: 1}, valid: false},
+		{name: "move invalid category", operation: types.BulkOperation{Action: types.BulkMoveCategory, TodoID: 1, CategoryID: &negativeCategory}, valid: false},
+		{name: "share", operation: types.BulkOperation{Action: types.BulkShare, TodoID: 1, Username: "test"}, valid: true},
+		{name: "share missing user", operation: types.BulkOperation{Action: types.BulkShare, TodoID: 1}, valid: false},
+		{name: "tag", operation: types.BulkOperation{Action: types.BulkTag, TodoID: 1, TagID: 2}, valid: true},
+		{name: "tag missing tag", operation: types.BulkOperation{Action: types.BulkTag, TodoID: 1}, valid: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			err := validateBulkOperation(tc.operation)
+
+			// Assert
+			if tc.valid && err != nil {
+				t.Errorf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			if !tc.valid && err == nil {
+				t.Errorf("Expected an error, but got nil")
+			}
+		})
+	}
+}
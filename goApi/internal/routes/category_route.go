@@ -1,18 +1,27 @@
 package routes
 
 import (
+	"errors"
 	"github.com/floxo05/todoapi/internal/types"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strconv"
 )
 
 type CategoryRoute struct {
 	categoryRepository types.CategoryRepository
+	userRepository     types.UserRepository
+	transactionManager types.TransactionManager
 	userContextHelper  types.UserContextInterface
 }
 
-func NewCategoryRoute(categoryRepo types.CategoryRepository, userContextHelper types.UserContextInterface) *CategoryRoute {
-	return &CategoryRoute{categoryRepository: categoryRepo, userContextHelper: userContextHelper}
+func NewCategoryRoute(categoryRepo types.CategoryRepository, userRepo types.UserRepository, transactionManager types.TransactionManager, userContextHelper types.UserContextInterface) *CategoryRoute {
+	return &CategoryRoute{
+		categoryRepository: categoryRepo,
+		userRepository:     userRepo,
+		transactionManager: transactionManager,
+		userContextHelper:  userContextHelper,
+	}
 }
 
 func (cr *CategoryRoute) CreateCategory(c *gin.Context) {
@@ -34,7 +43,12 @@ func (cr *CategoryRoute) CreateCategory(c *gin.Context) {
 		return
 	}
 
-	category := types.Category{Title: req.Title, CreatedUserId: user.ID}
+	if user.WorkspaceRole == types.WorkspaceGuest {
+		c.JSON(http.StatusForbidden, gin.H{"error": "guests can not create categories in the workspace"})
+		return
+	}
+
+	category := types.Category{Title: req.Title, CreatedUserId: user.ID, WorkspaceID: user.WorkspaceID}
 	err = cr.categoryRepository.UpsertCategory(&category)
 }
 
@@ -45,7 +59,29 @@ func (cr *CategoryRoute) GetCategories(c *gin.Context) {
 		return
 	}
 
-	categories, err := cr.categoryRepository.GetCategoriesByUserId(user.ID)
+	page, err := parsePageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if page != nil {
+		var categoryPage *types.Page[types.Category]
+		categoryPage, err = cr.categoryRepository.GetCategoriesPageByUserId(user.ID, user.WorkspaceID, *page)
+		if errors.Is(err, types.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, categoryPage)
+		return
+	}
+
+	categories, err := cr.categoryRepository.GetCategoriesByUserId(user.ID, user.WorkspaceID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -53,3 +89,247 @@ func (cr *CategoryRoute) GetCategories(c *gin.Context) {
 
 	c.JSON(http.StatusOK, categories)
 }
+
+// GetCategoryMembers lists the owner and the members of a shared category
+func (cr *CategoryRoute) GetCategoryMembers(c *gin.Context) {
+	user, err := cr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category id"})
+		return
+	}
+
+	members, err := cr.categoryRepository.GetCategoryMembers(categoryID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// SetCategoryMember changes the role of a member or invites a user to the category
+func (cr *CategoryRoute) SetCategoryMember(c *gin.Context) {
+	user, err := cr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category id"})
+		return
+	}
+
+	var req types.SetRoleRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if req.Role == "" {
+		req.Role = types.RoleEditor
+	}
+
+	if !validShareRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'role' must be one of viewer, editor or manager"})
+		return
+	}
+
+	member, err := cr.userRepository.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve member"})
+		return
+	}
+
+	invited := false
+	err = cr.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		// the previous role is only needed for the history of the todos
+		var previousRole interface{}
+		members, err := tx.Categories().GetCategoryMembers(categoryID, user)
+		if err == nil {
+			for _, current := range members {
+				if current.Username == member.Username {
+					previousRole = current.Role
+				}
+			}
+		}
+
+		err = tx.Categories().SetCategoryMember(categoryID, user, member, req.Role)
+		if errors.Is(err, types.ErrNotCategoryMember) {
+			// new members only get access to the todos of the category after accepting
+			invited = true
+			return tx.Categories().InviteCategoryMember(categoryID, user, member, req.Role)
+		}
+		if err != nil || previousRole == req.Role {
+			return err
+		}
+
+		return recordCategoryEvent(tx, categoryID, types.TodoEvent{Action: types.EventRoleChanged,
+			Subject: &member.Username, Changes: map[string]types.FieldChange{"role": {Old: previousRole, New: req.Role}}},
+			user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if invited {
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation sent successfully"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role changed successfully"})
+}
+
+func (cr *CategoryRoute) RemoveCategoryMember(c *gin.Context) {
+	user, err := cr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category id"})
+		return
+	}
+
+	member, err := cr.userRepository.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve member"})
+		return
+	}
+
+	err = cr.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Categories().RemoveCategoryMember(categoryID, user, member)
+		if err != nil {
+			return err
+		}
+
+		return recordCategoryEvent(tx, categoryID, types.TodoEvent{Action: types.EventUnshared,
+			Subject: &member.Username}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+func (cr *CategoryRoute) GetCategoryInvitations(c *gin.Context) {
+	user, err := cr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	invitations, err := cr.categoryRepository.GetCategoryInvitationsByUser(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// AcceptCategoryInvitation makes the user a member of the category of the invitation
+func (cr *CategoryRoute) AcceptCategoryInvitation(c *gin.Context) {
+	user, err := cr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	invitationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation id"})
+		return
+	}
+
+	invitation, err := cr.pendingCategoryInvitation(invitationID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = cr.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Categories().AcceptCategoryInvitation(invitationID, user)
+		if err != nil || invitation == nil {
+			return err
+		}
+
+		return recordCategoryEvent(tx, invitation.CategoryID, types.TodoEvent{Action: types.EventShared,
+			Subject: &user.Username, Changes: map[string]types.FieldChange{"role": {Old: nil, New: invitation.Role}}}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted successfully"})
+}
+
+func (cr *CategoryRoute) DeclineCategoryInvitation(c *gin.Context) {
+	user, err := cr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	invitationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation id"})
+		return
+	}
+
+	err = cr.categoryRepository.DeclineCategoryInvitation(invitationID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation declined successfully"})
+}
+
+// pendingCategoryInvitation returns the pending invitation of the user with the id, nil if there is none
+func (cr *CategoryRoute) pendingCategoryInvitation(invitationID int, user *types.User) (*types.CategoryInvitation, error) {
+	invitations, err := cr.categoryRepository.GetCategoryInvitationsByUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, invitation := range invitations {
+		if invitation.ID == invitationID {
+			return &invitation, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// recordCategoryEvent adds the event to the history of every todo in the category, the members of a category share
+// all of its todos
+func recordCategoryEvent(tx types.Transaction, categoryID int, event types.TodoEvent, actor *types.User) error {
+	todoIds, err := tx.Categories().GetCategoryTodoIds(categoryID)
+	if err != nil {
+		return err
+	}
+
+	for _, todoID := range todoIds {
+		event.TodoID = todoID
+		err = recordEvent(tx, event, actor)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"bytes"
+	"github.com/floxo05/todoapi/internal/types"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCategoryRoute_SetCategoryMember(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	categoryRoute := NewCategoryRoute(&mockCategoryRepository{}, &mockUserRepository{},
+		&mockTransactionManager{categoryRepo: &mockCategoryRepository{}}, &mockUserContextHelper{})
+	router := gin.Default()
+	router.PUT("/category/:id/members/:username", categoryRoute.SetCategoryMember)
+
+	testcases := []struct {
+		Path             string
+		Body             []byte
+		expectedResponse int
+	}{
+		{Path: "/category/1/members/test", Body: []byte(`{"role": "viewer"}`), expectedResponse: http.StatusOK},
+		{Path: "/category/1/members/test", Body: []byte(`{}`), expectedResponse: http.StatusOK},
+		{Path: "/category/1/members/test", Body: []byte(`{"role": "owner"}`), expectedResponse: http.StatusBadRequest},
+		{Path: "/category/abc/members/test", Body: []byte(`{"role": "viewer"}`), expectedResponse: http.StatusBadRequest},
+		{Path: "/category/1/members/unknown", Body: []byte(`{"role": "viewer"}`), expectedResponse: http.StatusNotFound},
+	}
+
+	for _, tc := range testcases {
+		t.Run("Test SetCategoryMember", func(t *testing.T) {
+			// Act
+			req := httptest.NewRequest("PUT", tc.Path, bytes.NewBuffer(tc.Body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assert
+			if tc.expectedResponse != w.Code {
+				t.Errorf("Expected status code %d, but got %d", tc.expectedResponse, w.Code)
+			}
+		})
+	}
+}
+
+/////////////////////////////////////////////
+
+type mockCategoryRepository struct{}
+
+func (m *mockCategoryRepository) UpsertCategory(category *types.Category) error {
+	return nil
+}
+
+func (m *mockCategoryRepository) GetCategoryFromDB(category *types.Category) (*types.Category, error) {
+	return category, nil
+}
+
+func (m *mockCategoryRepository) GetCategoryByID(id int) (*types.Category, error) {
+	return &types.Category{ID: id, Title: "Test Category"}, nil
+}
+
+func (m *mockCategoryRepository) GetCategoriesByUserId(userID int, workspaceID *int) ([]types.Category, error) {
+	return []types.Category{}, nil
+}
+
+func (m *mockCategoryRepository) GetCategoriesPageByUserId(userID int, workspaceID *int, page types.PageRequest) (*types.Page[types.Category], error) {
+	return &types.Page[types.Category]{Items: []types.Category{}}, nil
+}
+
+func (m *mockCategoryRepository) CategoryRole(categoryID int, user *types.User) (string, error) {
+	return types.RoleOwner, nil
+}
+
+func (m *mockCategoryRepository) GetCategoryMembers(categoryID int, user *types.User) ([]types.Collaborator, error) {
+	return []types.Collaborator{}, nil
+}
+
+func (m *mockCategoryRepository) SetCategoryMember(categoryID int, user *types.User, member *types.User, role string) error {
+	return nil
+}
+
+func (m *mockCategoryRepository) RemoveCategoryMember(categoryID int, user *types.User, member *types.User) error {
+	return nil
+}
+
+func (m *mockCategoryRepository) InviteCategoryMember(categoryID int, user *types.User, invitee *types.User, role string) error {
+	return nil
+}
+
+func (m *mockCategoryRepository) GetCategoryInvitationsByUser(user *types.User) ([]types.CategoryInvitation, error) {
+	return []types.CategoryInvitation{}, nil
+}
+
+func (m *mockCategoryRepository) AcceptCategoryInvitation(invitationID int, user *types.User) error {
+	return nil
+}
+
+func (m *mockCategoryRepository) DeclineCategoryInvitation(invitationID int, user *types.User) error {
+	return nil
+}
+
+func (m *mockCategoryRepository) GetCategoryTodoIds(categoryID int) ([]int, error) {
+	return []int{}, nil
+}
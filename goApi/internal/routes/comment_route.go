@@ -0,0 +1,201 @@
+package routes
+
+import (
+	"github.com/floxo05/todoapi/internal/types"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type CommentRoute struct {
+	commentRepository  types.CommentRepository
+	transactionManager types.TransactionManager
+	userContextHelper  types.UserContextInterface
+}
+
+func NewCommentRoute(commentRepository types.CommentRepository, transactionManager types.TransactionManager, userContextHelper types.UserContextInterface) *CommentRoute {
+	return &CommentRoute{commentRepository: commentRepository, transactionManager: transactionManager,
+		userContextHelper: userContextHelper}
+}
+
+func (cr *CommentRoute) GetComments(c *gin.Context) {
+	user, err := cr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo id"})
+		return
+	}
+
+	comments, err := cr.commentRepository.GetCommentsByTodo(todoID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
+func (cr *CommentRoute) CreateComment(c *gin.Context) {
+	user, err := cr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo id"})
+		return
+	}
+
+	body, ok := bindCommentBody(c)
+	if !ok {
+		return
+	}
+
+	// the comment is only kept together with its mentions
+	comment := types.Comment{TodoID: todoID, Body: body}
+	err = cr.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		return tx.Comments().CreateComment(&comment, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
+
+// UpdateComment edits a comment of the user, the previous body stays in the history of the comment
+func (cr *CommentRoute) UpdateComment(c *gin.Context) {
+	user, err := cr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoID, commentID, ok := parseCommentPath(c)
+	if !ok {
+		return
+	}
+
+	body, ok := bindCommentBody(c)
+	if !ok {
+		return
+	}
+
+	// the previous body is only kept in the history if the comment is changed as well
+	comment := types.Comment{ID: commentID, TodoID: todoID, Body: body}
+	err = cr.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		return tx.Comments().UpdateComment(&comment, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
+
+func (cr *CommentRoute) DeleteComment(c *gin.Context) {
+	user, err := cr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoID, commentID, ok := parseCommentPath(c)
+	if !ok {
+		return
+	}
+
+	err = cr.commentRepository.DeleteComment(commentID, todoID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// GetCommentHistory lists the previous bodies of an edited comment
+func (cr *CommentRoute) GetCommentHistory(c *gin.Context) {
+	user, err := cr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoID, commentID, ok := parseCommentPath(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := cr.commentRepository.GetCommentHistory(commentID, todoID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetMentions lists the comments the user is mentioned in
+func (cr *CommentRoute) GetMentions(c *gin.Context) {
+	user, err := cr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	comments, err := cr.commentRepository.GetMentionsByUser(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
+// parseCommentPath reads the todo and comment id of /todo/:id/comments/:commentId, it answers bad ids itself
+func parseCommentPath(c *gin.Context) (int, int, bool) {
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo id"})
+		return 0, 0, false
+	}
+
+	commentID, err := strconv.Atoi(c.Param("commentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment id"})
+		return 0, 0, false
+	}
+
+	return todoID, commentID, true
+}
+
+// bindCommentBody reads and validates the body of a comment request, it answers invalid requests itself
+func bindCommentBody(c *gin.Context) (string, bool) {
+	var req types.CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return "", false
+	}
+
+	if req.Body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'body' must not be empty"})
+		return "", false
+	}
+
+	if len(req.Body) > maxDescriptionLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'body' must not be longer than 65535 bytes"})
+		return "", false
+	}
+
+	return req.Body, true
+}
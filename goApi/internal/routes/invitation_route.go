@@ -0,0 +1,237 @@
+package routes
+
+import (
+	"github.com/floxo05/todoapi/internal/types"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type InvitationRoute struct {
+	invitationRepository types.InvitationRepository
+	userRepository       types.UserRepository
+	transactionManager   types.TransactionManager
+	userContextHelper    types.UserContextInterface
+}
+
+func NewInvitationRoute(invitationRepository types.InvitationRepository, userRepository types.UserRepository, transactionManager types.TransactionManager, userContextHelper types.UserContextInterface) *InvitationRoute {
+	return &InvitationRoute{
+		invitationRepository: invitationRepository,
+		userRepository:       userRepository,
+		transactionManager:   transactionManager,
+		userContextHelper:    userContextHelper,
+	}
+}
+
+// ShareToUser invites a user to a todo, the todo is shared once they accept the invitation
+func (i *InvitationRoute) ShareToUser(c *gin.Context) {
+	user, err := i.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	var req types.ShareToUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if req.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'username' must not be empty"})
+		return
+	}
+
+	if req.TodoID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'id' must not be empty"})
+		return
+	}
+
+	if req.Role == "" {
+		req.Role = types.RoleEditor
+	}
+
+	if !validShareRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'role' must be one of viewer, editor or manager"})
+		return
+	}
+
+	invitee, err := i.userRepository.GetUserByUsername(req.Username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve shareUser"})
+		return
+	}
+
+	err = i.invitationRepository.CreateInvitation(req.TodoID, user, invitee, req.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation sent successfully"})
+}
+
+func (i *InvitationRoute) GetInvitations(c *gin.Context) {
+	user, err := i.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	invitations, err := i.invitationRepository.GetInvitationsByUser(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// AcceptInvitation adds the todo of the invitation to the list of the user
+func (i *InvitationRoute) AcceptInvitation(c *gin.Context) {
+	user, err := i.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	invitationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation id"})
+		return
+	}
+
+	invitation, err := i.pendingInvitation(invitationID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the invitation is only used up if the todo could be shared
+	err = i.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Invitations().AcceptInvitation(invitationID, user)
+		if err != nil || invitation == nil {
+			return err
+		}
+
+		// the todo is shared once the invitation is accepted, not when it is sent
+		return recordEvent(tx, types.TodoEvent{TodoID: invitation.TodoID, Action: types.EventShared,
+			Subject: &user.Username, Changes: map[string]types.FieldChange{"role": {Old: nil, New: invitation.Role}}}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted successfully"})
+}
+
+func (i *InvitationRoute) DeclineInvitation(c *gin.Context) {
+	user, err := i.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	invitationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation id"})
+		return
+	}
+
+	err = i.invitationRepository.DeclineInvitation(invitationID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation declined successfully"})
+}
+
+func (i *InvitationRoute) GetBlockedUsers(c *gin.Context) {
+	user, err := i.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	usernames, err := i.invitationRepository.GetBlockedUsers(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, usernames)
+}
+
+// BlockUser stops the invitations of another user
+func (i *InvitationRoute) BlockUser(c *gin.Context) {
+	user, err := i.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	var req types.BlockUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if req.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'username' must not be empty"})
+		return
+	}
+
+	blockedUser, err := i.userRepository.GetUserByUsername(req.Username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve user to block"})
+		return
+	}
+
+	err = i.invitationRepository.BlockUser(user, blockedUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked successfully"})
+}
+
+func (i *InvitationRoute) UnblockUser(c *gin.Context) {
+	user, err := i.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	blockedUser, err := i.userRepository.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve user to unblock"})
+		return
+	}
+
+	err = i.invitationRepository.UnblockUser(user, blockedUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked successfully"})
+}
+
+// pendingInvitation returns the pending invitation of the user with the id, nil if there is none
+func (i *InvitationRoute) pendingInvitation(invitationID int, user *types.User) (*types.Invitation, error) {
+	invitations, err := i.invitationRepository.GetInvitationsByUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, invitation := range invitations {
+		if invitation.ID == invitationID {
+			return &invitation, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,322 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// errPatchTestFailed is returned if a test operation of a JSON Patch does not match the document
+var errPatchTestFailed = errors.New("a 'test' operation of the patch failed")
+
+// patchOperation is one operation of a JSON Patch (RFC 6902). Value stays nil if the operation has no value,
+// an explicit null is kept as raw json.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyMergePatch applies a JSON Merge Patch (RFC 7396) to a decoded json document
+func applyMergePatch(document interface{}, patch []byte) (interface{}, error) {
+	var decoded interface{}
+	err := json.Unmarshal(patch, &decoded)
+	if err != nil {
+		return nil, errors.New("the merge patch is no valid json")
+	}
+
+	return mergePatch(document, decoded), nil
+}
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+// applyJSONPatch applies a JSON Patch (RFC 6902) to a decoded json document. The operations are applied in order,
+// if one fails the whole patch fails.
+func applyJSONPatch(document interface{}, patch []byte) (interface{}, error) {
+	var operations []patchOperation
+	err := json.Unmarshal(patch, &operations)
+	if err != nil {
+		return nil, errors.New("the json patch must be an array of operations")
+	}
+
+	for i, operation := range operations {
+		document, err = applyPatchOperation(document, operation)
+		if errors.Is(err, errPatchTestFailed) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d of the json patch: %s", i, err.Error())
+		}
+	}
+
+	return document, nil
+}
+
+func applyPatchOperation(document interface{}, operation patchOperation) (interface{}, error) {
+	path, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		if operation.Value == nil {
+			return nil, fmt.Errorf("'%s' requires a value", operation.Op)
+		}
+
+		var value interface{}
+		err = json.Unmarshal(operation.Value, &value)
+		if err != nil {
+			return nil, err
+		}
+
+		if operation.Op == "add" || (operation.Op == "replace" && len(path) == 0) {
+			return pointerAdd(document, path, value)
+		}
+
+		current, err := pointerGet(document, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if operation.Op == "test" {
+			if !reflect.DeepEqual(current, value) {
+				return nil, errPatchTestFailed
+			}
+			return document, nil
+		}
+
+		document, _, err = pointerRemove(document, path)
+		if err != nil {
+			return nil, err
+		}
+
+		return pointerAdd(document, path, value)
+	case "remove":
+		document, _, err = pointerRemove(document, path)
+		return document, err
+	case "move", "copy":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+
+		var value interface{}
+		if operation.Op == "move" {
+			if len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+				return nil, errors.New("a value can not be moved into itself")
+			}
+
+			document, value, err = pointerRemove(document, from)
+		} else {
+			value, err = pointerGet(document, from)
+			if err == nil {
+				value, err = copyValue(value)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return pointerAdd(document, path, value)
+	default:
+		return nil, fmt.Errorf("unknown operation '%s'", operation.Op)
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped reference tokens, the empty pointer is the root
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("'%s' is no json pointer", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func pointerGet(node interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch current := node.(type) {
+		case map[string]interface{}:
+			value, ok := current[token]
+			if !ok {
+				return nil, fmt.Errorf("'%s' does not exist", token)
+			}
+			node = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(current)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = current[index]
+		default:
+			return nil, fmt.Errorf("'%s' does not exist", token)
+		}
+	}
+
+	return node, nil
+}
+
+// pointerAdd returns the node with the value added at the path, arrays are copied as their length changes
+func pointerAdd(node interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token := path[0]
+	switch current := node.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			current[token] = value
+			return current, nil
+		}
+
+		child, ok := current[token]
+		if !ok {
+			return nil, fmt.Errorf("'%s' does not exist", token)
+		}
+
+		child, err := pointerAdd(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		current[token] = child
+
+		return current, nil
+	case []interface{}:
+		if len(path) == 1 {
+			index := len(current)
+			if token != "-" {
+				var err error
+				index, err = arrayIndex(token, len(current))
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			result := append([]interface{}{}, current[:index]...)
+			result = append(result, value)
+			return append(result, current[index:]...), nil
+		}
+
+		index, err := arrayIndex(token, len(current)-1)
+		if err != nil {
+			return nil, err
+		}
+
+		child, err := pointerAdd(current[index], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		current[index] = child
+
+		return current, nil
+	default:
+		return nil, fmt.Errorf("'%s' does not exist", token)
+	}
+}
+
+// pointerRemove returns the node without the value at the path together with the removed value
+func pointerRemove(node interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("the whole document can not be removed")
+	}
+
+	token := path[0]
+	switch current := node.(type) {
+	case map[string]interface{}:
+		child, ok := current[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("'%s' does not exist", token)
+		}
+
+		if len(path) == 1 {
+			delete(current, token)
+			return current, child, nil
+		}
+
+		child, removed, err := pointerRemove(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		current[token] = child
+
+		return current, removed, nil
+	case []interface{}:
+		index, err := arrayIndex(token, len(current)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(path) == 1 {
+			result := append([]interface{}{}, current[:index]...)
+			return append(result, current[index+1:]...), current[index], nil
+		}
+
+		child, removed, err := pointerRemove(current[index], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		current[index] = child
+
+		return current, removed, nil
+	default:
+		return nil, nil, fmt.Errorf("'%s' does not exist", token)
+	}
+}
+
+// arrayIndex parses an array index of a json pointer, indexes have no leading zeros
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max || strconv.Itoa(index) != token {
+		return 0, fmt.Errorf("'%s' is no valid index", token)
+	}
+
+	return index, nil
+}
+
+// copyValue copies a decoded json value, so a copied value does not change with the original
+func copyValue(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	err = json.Unmarshal(data, &result)
+
+	return result, err
+}
@@ -0,0 +1,137 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	testcases := []struct {
+		name     string
+		document string
+		patch    string
+		expected string
+	}{
+		{name: "should replace values", document: `{"a":"b"}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		{name: "should add values", document: `{"a":"b"}`, patch: `{"b":"c"}`, expected: `{"a":"b","b":"c"}`},
+		{name: "should remove null values", document: `{"a":"b","b":"c"}`, patch: `{"a":null}`, expected: `{"b":"c"}`},
+		{name: "should merge objects", document: `{"a":{"b":"c","d":"e"}}`, patch: `{"a":{"d":null,"f":"g"}}`, expected: `{"a":{"b":"c","f":"g"}}`},
+		{name: "should replace arrays", document: `{"a":["b"]}`, patch: `{"a":["c","d"]}`, expected: `{"a":["c","d"]}`},
+		{name: "should replace non objects", document: `{"a":"b"}`, patch: `{"a":{"b":null,"c":"d"}}`, expected: `{"a":{"c":"d"}}`},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			result, err := applyMergePatch(decodeJSON(t, tc.document), []byte(tc.patch))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			if !reflect.DeepEqual(result, decodeJSON(t, tc.expected)) {
+				t.Errorf("Expected %s, but got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	testcases := []struct {
+		name          string
+		document      string
+		patch         string
+		expected      string
+		expectedError bool
+	}{
+		{name: "should add to objects", document: `{"foo":"bar"}`,
+			patch:    `[{"op":"add","path":"/baz","value":"qux"}]`,
+			expected: `{"baz":"qux","foo":"bar"}`},
+		{name: "should insert into arrays", document: `{"foo":["bar","baz"]}`,
+			patch:    `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			expected: `{"foo":["bar","qux","baz"]}`},
+		{name: "should append to arrays", document: `{"foo":["bar"]}`,
+			patch:    `[{"op":"add","path":"/foo/-","value":"qux"}]`,
+			expected: `{"foo":["bar","qux"]}`},
+		{name: "should remove values", document: `{"baz":"qux","foo":"bar"}`,
+			patch:    `[{"op":"remove","path":"/baz"}]`,
+			expected: `{"foo":"bar"}`},
+		{name: "should replace values", document: `{"baz":"qux","foo":"bar"}`,
+			patch:    `[{"op":"replace","path":"/baz","value":null}]`,
+			expected: `{"baz":null,"foo":"bar"}`},
+		{name: "should move values", document: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch:    `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			expected: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{name: "should copy values", document: `{"foo":{"bar":"baz"}}`,
+			patch:    `[{"op":"copy","from":"/foo","path":"/qux"},{"op":"add","path":"/qux/bar","value":1}]`,
+			expected: `{"foo":{"bar":"baz"},"qux":{"bar":1}}`},
+		{name: "should unescape pointers", document: `{"a/b":1,"m~n":2}`,
+			patch:    `[{"op":"remove","path":"/a~1b"},{"op":"replace","path":"/m~0n","value":3}]`,
+			expected: `{"m~n":3}`},
+		{name: "should pass matching tests", document: `{"baz":"qux"}`,
+			patch:    `[{"op":"test","path":"/baz","value":"qux"}]`,
+			expected: `{"baz":"qux"}`},
+		{name: "should not replace missing values", document: `{"foo":"bar"}`,
+			patch:         `[{"op":"replace","path":"/baz","value":"qux"}]`,
+			expectedError: true},
+		{name: "should not add to missing parents", document: `{"foo":"bar"}`,
+			patch:         `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			expectedError: true},
+		{name: "should require a value", document: `{"foo":"bar"}`,
+			patch:         `[{"op":"add","path":"/baz"}]`,
+			expectedError: true},
+		{name: "should reject indexes with leading zeros", document: `{"foo":["bar","baz"]}`,
+			patch:         `[{"op":"remove","path":"/foo/01"}]`,
+			expectedError: true},
+		{name: "should reject unknown operations", document: `{"foo":"bar"}`,
+			patch:         `[{"op":"merge","path":"/foo","value":"baz"}]`,
+			expectedError: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			result, err := applyJSONPatch(decodeJSON(t, tc.document), []byte(tc.patch))
+
+			// Assert
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("Expected an error, but got %v", result)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			if !reflect.DeepEqual(result, decodeJSON(t, tc.expected)) {
+				t.Errorf("Expected %s, but got %v", tc.expected, result)
+			}
+		})
+	}
+
+	t.Run("should fail the patch if a test does not match", func(t *testing.T) {
+		// Act
+		_, err := applyJSONPatch(decodeJSON(t, `{"baz":"qux"}`),
+			[]byte(`[{"op":"replace","path":"/baz","value":"bar"},{"op":"test","path":"/baz","value":"qux"}]`))
+
+		// Assert
+		if !errors.Is(err, errPatchTestFailed) {
+			t.Errorf("Expected the test to fail, but got %v", err)
+		}
+	})
+}
+
+func decodeJSON(t *testing.T, value string) interface{} {
+	var decoded interface{}
+	err := json.Unmarshal([]byte(value), &decoded)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when decoding %s", err, value)
+	}
+
+	return decoded
+}
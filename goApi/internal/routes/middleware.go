@@ -2,13 +2,18 @@ package routes
 
 import (
 	"errors"
+	"github.com/floxo05/todoapi/internal/types"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 )
 
+// workspaceHeader selects the workspace a request works in, without it the request works in the personal space
+const workspaceHeader = "X-Workspace-ID"
+
 func JWTAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -55,3 +60,47 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// WorkspaceMiddleware makes the workspace of the X-Workspace-ID header the active workspace of the request.
+// It has to run after JWTAuthMiddleware.
+func WorkspaceMiddleware(workspaceRepository types.WorkspaceRepository, userContextHelper types.UserContextInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader(workspaceHeader)
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		workspaceID, err := strconv.Atoi(header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace id"})
+			c.Abort()
+			return
+		}
+
+		user, err := userContextHelper.GetUserFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		role, err := workspaceRepository.WorkspaceRole(workspaceID, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		if role == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "user is not a member of the workspace"})
+			c.Abort()
+			return
+		}
+
+		c.Set("workspace_id", workspaceID)
+		c.Set("workspace_role", role)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,210 @@
+package routes
+
+import (
+	"github.com/floxo05/todoapi/internal/types"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type OwnershipRoute struct {
+	ownershipRepository types.OwnershipRepository
+	userRepository      types.UserRepository
+	transactionManager  types.TransactionManager
+	userContextHelper   types.UserContextInterface
+}
+
+func NewOwnershipRoute(ownershipRepository types.OwnershipRepository, userRepository types.UserRepository, transactionManager types.TransactionManager, userContextHelper types.UserContextInterface) *OwnershipRoute {
+	return &OwnershipRoute{
+		ownershipRepository: ownershipRepository,
+		userRepository:      userRepository,
+		transactionManager:  transactionManager,
+		userContextHelper:   userContextHelper,
+	}
+}
+
+// RequestTransfer offers the ownership of a todo to another user, it changes once they accept
+func (o *OwnershipRoute) RequestTransfer(c *gin.Context) {
+	o.transfer(c, false)
+}
+
+// ForceTransfer lets an admin of the workspace give a todo to another member right away
+func (o *OwnershipRoute) ForceTransfer(c *gin.Context) {
+	o.transfer(c, true)
+}
+
+func (o *OwnershipRoute) transfer(c *gin.Context, force bool) {
+	user, err := o.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo id"})
+		return
+	}
+
+	var req types.TransferOwnershipRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if req.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'username' must not be empty"})
+		return
+	}
+
+	recipient, err := o.userRepository.GetUserByUsername(req.Username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve recipient"})
+		return
+	}
+
+	if !force {
+		err = o.transactionManager.WithTransaction(func(tx types.Transaction) error {
+			return tx.Ownership().RequestTransfer(todoID, user, recipient)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Transfer requested successfully"})
+		return
+	}
+
+	err = o.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Ownership().ForceTransfer(todoID, user, recipient)
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(tx, types.TodoEvent{TodoID: todoID, Action: types.EventTransferred,
+			Subject: &recipient.Username}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Todo transferred successfully"})
+}
+
+// GetTransfers lists the pending transfers the user sent or received
+func (o *OwnershipRoute) GetTransfers(c *gin.Context) {
+	user, err := o.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	transfers, err := o.ownershipRepository.GetTransfersByUser(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfers)
+}
+
+func (o *OwnershipRoute) AcceptTransfer(c *gin.Context) {
+	user, err := o.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	transferID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer id"})
+		return
+	}
+
+	transfer, err := o.pendingTransfer(transferID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the todo and all of its subtasks change their owner together
+	err = o.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Ownership().AcceptTransfer(transferID, user)
+		if err != nil || transfer == nil {
+			return err
+		}
+
+		return recordEvent(tx, types.TodoEvent{TodoID: transfer.TodoID, Action: types.EventTransferred,
+			Subject: &user.Username}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer accepted successfully"})
+}
+
+// DeclineTransfer declines a received transfer or cancels a sent one
+func (o *OwnershipRoute) DeclineTransfer(c *gin.Context) {
+	user, err := o.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	transferID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer id"})
+		return
+	}
+
+	err = o.ownershipRepository.DeclineTransfer(transferID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer declined successfully"})
+}
+
+// GetTransferHistory lists the previous owners of a todo
+func (o *OwnershipRoute) GetTransferHistory(c *gin.Context) {
+	user, err := o.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo id"})
+		return
+	}
+
+	transfers, err := o.ownershipRepository.GetTransferHistory(todoID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfers)
+}
+
+// pendingTransfer returns the pending transfer of the user with the id, nil if there is none
+func (o *OwnershipRoute) pendingTransfer(transferID int, user *types.User) (*types.OwnershipTransfer, error) {
+	transfers, err := o.ownershipRepository.GetTransfersByUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, transfer := range transfers {
+		if transfer.ID == transferID {
+			return &transfer, nil
+		}
+	}
+
+	return nil, nil
+}
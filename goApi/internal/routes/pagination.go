@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"errors"
+	"github.com/floxo05/todoapi/internal/types"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+// parsePageRequest reads the limit, cursor and count query parameters.
+// It returns nil if none of them is given, listings then answer with a plain array like before pagination existed.
+// Without a limit the page has defaultPageLimit items, also if only count is given.
+func parsePageRequest(c *gin.Context) (*types.PageRequest, error) {
+	limit, hasLimit := c.GetQuery("limit")
+	cursor, hasCursor := c.GetQuery("cursor")
+	_, hasCount := c.GetQuery("count")
+	if !hasLimit && !hasCursor && !hasCount {
+		return nil, nil
+	}
+
+	page := types.PageRequest{Limit: defaultPageLimit, Cursor: cursor}
+	if hasLimit {
+		var err error
+		page.Limit, err = strconv.Atoi(limit)
+		if err != nil || page.Limit < 1 || page.Limit > maxPageLimit {
+			return nil, errors.New("'limit' must be a number between 1 and 100")
+		}
+	}
+
+	if value := c.Query("count"); value != "" {
+		withTotal, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.New("'count' must be true or false")
+		}
+		page.WithTotal = withTotal
+	}
+
+	return &page, nil
+}
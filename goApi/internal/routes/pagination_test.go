@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePageRequest(t *testing.T) {
+	t.Run("should page with the default limit if only count is given", func(t *testing.T) {
+		// Arrange
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/todos?count=true", nil)
+
+		// Act
+		page, err := parsePageRequest(c)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if page == nil || page.Limit != defaultPageLimit || !page.WithTotal {
+			t.Errorf("Expected a page of %d with total, but got %+v", defaultPageLimit, page)
+		}
+	})
+
+	t.Run("should return nil without paging parameters", func(t *testing.T) {
+		// Arrange
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/todos", nil)
+
+		// Act
+		page, err := parsePageRequest(c)
+
+		// Assert
+		if err != nil || page != nil {
+			t.Errorf("Expected no page, but got %+v and %v", page, err)
+		}
+	})
+}
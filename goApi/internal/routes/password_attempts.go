@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"sync"
+	"time"
+)
+
+// passwordAttempts counts the failed password attempts per key within a fixed window. Once a key reached the limit,
+// further attempts are rejected until the window is over, even with the correct password.
+type passwordAttempts struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	failures map[string]*attemptWindow
+}
+
+type attemptWindow struct {
+	start time.Time
+	count int
+}
+
+func newPasswordAttempts(limit int, window time.Duration) *passwordAttempts {
+	return &passwordAttempts{limit: limit, window: window, failures: map[string]*attemptWindow{}}
+}
+
+// retryAfter returns how long the key is blocked, zero if it may try again
+func (p *passwordAttempts) retryAfter(key string, now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	failures, ok := p.failures[key]
+	if !ok || failures.count < p.limit {
+		return 0
+	}
+
+	remaining := failures.start.Add(p.window).Sub(now)
+	if remaining <= 0 {
+		return 0
+	}
+
+	return remaining
+}
+
+// fail records a failed attempt of the key
+func (p *passwordAttempts) fail(key string, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// windows that are over are dropped, so the map only holds keys that failed recently
+	for k, failures := range p.failures {
+		if !now.Before(failures.start.Add(p.window)) {
+			delete(p.failures, k)
+		}
+	}
+
+	failures, ok := p.failures[key]
+	if !ok {
+		p.failures[key] = &attemptWindow{start: now, count: 1}
+		return
+	}
+
+	failures.count++
+}
@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPasswordAttempts(t *testing.T) {
+	now := time.Date(2024, 5, 15, 13, 30, 0, 0, time.UTC)
+
+	t.Run("should block a key after too many failures until the window is over", func(t *testing.T) {
+		// Arrange
+		attempts := newPasswordAttempts(2, time.Minute)
+
+		// Act
+		attempts.fail("token", now)
+		afterOne := attempts.retryAfter("token", now)
+		attempts.fail("token", now.Add(10*time.Second))
+		afterTwo := attempts.retryAfter("token", now.Add(10*time.Second))
+		afterWindow := attempts.retryAfter("token", now.Add(time.Minute))
+
+		// Assert
+		if afterOne != 0 {
+			t.Errorf("Expected the key not to be blocked after one failure, but got %s", afterOne)
+		}
+
+		if afterTwo != 50*time.Second {
+			t.Errorf("Expected the key to be blocked for 50s, but got %s", afterTwo)
+		}
+
+		if afterWindow != 0 {
+			t.Errorf("Expected the key not to be blocked after the window, but got %s", afterWindow)
+		}
+	})
+
+	t.Run("should count the keys separately", func(t *testing.T) {
+		// Arrange
+		attempts := newPasswordAttempts(1, time.Minute)
+
+		// Act
+		attempts.fail("token", now)
+
+		// Assert
+		if wait := attempts.retryAfter("other", now); wait != 0 {
+			t.Errorf("Expected the other key not to be blocked, but got %s", wait)
+		}
+	})
+}
@@ -0,0 +1,260 @@
+package routes
+
+import (
+	"errors"
+	"github.com/floxo05/todoapi/internal/types"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// passwordHeader carries the password of a protected public link
+const passwordHeader = "X-Link-Password"
+
+// a link is locked for the rest of the window after this many wrong passwords
+const (
+	maxPasswordFailures   = 5
+	passwordFailureWindow = 15 * time.Minute
+)
+
+type PublicLinkRoute struct {
+	publicLinkRepository types.PublicLinkRepository
+	todoRepository       types.TodoRepository
+	categoryRepository   types.CategoryRepository
+	passwordHasher       types.PasswordHasherInterface
+	userContextHelper    types.UserContextInterface
+	passwordAttempts     *passwordAttempts
+}
+
+func NewPublicLinkRoute(publicLinkRepository types.PublicLinkRepository, todoRepository types.TodoRepository, categoryRepository types.CategoryRepository, passwordHasher types.PasswordHasherInterface, userContextHelper types.UserContextInterface) *PublicLinkRoute {
+	return &PublicLinkRoute{
+		publicLinkRepository: publicLinkRepository,
+		todoRepository:       todoRepository,
+		categoryRepository:   categoryRepository,
+		passwordHasher:       passwordHasher,
+		userContextHelper:    userContextHelper,
+		passwordAttempts:     newPasswordAttempts(maxPasswordFailures, passwordFailureWindow),
+	}
+}
+
+func (p *PublicLinkRoute) CreatePublicLink(c *gin.Context) {
+	user, err := p.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req types.CreatePublicLinkRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if (req.TodoID == nil) == (req.CategoryID == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either 'todo_id' or 'category_id' is required"})
+		return
+	}
+
+	link := types.PublicLink{TodoID: req.TodoID, CategoryID: req.CategoryID}
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'expires_at' must be in the future"})
+			return
+		}
+
+		expiresAt := req.ExpiresAt.UTC().Truncate(time.Second)
+		link.ExpiresAt = &expiresAt
+	}
+
+	if req.Password != "" {
+		link.Password, err = p.passwordHasher.HashPassword(req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	err = p.publicLinkRepository.CreatePublicLink(&link, user)
+	if err != nil {
+		respondPublicLinkError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, link)
+}
+
+func (p *PublicLinkRoute) GetPublicLinks(c *gin.Context) {
+	user, err := p.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	links, err := p.publicLinkRepository.GetPublicLinksByUser(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+func (p *PublicLinkRoute) RevokePublicLink(c *gin.Context) {
+	user, err := p.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	linkID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid public link id"})
+		return
+	}
+
+	err = p.publicLinkRepository.RevokePublicLink(linkID, user)
+	if err != nil {
+		respondPublicLinkError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Public link revoked successfully"})
+}
+
+// GetPublicContent shows the todo or the category of a link without authentication. The content is what the
+// creator of the link sees, the link stops working when the creator is no manager anymore.
+func (p *PublicLinkRoute) GetPublicContent(c *gin.Context) {
+	link, err := p.publicLinkRepository.GetPublicLinkByToken(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if link == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+		return
+	}
+
+	if link.HasPassword {
+		// the attempts are counted per link, so guessing from many addresses does not help
+		now := time.Now()
+		if wait := p.passwordAttempts.retryAfter(link.Token, now); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many wrong passwords, try again later"})
+			return
+		}
+
+		if err = p.passwordHasher.ComparePasswords(link.Password, c.GetHeader(passwordHeader)); err != nil {
+			p.passwordAttempts.fail(link.Token, now)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the link needs the correct password"})
+			return
+		}
+	}
+
+	// the link shows what its creator sees in the workspace of the link
+	creator := &types.User{ID: link.CreatedUserID, WorkspaceID: link.WorkspaceID}
+	var content gin.H
+	if link.TodoID != nil {
+		var todo *types.Todo
+		todo, err = p.todoRepository.GetTodoTree(*link.TodoID, creator)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+			return
+		}
+
+		content = gin.H{"todo": publicTodo(todo)}
+	} else {
+		content, err = p.publicCategory(*link.CategoryID, creator)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if content == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+			return
+		}
+	}
+
+	err = p.publicLinkRepository.CountAccess(link)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, content)
+}
+
+// respondPublicLinkError answers 404 for unknown links, 403 if the user may not manage the link and 500 otherwise
+func respondPublicLinkError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, types.ErrPublicLinkNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, types.ErrPermissionDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// publicCategory returns the title and the todos of the category with their subtasks, nil if the creator has no
+// access anymore
+func (p *PublicLinkRoute) publicCategory(categoryID int, creator *types.User) (gin.H, error) {
+	role, err := p.categoryRepository.CategoryRole(categoryID, creator)
+	if err != nil || role == "" {
+		return nil, err
+	}
+
+	category, err := p.categoryRepository.GetCategoryByID(categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	todos, err := p.todoRepository.GetAllTodosByUser(creator, types.TodoFilter{CategoryID: &categoryID})
+	if err != nil {
+		return nil, err
+	}
+
+	inCategory := map[int]bool{}
+	for _, todo := range todos {
+		inCategory[todo.ID] = true
+	}
+
+	// subtasks are shown below their parent
+	publicTodos := []types.PublicTodo{}
+	for _, todo := range todos {
+		if todo.ParentID != nil && inCategory[*todo.ParentID] {
+			continue
+		}
+
+		var tree *types.Todo
+		tree, err = p.todoRepository.GetTodoTree(todo.ID, creator)
+		if err != nil {
+			return nil, err
+		}
+
+		publicTodos = append(publicTodos, publicTodo(tree))
+	}
+
+	return gin.H{"category": category.Title, "todos": publicTodos}, nil
+}
+
+func publicTodo(todo *types.Todo) types.PublicTodo {
+	public := types.PublicTodo{
+		Title:       todo.Title,
+		Completed:   todo.Completed,
+		DueAt:       todo.DueAt,
+		DueHasTime:  todo.DueHasTime,
+		Priority:    todo.Priority,
+		Description: todo.Description,
+		Subtasks:    []types.PublicTodo{},
+	}
+
+	for i := range todo.Subtasks {
+		public.Subtasks = append(public.Subtasks, publicTodo(&todo.Subtasks[i]))
+	}
+
+	return public
+}
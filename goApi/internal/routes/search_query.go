@@ -0,0 +1,167 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/floxo05/todoapi/internal/types"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// parseSearchQuery parses the query language of GET /auth/todos/search.
+// Plain words and "quoted phrases" are matched against the todo text, filters have the form key:value:
+//
+//	category:work  category:"side project"
+//	is:done  is:open
+//	shared:yes  shared:no
+//	due:2024-06-01  due:<2024-06-01  due:>2024-06-01  due:today  due:tomorrow  due:week
+//
+// Everything with an unknown key is treated as a word.
+func parseSearchQuery(query string, now time.Time) (*types.SearchQuery, error) {
+	parsed := types.SearchQuery{}
+
+	terms, err := splitSearchTerms(query)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, term := range terms {
+		if term.quoted {
+			parsed.Phrases = append(parsed.Phrases, term.value)
+			continue
+		}
+
+		key, value, found := strings.Cut(term.value, ":")
+		if !found {
+			parsed.Words = append(parsed.Words, term.value)
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "category":
+			parsed.Category = value
+		case "is":
+			switch strings.ToLower(value) {
+			case "done", "completed":
+				completed := true
+				parsed.Completed = &completed
+			case "open", "todo":
+				completed := false
+				parsed.Completed = &completed
+			default:
+				return nil, fmt.Errorf("'is:%s' is unknown, use is:done or is:open", value)
+			}
+		case "shared":
+			switch strings.ToLower(value) {
+			case "yes", "true":
+				shared := true
+				parsed.Shared = &shared
+			case "no", "false":
+				shared := false
+				parsed.Shared = &shared
+			default:
+				return nil, fmt.Errorf("'shared:%s' is unknown, use shared:yes or shared:no", value)
+			}
+		case "due":
+			parsed.DueFrom, parsed.DueBefore, err = parseDueRange(value, now)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			parsed.Words = append(parsed.Words, term.value)
+		}
+	}
+
+	return &parsed, nil
+}
+
+// parseDueRange converts the value of a due: filter into the range [from, before)
+func parseDueRange(value string, now time.Time) (*time.Time, *time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var from, before time.Time
+	switch strings.ToLower(value) {
+	case "today":
+		from, before = today, today.AddDate(0, 0, 1)
+		return &from, &before, nil
+	case "tomorrow":
+		from, before = today.AddDate(0, 0, 1), today.AddDate(0, 0, 2)
+		return &from, &before, nil
+	case "week":
+		// weeks start on monday
+		from = today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		before = from.AddDate(0, 0, 7)
+		return &from, &before, nil
+	}
+
+	comparison := ""
+	if strings.HasPrefix(value, "<") || strings.HasPrefix(value, ">") {
+		comparison, value = value[:1], value[1:]
+	}
+
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, nil, errors.New("'due:' needs a date (YYYY-MM-DD, optionally prefixed with < or >), today, tomorrow or week")
+	}
+
+	switch comparison {
+	case "<":
+		return nil, &day, nil
+	case ">":
+		from = day.AddDate(0, 0, 1)
+		return &from, nil, nil
+	}
+
+	before = day.AddDate(0, 0, 1)
+	return &day, &before, nil
+}
+
+type searchTerm struct {
+	value  string
+	quoted bool
+}
+
+// splitSearchTerms splits the query at whitespace, quoted parts stay together.
+// A quote directly after a colon belongs to the filter value, e.g. category:"side project".
+func splitSearchTerms(query string) ([]searchTerm, error) {
+	var terms []searchTerm
+	var current strings.Builder
+	inQuotes, quotedTerm := false, false
+
+	flush := func() {
+		if current.Len() > 0 {
+			terms = append(terms, searchTerm{value: current.String(), quoted: quotedTerm})
+		}
+		current.Reset()
+		quotedTerm = false
+	}
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			if inQuotes {
+				inQuotes = false
+				flush()
+				continue
+			}
+
+			inQuotes = true
+			if !strings.HasSuffix(current.String(), ":") {
+				flush()
+				quotedTerm = true
+			}
+		case unicode.IsSpace(r) && !inQuotes:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if inQuotes {
+		return nil, errors.New("the search query contains an unclosed quote")
+	}
+	flush()
+
+	return terms, nil
+}
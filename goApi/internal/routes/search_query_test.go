@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	// a wednesday
+	now := time.Date(2024, 5, 15, 13, 30, 0, 0, time.UTC)
+
+	t.Run("should split words, phrases and filters", func(t *testing.T) {
+		// Act
+		query, err := parseSearchQuery(`report "q3 numbers" category:"side project" is:open shared:yes 12:30`, now)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		if !reflect.DeepEqual(query.Words, []string{"report", "12:30"}) {
+			t.Errorf("Expected words [report 12:30], but got %v", query.Words)
+		}
+
+		if !reflect.DeepEqual(query.Phrases, []string{"q3 numbers"}) {
+			t.Errorf("Expected phrases [q3 numbers], but got %v", query.Phrases)
+		}
+
+		if query.Category != "side project" {
+			t.Errorf("Expected category 'side project', but got '%s'", query.Category)
+		}
+
+		if query.Completed == nil || *query.Completed || query.Shared == nil || !*query.Shared {
+			t.Errorf("Expected open and shared todos, but got %+v", query)
+		}
+	})
+
+	t.Run("should parse due ranges", func(t *testing.T) {
+		testcases := []struct {
+			query          string
+			expectedFrom   *time.Time
+			expectedBefore *time.Time
+		}{
+			{query: "due:2024-06-01", expectedFrom: day(2024, 6, 1), expectedBefore: day(2024, 6, 2)},
+			{query: "due:<2024-06-01", expectedBefore: day(2024, 6, 1)},
+			{query: "due:>2024-06-01", expectedFrom: day(2024, 6, 2)},
+			{query: "due:today", expectedFrom: day(2024, 5, 15), expectedBefore: day(2024, 5, 16)},
+			{query: "due:week", expectedFrom: day(2024, 5, 13), expectedBefore: day(2024, 5, 20)},
+		}
+
+		for _, tc := range testcases {
+			query, err := parseSearchQuery(tc.query, now)
+			if err != nil {
+				t.Fatalf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			if !reflect.DeepEqual(query.DueFrom, tc.expectedFrom) || !reflect.DeepEqual(query.DueBefore, tc.expectedBefore) {
+				t.Errorf("%s: expected [%v, %v), but got [%v, %v)", tc.query, tc.expectedFrom, tc.expectedBefore, query.DueFrom, query.DueBefore)
+			}
+		}
+	})
+
+	t.Run("should reject invalid queries", func(t *testing.T) {
+		for _, q := range []string{`"unclosed`, "is:maybe", "shared:often", "due:someday"} {
+			if _, err := parseSearchQuery(q, now); err == nil {
+				t.Errorf("%s: expected an error, but got nil", q)
+			}
+		}
+	})
+}
+
+func day(year int, month time.Month, d int) *time.Time {
+	date := time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	return &date
+}
@@ -0,0 +1,267 @@
+package routes
+
+import (
+	"errors"
+	"github.com/floxo05/todoapi/internal/types"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const maxTagTitleLength = 50
+
+type TagRoute struct {
+	tagRepository      types.TagRepository
+	transactionManager types.TransactionManager
+	userContextHelper  types.UserContextInterface
+}
+
+func NewTagRoute(tagRepo types.TagRepository, transactionManager types.TransactionManager, userContextHelper types.UserContextInterface) *TagRoute {
+	return &TagRoute{tagRepository: tagRepo, transactionManager: transactionManager, userContextHelper: userContextHelper}
+}
+
+func (tr *TagRoute) CreateTag(c *gin.Context) {
+	user, err := tr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req types.TagRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	title, err := validTagTitle(req.Title)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag := types.Tag{Title: title, CreatedUserId: user.ID}
+	err = tr.tagRepository.CreateTag(&tag)
+	if errors.Is(err, types.ErrTagExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+func (tr *TagRoute) GetTags(c *gin.Context) {
+	user, err := tr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tags, err := tr.tagRepository.GetTagsByUserId(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if tags == nil {
+		tags = []types.Tag{}
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+func (tr *TagRoute) RenameTag(c *gin.Context) {
+	user, err := tr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tagId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var req types.TagRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	title, err := validTagTitle(req.Title)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag := types.Tag{ID: tagId, Title: title}
+	err = tr.tagRepository.RenameTag(&tag, user)
+	if errors.Is(err, types.ErrTagExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// MergeTags moves the todos of the tag in the path to the target tag and deletes it
+func (tr *TagRoute) MergeTags(c *gin.Context) {
+	user, err := tr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tagId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var req types.MergeTagsRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if req.TargetID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'target_id' must be a tag id"})
+		return
+	}
+
+	err = tr.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		return tx.Tags().MergeTags(&types.Tag{ID: tagId}, &types.Tag{ID: req.TargetID}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tags merged successfully"})
+}
+
+func (tr *TagRoute) DeleteTag(c *gin.Context) {
+	user, err := tr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tagId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	err = tr.tagRepository.DeleteTag(&types.Tag{ID: tagId}, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
+
+func (tr *TagRoute) AttachTag(c *gin.Context) {
+	user, err := tr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var req types.AttachTagRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if req.TagID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'tag_id' must be a tag id"})
+		return
+	}
+
+	err = tr.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Tags().AttachTag(todoId, &types.Tag{ID: req.TagID}, user)
+		if err != nil {
+			return err
+		}
+
+		// tags are private, the event does not tell which tag it was
+		return recordEvent(tx, types.TodoEvent{TodoID: todoId, Action: types.EventTagged}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag attached successfully"})
+}
+
+func (tr *TagRoute) DetachTag(c *gin.Context) {
+	user, err := tr.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	tagId, err := strconv.Atoi(c.Param("tagId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	err = tr.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Tags().DetachTag(todoId, &types.Tag{ID: tagId}, user)
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(tx, types.TodoEvent{TodoID: todoId, Action: types.EventUntagged}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag detached successfully"})
+}
+
+// validTagTitle trims the title, commas are not allowed because the tag filter is a comma separated list
+func validTagTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+
+	if title == "" {
+		return "", errors.New("'title' must not be empty")
+	}
+
+	if len(title) > maxTagTitleLength {
+		return "", errors.New("'title' must not be longer than 50 characters")
+	}
+
+	if strings.Contains(title, ",") {
+		return "", errors.New("'title' must not contain a comma")
+	}
+
+	return title, nil
+}
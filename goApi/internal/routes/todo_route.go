@@ -1,20 +1,42 @@
 package routes
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/floxo05/todoapi/internal/types"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// descriptions are stored in a TEXT column
+const maxDescriptionLength = 65535
+
 type TodoRoute struct {
-	todoRepository    types.TodoRepository
-	userContextHelper types.UserContextInterface
+	todoRepository     types.TodoRepository
+	transactionManager types.TransactionManager
+	userContextHelper  types.UserContextInterface
+	recurrence         types.RecurrenceInterface
+	markdownRenderer   types.MarkdownRendererInterface
 }
 
-func NewTodoRoute(todoRepository types.TodoRepository, userContextHelper types.UserContextInterface) *TodoRoute {
-	return &TodoRoute{todoRepository: todoRepository, userContextHelper: userContextHelper}
+func NewTodoRoute(
+	todoRepository types.TodoRepository,
+	transactionManager types.TransactionManager,
+	userContextHelper types.UserContextInterface,
+	recurrence types.RecurrenceInterface,
+	markdownRenderer types.MarkdownRendererInterface) *TodoRoute {
+	return &TodoRoute{
+		todoRepository:     todoRepository,
+		transactionManager: transactionManager,
+		userContextHelper:  userContextHelper,
+		recurrence:         recurrence,
+		markdownRenderer:   markdownRenderer}
 }
 
 func (t *TodoRoute) GetTodos(c *gin.Context) {
@@ -24,15 +46,151 @@ func (t *TodoRoute) GetTodos(c *gin.Context) {
 		return
 	}
 
-	todos, err := t.todoRepository.GetAllTodosByUser(user)
+	filter, err := parseTodoFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := parsePageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if page != nil {
+		var todoPage *types.Page[types.Todo]
+		todoPage, err = t.todoRepository.GetTodosPageByUser(user, filter, *page)
+		if errors.Is(err, types.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, todoPage)
+		return
+	}
+
+	todos, err := t.todoRepository.GetAllTodosByUser(user, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todos)
+}
+
+// SearchTodos answers GET /auth/todos/search, due: filters use the calendar days of the time zone in tz.
+// The results are capped, X-Results-Truncated tells the client to narrow the search down.
+func (t *TodoRoute) SearchTodos(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if strings.TrimSpace(c.Query("q")) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'q' must not be empty"})
+		return
+	}
+
+	location, err := parseLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// due: filters use the calendar day of the client
+	query, err := parseSearchQuery(c.Query("q"), time.Now().In(location))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query.Location = location
+
+	todos, truncated, err := t.todoRepository.SearchTodos(user, *query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if truncated {
+		// the search is not paged, clients are told to narrow it down
+		c.Header("X-Results-Truncated", "true")
+	}
+
+	if todos == nil {
+		todos = []types.Todo{}
+	}
+
 	c.JSON(http.StatusOK, todos)
 }
 
+func (t *TodoRoute) GetTodo(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	todo, err := t.todoRepository.GetTodoTree(todoId, user)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c, todo)
+	c.JSON(http.StatusOK, todo)
+}
+
+// GetTodoDescription returns the markdown description of a todo together with its rendering as sanitised HTML
+func (t *TodoRoute) GetTodoDescription(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	todo, err := t.todoRepository.GetTodoById(todoId, user)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"markdown": todo.Description, "html": t.markdownRenderer.Render(todo.Description)})
+}
+
+// RenderMarkdown renders arbitrary markdown, e.g. for a preview while editing a description
+func (t *TodoRoute) RenderMarkdown(c *gin.Context) {
+	var req types.RenderMarkdownRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if len(req.Markdown) > maxDescriptionLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'markdown' must not be longer than 65535 bytes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"html": t.markdownRenderer.Render(req.Markdown)})
+}
+
 func (t *TodoRoute) CreateTodo(c *gin.Context) {
 	user, err := t.userContextHelper.GetUserFromContext(c)
 	if err != nil {
@@ -52,8 +210,68 @@ func (t *TodoRoute) CreateTodo(c *gin.Context) {
 		return
 	}
 
-	todo := types.Todo{Title: req.Title, OwnerID: user.ID, Completed: false, CreatedAt: time.Now()}
-	err = t.todoRepository.CreateTodo(&todo)
+	todo := types.Todo{Title: req.Title, OwnerID: user.ID, Completed: false, CreatedAt: time.Now(), Occurrence: 1,
+		Priority: types.PriorityNone, WorkspaceID: user.WorkspaceID}
+	if req.Priority != nil && *req.Priority != "" {
+		if !validPriority(*req.Priority) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'priority' must be one of none, low, medium, high or urgent"})
+			return
+		}
+		todo.Priority = *req.Priority
+	}
+
+	if req.Description != nil {
+		if len(*req.Description) > maxDescriptionLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'description' must not be longer than 65535 bytes"})
+			return
+		}
+		todo.Description = *req.Description
+	}
+
+	if req.ParentID != nil {
+		var parent *types.Todo
+		parent, err = t.todoRepository.GetTodoById(*req.ParentID, user)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "parent todo not found"})
+			return
+		}
+
+		// viewers can not add subtasks
+		err = t.todoRepository.RequireRole(parent.ID, user, types.RoleEditor)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		// subtasks belong to the owner of the parent
+		todo.ParentID = &parent.ID
+		todo.OwnerID = parent.OwnerID
+	} else if user.WorkspaceRole == types.WorkspaceGuest {
+		c.JSON(http.StatusForbidden, gin.H{"error": "guests can not create todos in the workspace"})
+		return
+	}
+
+	if err = applyTodoDates(&todo, req.DueAt, req.DueHasTime, req.StartAt, req.StartHasTime); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err = t.applyRecurrenceRule(&todo, req.RecurrenceRule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// a subtask is inserted together with the access copied from its parent
+	err = t.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Todos().CreateTodo(&todo)
+		if err != nil {
+			return err
+		}
+
+		// the changes of a new todo are the fields it was created with
+		return recordEvent(tx, types.TodoEvent{TodoID: todo.ID, Action: types.EventCreated,
+			Changes: todoChanges(&types.Todo{Priority: types.PriorityNone}, &todo)}, user)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -85,38 +303,984 @@ func (t *TodoRoute) UpdateTodo(c *gin.Context) {
 		return
 	}
 
-	todo := types.Todo{ID: *req.ID, Title: *req.Title, Completed: *req.Completed, Category: *req.Category}
+	version, err := parseIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previous, err := t.todoRepository.GetTodoById(*req.ID, user)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// dates are kept like the other optional fields if the request does not mention them
+	todo := types.Todo{ID: *req.ID, Title: *req.Title, Completed: *req.Completed, DueAt: previous.DueAt,
+		DueHasTime: previous.DueHasTime, StartAt: previous.StartAt, StartHasTime: previous.StartHasTime}
+	if err = applyTodoDates(&todo, req.DueAt, req.DueHasTime, req.StartAt, req.StartHasTime); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// fields that can not be changed through this route are kept
+	todo.OwnerID = previous.OwnerID
+	todo.WorkspaceID = previous.WorkspaceID
+	todo.CreatedAt = previous.CreatedAt
+	todo.Occurrence = previous.Occurrence
+	todo.ParentID = previous.ParentID
+
+	// category, priority, description and rule are kept if the request does not mention them
+	todo.Category = previous.Category
+	if req.Category != nil {
+		todo.Category = *req.Category
+	}
 	todo.Category.CreatedUserId = user.ID
 
-	err = t.todoRepository.UpdateTodoById(&todo, user)
+	todo.Priority = previous.Priority
+	if req.Priority != nil {
+		if !validPriority(*req.Priority) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'priority' must be one of none, low, medium, high or urgent"})
+			return
+		}
+		todo.Priority = *req.Priority
+	}
+
+	todo.Description = previous.Description
+	if req.Description != nil {
+		if len(*req.Description) > maxDescriptionLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'description' must not be longer than 65535 bytes"})
+			return
+		}
+		todo.Description = *req.Description
+	}
+
+	todo.RecurrenceRule = previous.RecurrenceRule
+	if req.RecurrenceRule != nil {
+		err = t.applyRecurrenceRule(&todo, req.RecurrenceRule)
+	} else if todo.RecurrenceRule != "" && todo.DueAt == nil {
+		err = errors.New("'recurrence_rule' requires 'due_at'")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	todo.Version = version
+	err = t.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Todos().UpdateTodoById(&todo, user)
+		if err != nil {
+			return err
+		}
+
+		err = t.spawnNextOccurrence(tx, previous, &todo)
+		if err != nil {
+			return err
+		}
+
+		if event := updateEvent(previous, &todo); event != nil {
+			return recordEvent(tx, *event, user)
+		}
+
+		return nil
+	})
+	if errors.Is(err, types.ErrVersionConflict) {
+		t.respondVersionConflict(c, todo.ID, user)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c, &todo)
 	c.JSON(http.StatusOK, todo)
 }
 
-func (t *TodoRoute) DeleteTodo(c *gin.Context) {
+// PatchTodo only changes the fields of a todo that are mentioned in a JSON Merge Patch (RFC 7396) or a JSON Patch
+// (RFC 6902), the Content-Type tells which one it is. Patches apply to the json of the todo like it is returned by
+// GET /todo/:id, but only the fields an update can change may be patched.
+func (t *TodoRoute) PatchTodo(c *gin.Context) {
 	user, err := t.userContextHelper.GetUserFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	var todoId int
-	todoId, err = strconv.Atoi(c.Param("id"))
+	todoId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
 
-	todo := types.Todo{ID: todoId}
-	err = t.todoRepository.DeleteTodoById(&todo, user)
+	var applyPatch func(document interface{}, patch []byte) (interface{}, error)
+	switch c.ContentType() {
+	case "application/merge-patch+json", "application/json":
+		applyPatch = applyMergePatch
+	case "application/json-patch+json":
+		applyPatch = applyJSONPatch
+	default:
+		c.JSON(http.StatusUnsupportedMediaType,
+			gin.H{"error": "'Content-Type' must be application/merge-patch+json or application/json-patch+json"})
+		return
+	}
+
+	version, err := parseIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	previous, err := t.todoRepository.GetTodoById(todoId, user)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the patch is applied to the current version, so it must be the one the client knows
+	if version != 0 && version != previous.Version {
+		t.respondVersionConflict(c, todoId, user)
+		return
+	}
+
+	document, err := todoDocument(previous)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	patched, err := applyPatch(document, patch)
+	if errors.Is(err, errPatchTestFailed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	todo, err := t.patchedTodo(previous, patched, user)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// only the changed fields are written
+	changes := todoChanges(previous, todo)
+	fields := make([]string, 0, len(changes))
+	for _, field := range types.TodoFields {
+		if _, ok := changes[field]; ok {
+			fields = append(fields, field)
+		}
+	}
+
+	todo.Version = previous.Version
+	err = t.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Todos().UpdateTodoFields(todo, fields, user)
+		if err != nil {
+			return err
+		}
+
+		err = t.spawnNextOccurrence(tx, previous, todo)
+		if err != nil {
+			return err
+		}
+
+		if event := updateEvent(previous, todo); event != nil {
+			return recordEvent(tx, *event, user)
+		}
+
+		return nil
+	})
+	if errors.Is(err, types.ErrVersionConflict) {
+		t.respondVersionConflict(c, todo.ID, user)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c, todo)
+	c.JSON(http.StatusOK, todo)
+}
+
+// todoDocument is the json of the todo as generic value a patch can be applied to
+func todoDocument(todo *types.Todo) (interface{}, error) {
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return nil, err
+	}
+
+	var document interface{}
+	err = json.Unmarshal(data, &document)
+
+	return document, err
+}
+
+// patchedTodo validates the patched json of a todo like an update request, fields outside of types.TodoFields must
+// stay the same
+func (t *TodoRoute) patchedTodo(previous *types.Todo, patched interface{}, user *types.User) (*types.Todo, error) {
+	patchedObject, ok := patched.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("the patched todo must be an object")
+	}
+
+	original, err := todoDocument(previous)
+	if err != nil {
+		return nil, err
+	}
+
+	originalObject := original.(map[string]interface{})
+	for _, object := range []map[string]interface{}{originalObject, patchedObject} {
+		for key := range object {
+			if !reflect.DeepEqual(originalObject[key], patchedObject[key]) && !slices.Contains(types.TodoFields, key) {
+				return nil, fmt.Errorf("'%s' can not be changed", key)
+			}
+		}
+	}
+
+	data, err := json.Marshal(patchedObject)
+	if err != nil {
+		return nil, err
+	}
+
+	var req types.UpdateTodoRequest
+	if err = json.Unmarshal(data, &req); err != nil {
+		return nil, errors.New("the patched todo is no valid todo")
+	}
+
+	if req.Title == nil || *req.Title == "" {
+		return nil, errors.New("'title' must not be empty")
+	}
+
+	if req.Completed == nil {
+		return nil, errors.New("'completed' must be true or false")
+	}
+
+	todo := *previous
+	todo.Title = *req.Title
+	todo.Completed = *req.Completed
+
+	// a removed category means no category
+	todo.Category = types.Category{}
+	if req.Category != nil {
+		todo.Category = types.Category{ID: req.Category.ID, Title: req.Category.Title}
+	}
+	todo.Category.CreatedUserId = user.ID
+
+	// removed dates mean no dates
+	todo.DueAt, todo.DueHasTime, todo.StartAt, todo.StartHasTime = nil, false, nil, false
+	if err = applyTodoDates(&todo, req.DueAt, req.DueHasTime, req.StartAt, req.StartHasTime); err != nil {
+		return nil, err
+	}
+
+	todo.Priority = types.PriorityNone
+	if req.Priority != nil {
+		if !validPriority(*req.Priority) {
+			return nil, errors.New("'priority' must be one of none, low, medium, high or urgent")
+		}
+		todo.Priority = *req.Priority
+	}
+
+	todo.Description = ""
+	if req.Description != nil {
+		if len(*req.Description) > maxDescriptionLength {
+			return nil, errors.New("'description' must not be longer than 65535 bytes")
+		}
+		todo.Description = *req.Description
+	}
+
+	if err = t.applyRecurrenceRule(&todo, req.RecurrenceRule); err != nil {
+		return nil, err
+	}
+
+	return &todo, nil
+}
+
+// GetTodoRevisions lists the previous versions of a todo, newest first
+func (t *TodoRoute) GetTodoRevisions(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	revisions, err := t.todoRepository.GetTodoRevisions(todoId, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// RevertTodo restores a todo to one of its revisions, the version it replaces becomes a revision itself
+func (t *TodoRoute) RevertTodo(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Todo deleted successfully"})
+	todoId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	revisionId, err := strconv.Atoi(c.Param("revisionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision id"})
+		return
+	}
+
+	version, err := parseIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previous, err := t.todoRepository.GetTodoById(todoId, user)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	todo := types.Todo{ID: todoId, Version: version}
+	err = t.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Todos().RevertTodo(&todo, revisionId, user)
+		if err != nil {
+			return err
+		}
+
+		err = t.spawnNextOccurrence(tx, previous, &todo)
+		if err != nil {
+			return err
+		}
+
+		if event := updateEvent(previous, &todo); event != nil {
+			event.Action = types.EventReverted
+			return recordEvent(tx, *event, user)
+		}
+
+		return nil
+	})
+	if errors.Is(err, types.ErrVersionConflict) {
+		t.respondVersionConflict(c, todo.ID, user)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c, &todo)
+	c.JSON(http.StatusOK, todo)
+}
+
+func (t *TodoRoute) DeleteTodo(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var todoId int
+	todoId, err = strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	todo := types.Todo{ID: todoId}
+	err = t.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Todos().DeleteTodoById(&todo, user)
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(tx, types.TodoEvent{TodoID: todo.ID, Action: types.EventDeleted}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Todo moved to the trash"})
+}
+
+// GetArchivedTodos lists the archived todos, most recently archived first. It is always paginated.
+func (t *TodoRoute) GetArchivedTodos(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter, err := parseTodoFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter.Archived = true
+	if filter.SortBy == "" {
+		filter.SortBy = types.SortArchivedAt
+		if filter.SortOrder == "" {
+			filter.SortOrder = types.SortDesc
+		}
+	}
+
+	page, err := parsePageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if page == nil {
+		page = &types.PageRequest{Limit: defaultPageLimit}
+	}
+
+	todoPage, err := t.todoRepository.GetTodosPageByUser(user, filter, *page)
+	if errors.Is(err, types.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todoPage)
+}
+
+func (t *TodoRoute) ArchiveTodo(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	todo := types.Todo{ID: todoId}
+	err = t.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Todos().ArchiveTodo(&todo, user)
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(tx, types.TodoEvent{TodoID: todo.ID, Action: types.EventArchived}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Todo archived successfully"})
+}
+
+func (t *TodoRoute) UnarchiveTodo(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	todo := types.Todo{ID: todoId}
+	err = t.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Todos().UnarchiveTodo(&todo, user)
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(tx, types.TodoEvent{TodoID: todo.ID, Action: types.EventUnarchived}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Todo unarchived successfully"})
+}
+
+// ArchiveCompletedTodos archives all completed todos, optionally only those of one category
+func (t *TodoRoute) ArchiveCompletedTodos(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req types.ArchiveCompletedRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if req.CategoryID != nil && *req.CategoryID < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'category_id' must be a category id or 0"})
+		return
+	}
+
+	archived, err := t.todoRepository.ArchiveCompletedTodos(user, req.CategoryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"archived": archived})
+}
+
+func (t *TodoRoute) GetTrash(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todos, err := t.todoRepository.GetTrashByUser(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if todos == nil {
+		todos = []types.Todo{}
+	}
+
+	c.JSON(http.StatusOK, todos)
+}
+
+func (t *TodoRoute) RestoreTodo(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	todo := types.Todo{ID: todoId}
+	err = t.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Todos().RestoreTodo(&todo, user)
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(tx, types.TodoEvent{TodoID: todo.ID, Action: types.EventRestored}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Todo restored successfully"})
+}
+
+// PurgeTodo deletes a todo in the trash permanently
+func (t *TodoRoute) PurgeTodo(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	todo := types.Todo{ID: todoId}
+	err = t.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Todos().PurgeTodo(&todo, user)
+		if err != nil {
+			return err
+		}
+
+		// the todo is gone, only the owner can purge it and only in the workspace of the todo
+		return recordEvent(tx, types.TodoEvent{TodoTitle: todo.Title, WorkspaceID: user.WorkspaceID,
+			Action: types.EventPurged}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Todo deleted permanently"})
+}
+
+func (t *TodoRoute) MoveTodo(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var req types.MoveTodoRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	previous, err := t.todoRepository.GetTodoById(todoId, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todo := types.Todo{ID: todoId}
+	err = t.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Todos().MoveTodo(&todo, req.ParentID, user)
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(tx, types.TodoEvent{TodoID: todo.ID, Action: types.EventMoved,
+			Changes: map[string]types.FieldChange{"parent_id": {Old: previous.ParentID, New: req.ParentID}}}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Todo moved successfully"})
+}
+
+// ReorderTodo changes the position of the todo in the list of the user
+func (t *TodoRoute) ReorderTodo(c *gin.Context) {
+	user, err := t.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	todoId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var req types.ReorderTodoRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if req.CategoryID != nil && *req.CategoryID < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'category_id' must be a category id or 0"})
+		return
+	}
+
+	// renumbering the list of the user and moving the todo must not be torn apart
+	todo := types.Todo{ID: todoId}
+	err = t.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		if req.CategoryID == nil {
+			return tx.Todos().ReorderTodo(&todo, req.AfterID, nil, user)
+		}
+
+		event, err := moveCategory(tx, &todo, req.AfterID, req.CategoryID, user)
+		if err != nil || event == nil {
+			return err
+		}
+
+		return recordEvent(tx, *event, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Todo reordered successfully", "position": todo.Position})
+}
+
+// respondVersionConflict answers an update based on an outdated version with the current state of the todo
+func (t *TodoRoute) respondVersionConflict(c *gin.Context, todoID int, user *types.User) {
+	current, err := t.todoRepository.GetTodoById(todoID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c, current)
+	c.JSON(http.StatusPreconditionFailed, current)
+}
+
+// setETag sends the version of the todo as strong ETag
+func setETag(c *gin.Context, todo *types.Todo) {
+	c.Header("ETag", fmt.Sprintf(`"%d"`, todo.Version))
+}
+
+// parseIfMatch reads the version of the If-Match header, 0 if the header is missing or "*" so any version matches
+func parseIfMatch(c *gin.Context) (int, error) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(value, `"`), `"`))
+	if err != nil || version < 1 || value[0] != '"' || value[len(value)-1] != '"' {
+		return 0, errors.New("'If-Match' must be an ETag of the todo")
+	}
+
+	return version, nil
+}
+
+// parseLocation reads the time zone of the client from the tz query parameter, it defaults to UTC
+func parseLocation(c *gin.Context) (*time.Location, error) {
+	value := c.Query("tz")
+	if value == "" {
+		return time.UTC, nil
+	}
+
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		return nil, errors.New("'tz' must be an IANA time zone like Europe/Berlin")
+	}
+
+	return location, nil
+}
+
+// parseTodoFilter reads the filter and sort options of GET /auth/todos from the query string
+func parseTodoFilter(c *gin.Context) (types.TodoFilter, error) {
+	filter := types.TodoFilter{
+		Due:       c.Query("due"),
+		Ownership: c.Query("owner"),
+		SortBy:    c.Query("sort"),
+		SortOrder: c.Query("order"),
+	}
+
+	switch c.Query("assignee") {
+	case "":
+	case "me":
+		filter.AssignedToMe = true
+	default:
+		return filter, errors.New("'assignee' must be me")
+	}
+
+	switch filter.Due {
+	case "", types.DueOverdue, types.DueToday, types.DueThisWeek, types.DueNone:
+	default:
+		return filter, errors.New("'due' must be one of overdue, today, week or none")
+	}
+
+	if c.Query("tz") != "" {
+		location, err := parseLocation(c)
+		if err != nil {
+			return filter, err
+		}
+		filter.Location = location
+	}
+
+	if value := c.Query("completed"); value != "" {
+		completed, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.New("'completed' must be true or false")
+		}
+		filter.Completed = &completed
+	}
+
+	if value := c.Query("category"); value != "" {
+		categoryID := 0
+		if value != "none" {
+			var err error
+			categoryID, err = strconv.Atoi(value)
+			if err != nil || categoryID <= 0 {
+				return filter, errors.New("'category' must be a category id or none")
+			}
+		}
+		filter.CategoryID = &categoryID
+	}
+
+	if value := c.Query("priority"); value != "" {
+		for _, priority := range strings.Split(value, ",") {
+			if !validPriority(priority) {
+				return filter, errors.New("'priority' must be a list of none, low, medium, high or urgent")
+			}
+			filter.Priorities = append(filter.Priorities, priority)
+		}
+	}
+
+	if value := c.Query("tags"); value != "" {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" {
+				return filter, errors.New("'tags' must be a comma separated list of tag titles")
+			}
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+
+	filter.TagMode = c.Query("tag_mode")
+	switch filter.TagMode {
+	case "", types.TagModeAll, types.TagModeAny:
+	default:
+		return filter, errors.New("'tag_mode' must be one of all or any")
+	}
+
+	switch filter.Ownership {
+	case "", types.OwnershipOwn, types.OwnershipShared:
+	default:
+		return filter, errors.New("'owner' must be one of own or shared")
+	}
+
+	switch filter.SortBy {
+	case "", types.SortPosition, types.SortCreatedAt, types.SortDueAt, types.SortPriority, types.SortTitle:
+	default:
+		return filter, errors.New("'sort' must be one of position, created_at, due_at, priority or title")
+	}
+
+	switch filter.SortOrder {
+	case "", types.SortAsc, types.SortDesc:
+	default:
+		return filter, errors.New("'order' must be one of asc or desc")
+	}
+
+	return filter, nil
+}
+
+func validPriority(priority string) bool {
+	for _, p := range types.Priorities {
+		if p == priority {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyRecurrenceRule validates the rule of a request and stores it in its canonical form
+func (t *TodoRoute) applyRecurrenceRule(todo *types.Todo, rule *string) error {
+	if rule == nil || *rule == "" {
+		todo.RecurrenceRule = ""
+		return nil
+	}
+
+	parsed, err := t.recurrence.ParseRule(*rule)
+	if err != nil {
+		return fmt.Errorf("invalid 'recurrence_rule': %s", err.Error())
+	}
+
+	if todo.DueAt == nil {
+		return errors.New("'recurrence_rule' requires 'due_at'")
+	}
+
+	todo.RecurrenceRule = t.recurrence.FormatRule(parsed)
+	return nil
+}
+
+// spawnNextOccurrence creates the follow-up of a recurring todo the update completed, in the transaction of the update
+func (t *TodoRoute) spawnNextOccurrence(tx types.Transaction, previous *types.Todo, todo *types.Todo) error {
+	if !todo.Completed || previous.Completed || todo.RecurrenceRule == "" {
+		return nil
+	}
+
+	var err error
+	todo.NextOccurrence, err = createNextOccurrence(t.recurrence, tx.Todos(), todo)
+
+	return err
+}
+
+// createNextOccurrence spawns the follow-up of a completed recurring todo, it returns nil if the series has ended
+// or the follow-up was spawned before
+func createNextOccurrence(recurrence types.RecurrenceInterface, todoRepository types.TodoRepository, todo *types.Todo) (*types.Todo, error) {
+	next, err := recurrence.NextOccurrence(todo)
+	if err != nil || next == nil {
+		return nil, err
+	}
+
+	err = todoRepository.CreateNextOccurrence(next, todo)
+	if errors.Is(err, types.ErrOccurrenceExists) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// applyTodoDates parses the optional due and start dates of a request into the todo, a date the request leaves out
+// keeps the value of the todo and an empty one removes it.
+// Dates may be given as "2006-01-02" for a whole day or in RFC 3339 format with a time of day.
+// An explicit has_time flag wins over the format, so todos read from the api can be sent back unchanged.
+func applyTodoDates(todo *types.Todo, dueAt *string, dueHasTime *bool, startAt *string, startHasTime *bool) error {
+	var err error
+	if dueAt != nil {
+		todo.DueAt, todo.DueHasTime, err = parseTodoDate(dueAt, dueHasTime)
+		if err != nil {
+			return errors.New("'due_at' must be a date (YYYY-MM-DD) or a RFC 3339 timestamp")
+		}
+	}
+
+	if startAt != nil {
+		todo.StartAt, todo.StartHasTime, err = parseTodoDate(startAt, startHasTime)
+		if err != nil {
+			return errors.New("'start_at' must be a date (YYYY-MM-DD) or a RFC 3339 timestamp")
+		}
+	}
+
+	if todo.DueAt != nil && todo.StartAt != nil && todo.StartAt.After(*todo.DueAt) {
+		return errors.New("'start_at' must not be after 'due_at'")
+	}
+
+	return nil
+}
+
+func parseTodoDate(value *string, hasTime *bool) (*time.Time, bool, error) {
+	if value == nil || *value == "" {
+		return nil, false, nil
+	}
+
+	date, err := time.Parse(time.DateOnly, *value)
+	if err == nil {
+		return &date, false, nil
+	}
+
+	date, err = time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, false, err
+	}
+
+	date = date.UTC()
+	if hasTime != nil && !*hasTime {
+		date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+		return &date, false, nil
+	}
+
+	return &date, true, nil
 }
@@ -0,0 +1,285 @@
+package routes
+
+import (
+	"errors"
+	"github.com/floxo05/todoapi/internal/types"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testcases := []struct {
+		header          string
+		expectedVersion int
+		expectedError   bool
+	}{
+		{header: "", expectedVersion: 0},
+		{header: "*", expectedVersion: 0},
+		{header: `"3"`, expectedVersion: 3},
+		{header: `W/"3"`, expectedError: true},
+		{header: "3", expectedError: true},
+		{header: `"0"`, expectedError: true},
+		{header: `"abc"`, expectedError: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run("Test parseIfMatch "+tc.header, func(t *testing.T) {
+			// Arrange
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("PUT", "/todo/1", nil)
+			if tc.header != "" {
+				c.Request.Header.Set("If-Match", tc.header)
+			}
+
+			// Act
+			version, err := parseIfMatch(c)
+
+			// Assert
+			if tc.expectedError && err == nil {
+				t.Errorf("Expected an error, but got version %d", version)
+			}
+
+			if !tc.expectedError && (err != nil || version != tc.expectedVersion) {
+				t.Errorf("Expected version %d, but got %d (%v)", tc.expectedVersion, version, err)
+			}
+		})
+	}
+}
+
+func TestTodoRoute_patchedTodo(t *testing.T) {
+	previous := types.Todo{ID: 1, Title: "Todo", OwnerID: 1, Priority: types.PriorityLow, Description: "text",
+		Category: types.Category{ID: 2, Title: "Work", CreatedUserId: 1}, Version: 3}
+	user := &types.User{ID: 1}
+
+	t.Run("should only change the patched fields", func(t *testing.T) {
+		// Arrange
+		document, err := todoDocument(&previous)
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		patched, err := applyMergePatch(document, []byte(`{"priority":"high","category":null}`))
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		// Act
+		todo, err := (&TodoRoute{}).patchedTodo(&previous, patched, user)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected error to be nil, but got %s", err.Error())
+		}
+
+		changes := todoChanges(&previous, todo)
+		if len(changes) != 2 || changes[types.FieldPriority].New != types.PriorityHigh ||
+			changes[types.FieldCategory].New != nil {
+			t.Errorf("Expected the priority and the category to change, but got %v", changes)
+		}
+	})
+
+	testcases := []struct {
+		name  string
+		patch string
+	}{
+		{name: "should not change other fields", patch: `[{"op":"replace","path":"/owner_id","value":2}]`},
+		{name: "should not remove the title", patch: `[{"op":"remove","path":"/title"}]`},
+		{name: "should validate the priority", patch: `[{"op":"replace","path":"/priority","value":"later"}]`},
+		{name: "should validate the types", patch: `[{"op":"replace","path":"/completed","value":"yes"}]`},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			document, err := todoDocument(&previous)
+			if err != nil {
+				t.Fatalf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			patched, err := applyJSONPatch(document, []byte(tc.patch))
+			if err != nil {
+				t.Fatalf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			// Act
+			_, err = (&TodoRoute{}).patchedTodo(&previous, patched, user)
+
+			// Assert
+			if err == nil {
+				t.Errorf("Expected an error, but got nil")
+			}
+		})
+	}
+}
+
+func TestTodoRoute_UpdateTodo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dueAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	startAt := time.Date(2024, 4, 28, 9, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		name             string
+		body             string
+		expectedResponse int
+		expectDates      bool
+	}{
+		{name: "should keep the dates the request leaves out", body: `{"id":1,"title":"Todo","completed":false}`,
+			expectedResponse: http.StatusOK, expectDates: true},
+		{name: "should remove empty dates", body: `{"id":1,"title":"Todo","completed":false,"due_at":"","start_at":""}`,
+			expectedResponse: http.StatusOK},
+		{name: "should answer unknown todos with not found", body: `{"id":2,"title":"Todo","completed":false}`,
+			expectedResponse: http.StatusNotFound},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			todoRepo := &mockTodoRepository{todo: types.Todo{ID: 1, Title: "Todo", DueAt: &dueAt, StartAt: &startAt,
+				StartHasTime: true, Version: 1}}
+			todoRoute := NewTodoRoute(todoRepo, &mockTransactionManager{todoRepo: todoRepo}, &mockUserContextHelper{}, nil, nil)
+			router := gin.Default()
+			router.PUT("/todo", todoRoute.UpdateTodo)
+
+			// Act
+			req := httptest.NewRequest("PUT", "/todo", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assert
+			if tc.expectedResponse != w.Code {
+				t.Fatalf("Expected status code %d, but got %d", tc.expectedResponse, w.Code)
+			}
+
+			if tc.expectedResponse != http.StatusOK {
+				return
+			}
+
+			updated := todoRepo.updated
+			if tc.expectDates && (updated.DueAt == nil || !updated.DueAt.Equal(dueAt) || updated.StartAt == nil ||
+				!updated.StartAt.Equal(startAt) || !updated.StartHasTime) {
+				t.Errorf("Expected the dates to be kept, but got %v and %v", updated.DueAt, updated.StartAt)
+			}
+
+			if !tc.expectDates && (updated.DueAt != nil || updated.StartAt != nil) {
+				t.Errorf("Expected the dates to be removed, but got %v and %v", updated.DueAt, updated.StartAt)
+			}
+		})
+	}
+}
+
+func TestTodoRoute_RevertTodo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testcases := []struct {
+		name             string
+		ifMatch          string
+		expectedResponse int
+	}{
+		{name: "should revert the current version", ifMatch: `"2"`, expectedResponse: http.StatusOK},
+		{name: "should revert without precondition", expectedResponse: http.StatusOK},
+		{name: "should reject an outdated version", ifMatch: `"1"`, expectedResponse: http.StatusPreconditionFailed},
+		{name: "should reject an invalid precondition", ifMatch: "2", expectedResponse: http.StatusBadRequest},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			todoRepo := &mockTodoRepository{todo: types.Todo{ID: 1, Title: "Todo", Version: 2}}
+			todoRoute := NewTodoRoute(todoRepo, &mockTransactionManager{todoRepo: todoRepo}, &mockUserContextHelper{}, nil, nil)
+			router := gin.Default()
+			router.POST("/todo/:id/revisions/:revisionId/revert", todoRoute.RevertTodo)
+
+			// Act
+			req := httptest.NewRequest("POST", "/todo/1/revisions/9/revert", nil)
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assert
+			if tc.expectedResponse != w.Code {
+				t.Errorf("Expected status code %d, but got %d", tc.expectedResponse, w.Code)
+			}
+		})
+	}
+}
+
+/////////////////////////////////////////////
+
+// mockTodoRepository knows a single todo, methods the tests do not need are left to the embedded interface
+type mockTodoRepository struct {
+	types.TodoRepository
+	todo    types.Todo
+	updated *types.Todo
+}
+
+func (m *mockTodoRepository) GetTodoById(id int, user *types.User) (*types.Todo, error) {
+	if id != m.todo.ID {
+		return nil, errors.New("todo not found")
+	}
+
+	todo := m.todo
+	return &todo, nil
+}
+
+func (m *mockTodoRepository) UpdateTodoById(todo *types.Todo, user *types.User) error {
+	todo.Version++
+	m.updated = todo
+	return nil
+}
+
+func (m *mockTodoRepository) RevertTodo(todo *types.Todo, revisionID int, user *types.User) error {
+	if todo.Version != 0 && todo.Version != m.todo.Version {
+		return types.ErrVersionConflict
+	}
+
+	*todo = m.todo
+	todo.Title = "Reverted"
+	todo.Version++
+	return nil
+}
+
+// mockTransactionManager hands the given repositories to the transaction, events are recorded by a mock
+type mockTransactionManager struct {
+	todoRepo     types.TodoRepository
+	userRepo     types.UserRepository
+	categoryRepo types.CategoryRepository
+}
+
+func (m *mockTransactionManager) WithTransaction(fn func(tx types.Transaction) error) error {
+	return fn(&mockTransaction{todoRepo: m.todoRepo, userRepo: m.userRepo, categoryRepo: m.categoryRepo})
+}
+
+type mockTransaction struct {
+	types.Transaction
+	todoRepo     types.TodoRepository
+	userRepo     types.UserRepository
+	categoryRepo types.CategoryRepository
+}
+
+func (m *mockTransaction) Todos() types.TodoRepository {
+	return m.todoRepo
+}
+
+func (m *mockTransaction) Users() types.UserRepository {
+	return m.userRepo
+}
+
+func (m *mockTransaction) Categories() types.CategoryRepository {
+	return m.categoryRepo
+}
+
+func (m *mockTransaction) Activity() types.ActivityRepository {
+	return &mockActivityRepository{}
+}
+
+func (m *mockTransaction) Savepoint(fn func() error) error {
+	return fn()
+}
@@ -6,24 +6,31 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 	"unicode"
 )
 
+// completed todos are archived after ten years at the latest
+const maxAutoArchiveDays = 3650
+
 type UserRoute struct {
-	userRepository    types.UserRepository
-	passwordHasher    types.PasswordHasherInterface
-	userContextHelper types.UserContextInterface
+	userRepository     types.UserRepository
+	transactionManager types.TransactionManager
+	passwordHasher     types.PasswordHasherInterface
+	userContextHelper  types.UserContextInterface
 }
 
 func NewUserRoute(
 	userRepo types.UserRepository,
+	transactionManager types.TransactionManager,
 	passwordHasher types.PasswordHasherInterface,
 	userContextHelper types.UserContextInterface) *UserRoute {
 	return &UserRoute{
-		userRepository:    userRepo,
-		passwordHasher:    passwordHasher,
-		userContextHelper: userContextHelper}
+		userRepository:     userRepo,
+		transactionManager: transactionManager,
+		passwordHasher:     passwordHasher,
+		userContextHelper:  userContextHelper}
 }
 
 func (u *UserRoute) Login(c *gin.Context) {
@@ -87,43 +94,232 @@ func (u *UserRoute) Register(c *gin.Context) {
 	}
 }
 
-func (u *UserRoute) ShareToUser(c *gin.Context) {
+// SetCollaboratorRole lets the owner of a todo change the role of a collaborator
+func (u *UserRoute) SetCollaboratorRole(c *gin.Context) {
 	user, err := u.userContextHelper.GetUserFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
 
-	var req types.ShareToUserRequest
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo id"})
+		return
+	}
+
+	var req types.SetRoleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
 
-	if req.Username == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "'username' must not be empty"})
+	if !validShareRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'role' must be one of viewer, editor or manager"})
+		return
+	}
+
+	collaborator, err := u.userRepository.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve collaborator"})
+		return
+	}
+
+	err = u.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		// the previous role is only needed for the history of the todo
+		var previousRole interface{}
+		collaborators, err := tx.Users().GetCollaborators(todoID, user)
+		if err == nil {
+			for _, current := range collaborators {
+				if current.Username == collaborator.Username {
+					previousRole = current.Role
+				}
+			}
+		}
+
+		err = tx.Users().SetCollaboratorRole(todoID, user, collaborator, req.Role)
+		if err != nil || previousRole == req.Role {
+			return err
+		}
+
+		return recordEvent(tx, types.TodoEvent{TodoID: todoID, Action: types.EventRoleChanged,
+			Subject: &collaborator.Username,
+			Changes: map[string]types.FieldChange{"role": {Old: previousRole, New: req.Role}}}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	if req.TodoID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "'id' must not be empty"})
+	c.JSON(http.StatusOK, gin.H{"message": "Role changed successfully"})
+}
+
+// GetCollaborators lists the users a todo is shared with, including the owner
+func (u *UserRoute) GetCollaborators(c *gin.Context) {
+	user, err := u.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
 
-	var shareUser *types.User
-	shareUser, err = u.userRepository.GetUserByUsername(req.Username)
+	todoID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve shareUser"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo id"})
 		return
 	}
 
-	err = u.userRepository.ShareTodoWithUser(req.TodoID, user, shareUser)
+	collaborators, err := u.userRepository.GetCollaborators(todoID, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Todo shared successfully"})
+	c.JSON(http.StatusOK, collaborators)
+}
+
+// RemoveCollaborator lets the owner of a todo revoke the access of a collaborator
+func (u *UserRoute) RemoveCollaborator(c *gin.Context) {
+	user, err := u.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo id"})
+		return
+	}
+
+	collaborator, err := u.userRepository.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve collaborator"})
+		return
+	}
+
+	err = u.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Users().RemoveCollaborator(todoID, user, collaborator)
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(tx, types.TodoEvent{TodoID: todoID, Action: types.EventUnshared,
+			Subject: &collaborator.Username}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collaborator removed successfully"})
+}
+
+// AssignTodo makes a collaborator with at least editor access responsible for the todo
+func (u *UserRoute) AssignTodo(c *gin.Context) {
+	user, err := u.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo id"})
+		return
+	}
+
+	assignee, err := u.userRepository.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve assignee"})
+		return
+	}
+
+	err = u.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Users().AssignTodo(todoID, user, assignee)
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(tx, types.TodoEvent{TodoID: todoID, Action: types.EventAssigned,
+			Subject: &assignee.Username}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Todo assigned successfully"})
+}
+
+func (u *UserRoute) UnassignTodo(c *gin.Context) {
+	user, err := u.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo id"})
+		return
+	}
+
+	assignee, err := u.userRepository.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve assignee"})
+		return
+	}
+
+	err = u.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Users().UnassignTodo(todoID, user, assignee)
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(tx, types.TodoEvent{TodoID: todoID, Action: types.EventUnassigned,
+			Subject: &assignee.Username}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Todo unassigned successfully"})
+}
+
+// LeaveTodo removes a todo that was shared with the user from their list
+func (u *UserRoute) LeaveTodo(c *gin.Context) {
+	user, err := u.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo id"})
+		return
+	}
+
+	err = u.transactionManager.WithTransaction(func(tx types.Transaction) error {
+		err := tx.Users().LeaveTodo(todoID, user)
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(tx, types.TodoEvent{TodoID: todoID, Action: types.EventLeft}, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Left the todo successfully"})
+}
+
+// validShareRole reports whether the role can be given to a collaborator, there is only one owner
+func validShareRole(role string) bool {
+	return role == types.RoleViewer || role == types.RoleEditor || role == types.RoleManager
 }
 
 func sendJWTToken(c *gin.Context, req types.AuthRequest) error {
@@ -169,3 +365,31 @@ func validatePassword(password string) bool {
 
 	return hasMinLen && hasUpper && hasLower && hasNumber && hasSpecial
 }
+
+// SetAutoArchive changes after how many days the completed todos of the user are archived, null turns it off
+func (u *UserRoute) SetAutoArchive(c *gin.Context) {
+	user, err := u.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	var req types.AutoArchiveRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if req.Days != nil && (*req.Days < 1 || *req.Days > maxAutoArchiveDays) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'days' must be a number between 1 and 3650 or null"})
+		return
+	}
+
+	err = u.userRepository.SetAutoArchiveDays(user, req.Days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"auto_archive_days": user.AutoArchiveDays})
+}
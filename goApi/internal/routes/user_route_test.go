@@ -15,7 +15,8 @@ func TestUserRoute_Login(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
 
-	loginRoute := NewUserRoute(&mockUserRepository{}, &mockPasswordHasher{}, &mockUserContextHelper{})
+	loginRoute := NewUserRoute(&mockUserRepository{}, &mockTransactionManager{userRepo: &mockUserRepository{}}, &mockPasswordHasher{},
+		&mockUserContextHelper{})
 	router.POST("/login", loginRoute.Login)
 
 	testcases := []struct {
@@ -48,7 +49,8 @@ func TestUserRoute_Register(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
 
-	loginRoute := NewUserRoute(&mockUserRepository{}, &mockPasswordHasher{}, &mockUserContextHelper{})
+	loginRoute := NewUserRoute(&mockUserRepository{}, &mockTransactionManager{userRepo: &mockUserRepository{}}, &mockPasswordHasher{},
+		&mockUserContextHelper{})
 	router.POST("/register", loginRoute.Register)
 
 	testcases := []struct {
@@ -76,6 +78,71 @@ func TestUserRoute_Register(t *testing.T) {
 	}
 }
 
+func TestUserRoute_SetAutoArchive(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	userRoute := NewUserRoute(&mockUserRepository{}, &mockTransactionManager{userRepo: &mockUserRepository{}}, &mockPasswordHasher{},
+		&mockUserContextHelper{})
+	router := gin.Default()
+	router.PUT("/settings/auto-archive", userRoute.SetAutoArchive)
+
+	testcases := []struct {
+		Body             []byte
+		expectedResponse int
+	}{
+		{Body: []byte(`{"days": 7}`), expectedResponse: http.StatusOK},
+		{Body: []byte(`{"days": null}`), expectedResponse: http.StatusOK},
+		{Body: []byte(`{"days": 0}`), expectedResponse: http.StatusBadRequest},
+		{Body: []byte(`{"days": "7"}`), expectedResponse: http.StatusBadRequest},
+	}
+
+	for _, tc := range testcases {
+		t.Run("Test SetAutoArchive", func(t *testing.T) {
+			// Act
+			req := httptest.NewRequest("PUT", "/settings/auto-archive", bytes.NewBuffer(tc.Body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assert
+			if tc.expectedResponse != w.Code {
+				t.Errorf("Expected status code %d, but got %d", tc.expectedResponse, w.Code)
+			}
+		})
+	}
+}
+
+func TestUserRoute_RemoveCollaborator(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	userRoute := NewUserRoute(&mockUserRepository{}, &mockTransactionManager{userRepo: &mockUserRepository{}}, &mockPasswordHasher{},
+		&mockUserContextHelper{})
+	router := gin.Default()
+	router.DELETE("/todo/:id/collaborators/:username", userRoute.RemoveCollaborator)
+
+	testcases := []struct {
+		Path             string
+		expectedResponse int
+	}{
+		{Path: "/todo/1/collaborators/test", expectedResponse: http.StatusOK},
+		{Path: "/todo/abc/collaborators/test", expectedResponse: http.StatusBadRequest},
+		{Path: "/todo/1/collaborators/unknown", expectedResponse: http.StatusNotFound},
+	}
+
+	for _, tc := range testcases {
+		t.Run("Test RemoveCollaborator", func(t *testing.T) {
+			// Act
+			req := httptest.NewRequest("DELETE", tc.Path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assert
+			if tc.expectedResponse != w.Code {
+				t.Errorf("Expected status code %d, but got %d", tc.expectedResponse, w.Code)
+			}
+		})
+	}
+}
+
 /////////////////////////////////////////////
 
 type mockUserRepository struct{}
@@ -95,10 +162,53 @@ func (m *mockUserRepository) CreateUser(user *types.User) error {
 	return nil
 }
 
-func (m *mockUserRepository) ShareTodoWithUser(todoID int, user *types.User, shareUser *types.User) error {
+func (m *mockUserRepository) ShareTodoWithUser(todoID int, user *types.User, shareUser *types.User, role string) error {
+	return nil
+}
+
+func (m *mockUserRepository) SetCollaboratorRole(todoID int, user *types.User, collaborator *types.User, role string) error {
+	return nil
+}
+
+func (m *mockUserRepository) GetCollaborators(todoID int, user *types.User) ([]types.Collaborator, error) {
+	return []types.Collaborator{{Username: user.Username, Role: types.RoleOwner}}, nil
+}
+
+func (m *mockUserRepository) RemoveCollaborator(todoID int, user *types.User, collaborator *types.User) error {
+	return nil
+}
+
+func (m *mockUserRepository) LeaveTodo(todoID int, user *types.User) error {
 	return nil
 }
 
+func (m *mockUserRepository) AssignTodo(todoID int, user *types.User, assignee *types.User) error {
+	return nil
+}
+
+func (m *mockUserRepository) UnassignTodo(todoID int, user *types.User, assignee *types.User) error {
+	return nil
+}
+
+func (m *mockUserRepository) SetAutoArchiveDays(user *types.User, days *int) error {
+	user.AutoArchiveDays = days
+	return nil
+}
+
+type mockActivityRepository struct{}
+
+func (m *mockActivityRepository) RecordEvent(event *types.TodoEvent) error {
+	return nil
+}
+
+func (m *mockActivityRepository) GetTodoHistory(todoID int, user *types.User) ([]types.TodoEvent, error) {
+	return []types.TodoEvent{}, nil
+}
+
+func (m *mockActivityRepository) GetActivityByUser(user *types.User, page types.PageRequest) (*types.Page[types.TodoEvent], error) {
+	return &types.Page[types.TodoEvent]{Items: []types.TodoEvent{}}, nil
+}
+
 type mockPasswordHasher struct{}
 
 func (m *mockPasswordHasher) HashPassword(password string) (string, error) {
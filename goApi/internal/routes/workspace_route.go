@@ -0,0 +1,232 @@
+package routes
+
+import (
+	"errors"
+	"github.com/floxo05/todoapi/internal/types"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type WorkspaceRoute struct {
+	workspaceRepository types.WorkspaceRepository
+	userRepository      types.UserRepository
+	userContextHelper   types.UserContextInterface
+}
+
+func NewWorkspaceRoute(workspaceRepo types.WorkspaceRepository, userRepo types.UserRepository, userContextHelper types.UserContextInterface) *WorkspaceRoute {
+	return &WorkspaceRoute{workspaceRepository: workspaceRepo, userRepository: userRepo, userContextHelper: userContextHelper}
+}
+
+func (w *WorkspaceRoute) CreateWorkspace(c *gin.Context) {
+	user, err := w.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req types.CreateWorkspaceRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if req.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'title' must not be empty"})
+		return
+	}
+
+	workspace := types.Workspace{Title: req.Title}
+	err = w.workspaceRepository.CreateWorkspace(&workspace, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, workspace)
+}
+
+// GetWorkspaces lists the workspaces of the user with their role in each
+func (w *WorkspaceRoute) GetWorkspaces(c *gin.Context) {
+	user, err := w.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspaces, err := w.workspaceRepository.GetWorkspacesByUser(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, workspaces)
+}
+
+func (w *WorkspaceRoute) GetWorkspaceMembers(c *gin.Context) {
+	user, err := w.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspaceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace id"})
+		return
+	}
+
+	members, err := w.workspaceRepository.GetWorkspaceMembers(workspaceID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// SetWorkspaceMember changes the role of a member or invites a user who is no member yet
+func (w *WorkspaceRoute) SetWorkspaceMember(c *gin.Context) {
+	user, err := w.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspaceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace id"})
+		return
+	}
+
+	var req types.SetRoleRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if req.Role == "" {
+		req.Role = types.WorkspaceMember
+	}
+
+	if req.Role != types.WorkspaceGuest && req.Role != types.WorkspaceMember && req.Role != types.WorkspaceAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'role' must be one of guest, member or admin"})
+		return
+	}
+
+	member, err := w.userRepository.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve member"})
+		return
+	}
+
+	err = w.workspaceRepository.SetWorkspaceMember(workspaceID, user, member, req.Role)
+	if errors.Is(err, types.ErrNotWorkspaceMember) {
+		// new members only join the workspace after accepting
+		err = w.workspaceRepository.InviteWorkspaceMember(workspaceID, user, member, req.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation sent successfully"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member saved successfully"})
+}
+
+// RemoveWorkspaceMember removes a member from the workspace, members can remove themselves to leave it
+func (w *WorkspaceRoute) RemoveWorkspaceMember(c *gin.Context) {
+	user, err := w.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspaceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace id"})
+		return
+	}
+
+	member, err := w.userRepository.GetUserByUsername(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve member"})
+		return
+	}
+
+	err = w.workspaceRepository.RemoveWorkspaceMember(workspaceID, user, member)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// GetWorkspaceInvitations lists the pending invitations of the user to workspaces
+func (w *WorkspaceRoute) GetWorkspaceInvitations(c *gin.Context) {
+	user, err := w.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	invitations, err := w.workspaceRepository.GetWorkspaceInvitationsByUser(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// AcceptWorkspaceInvitation makes the user a member of the workspace of the invitation
+func (w *WorkspaceRoute) AcceptWorkspaceInvitation(c *gin.Context) {
+	user, err := w.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	invitationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation id"})
+		return
+	}
+
+	err = w.workspaceRepository.AcceptWorkspaceInvitation(invitationID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted successfully"})
+}
+
+func (w *WorkspaceRoute) DeclineWorkspaceInvitation(c *gin.Context) {
+	user, err := w.userContextHelper.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	invitationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation id"})
+		return
+	}
+
+	err = w.workspaceRepository.DeclineWorkspaceInvitation(invitationID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation declined successfully"})
+}
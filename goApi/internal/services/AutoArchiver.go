@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"github.com/floxo05/todoapi/internal/types"
+	"log"
+	"time"
+)
+
+// AutoArchiver archives completed todos of users who turned on auto archiving
+type AutoArchiver struct {
+	todoRepository types.TodoRepository
+	interval       time.Duration
+}
+
+func NewAutoArchiver(todoRepository types.TodoRepository, interval time.Duration) *AutoArchiver {
+	return &AutoArchiver{todoRepository: todoRepository, interval: interval}
+}
+
+// Run archives right away and then once per interval until the context is cancelled
+func (a *AutoArchiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		archived, err := a.todoRepository.AutoArchiveCompletedTodos(time.Now())
+		if err != nil {
+			log.Printf("archiving completed todos failed: %v", err)
+		} else if archived > 0 {
+			log.Printf("archived %d completed todos", archived)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
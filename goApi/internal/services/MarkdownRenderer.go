@@ -0,0 +1,223 @@
+package services
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The renderer never passes raw html through: all text is escaped and only its own tags are generated.
+var (
+	headingPattern       = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	rulePattern          = regexp.MustCompile(`^(-{3,}|\*{3,}|_{3,})$`)
+	unorderedPattern     = regexp.MustCompile(`^\s{0,3}[-*+]\s+(.*)$`)
+	orderedPattern       = regexp.MustCompile(`^\s{0,3}(\d{1,9})[.)]\s+(.*)$`)
+	taskPattern          = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+	fencePattern         = regexp.MustCompile("^\\s{0,3}```\\s*([A-Za-z0-9_+-]*)\\s*$")
+	quotePattern         = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+	linkPattern          = regexp.MustCompile(`\[([^\[\]]+)\]\(([^()\s]+)\)`)
+	strongPattern        = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	emphasisPattern      = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*|(^|[^\w])_(\S(?:.*?\S)?)_([^\w]|$)`)
+	strikethroughPattern = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+)
+
+type MarkdownRenderer struct{}
+
+func NewMarkdownRenderer() *MarkdownRenderer {
+	return &MarkdownRenderer{}
+}
+
+// Render supports headings, paragraphs, emphasis, strikethrough, inline code, code fences, block quotes,
+// horizontal rules, links and (task) lists
+func (m *MarkdownRenderer) Render(markdown string) string {
+	markdown = strings.ReplaceAll(markdown, "\r\n", "\n")
+	return renderBlocks(strings.Split(markdown, "\n"))
+}
+
+func renderBlocks(lines []string) string {
+	var out strings.Builder
+	var paragraph []string
+
+	flushParagraph := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+
+		rendered := make([]string, 0, len(paragraph))
+		for i, line := range paragraph {
+			// two trailing spaces are a hard line break
+			if strings.HasSuffix(line, "  ") && i < len(paragraph)-1 {
+				rendered = append(rendered, renderInline(strings.TrimSpace(line))+"<br>")
+				continue
+			}
+			rendered = append(rendered, renderInline(strings.TrimSpace(line)))
+		}
+
+		out.WriteString("<p>" + strings.Join(rendered, "\n") + "</p>\n")
+		paragraph = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if strings.TrimSpace(line) == "" {
+			flushParagraph()
+			continue
+		}
+
+		if match := fencePattern.FindStringSubmatch(line); match != nil {
+			flushParagraph()
+
+			var code []string
+			for i++; i < len(lines) && !fencePattern.MatchString(lines[i]); i++ {
+				code = append(code, lines[i])
+			}
+
+			class := ""
+			if match[1] != "" {
+				class = ` class="language-` + match[1] + `"`
+			}
+			out.WriteString("<pre><code" + class + ">" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+			continue
+		}
+
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			flushParagraph()
+			level := strconv.Itoa(len(match[1]))
+			out.WriteString("<h" + level + ">" + renderInline(match[2]) + "</h" + level + ">\n")
+			continue
+		}
+
+		if rulePattern.MatchString(strings.Join(strings.Fields(line), "")) {
+			flushParagraph()
+			out.WriteString("<hr>\n")
+			continue
+		}
+
+		if quotePattern.MatchString(line) {
+			flushParagraph()
+
+			var quoted []string
+			for ; i < len(lines) && quotePattern.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quotePattern.FindStringSubmatch(lines[i])[1])
+			}
+			i--
+
+			out.WriteString("<blockquote>\n" + renderBlocks(quoted) + "</blockquote>\n")
+			continue
+		}
+
+		if unorderedPattern.MatchString(line) {
+			flushParagraph()
+
+			out.WriteString("<ul>\n")
+			for ; i < len(lines) && unorderedPattern.MatchString(lines[i]); i++ {
+				out.WriteString(renderListItem(unorderedPattern.FindStringSubmatch(lines[i])[1]))
+			}
+			i--
+			out.WriteString("</ul>\n")
+			continue
+		}
+
+		if match := orderedPattern.FindStringSubmatch(line); match != nil {
+			flushParagraph()
+
+			start := ""
+			if match[1] != "1" {
+				number, _ := strconv.Atoi(match[1])
+				start = ` start="` + strconv.Itoa(number) + `"`
+			}
+
+			out.WriteString("<ol" + start + ">\n")
+			for ; i < len(lines) && orderedPattern.MatchString(lines[i]); i++ {
+				out.WriteString(renderListItem(orderedPattern.FindStringSubmatch(lines[i])[2]))
+			}
+			i--
+			out.WriteString("</ol>\n")
+			continue
+		}
+
+		paragraph = append(paragraph, line)
+	}
+	flushParagraph()
+
+	return out.String()
+}
+
+func renderListItem(text string) string {
+	if match := taskPattern.FindStringSubmatch(text); match != nil {
+		checked := ""
+		if match[1] != " " {
+			checked = " checked"
+		}
+
+		return `<li class="task"><input type="checkbox" disabled` + checked + "> " + renderInline(match[2]) + "</li>\n"
+	}
+
+	return "<li>" + renderInline(text) + "</li>\n"
+}
+
+// renderInline renders code spans, links and emphasis of a single line
+func renderInline(text string) string {
+	var out strings.Builder
+
+	// code spans are taken literally, so they are split off first
+	parts := strings.Split(text, "`")
+	for i, part := range parts {
+		switch {
+		case i%2 == 1 && i < len(parts)-1:
+			out.WriteString("<code>" + html.EscapeString(part) + "</code>")
+		case i%2 == 1:
+			// unmatched backtick
+			out.WriteString(renderLinks("`" + part))
+		default:
+			out.WriteString(renderLinks(part))
+		}
+	}
+
+	return out.String()
+}
+
+func renderLinks(text string) string {
+	var out strings.Builder
+
+	last := 0
+	for _, match := range linkPattern.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(renderEmphasis(html.EscapeString(text[last:match[0]])))
+
+		label, url := text[match[2]:match[3]], text[match[4]:match[5]]
+		if safeURL(url) {
+			out.WriteString(`<a href="` + html.EscapeString(url) + `" rel="nofollow noopener noreferrer" target="_blank">` +
+				renderEmphasis(html.EscapeString(label)) + "</a>")
+		} else {
+			out.WriteString(renderEmphasis(html.EscapeString(text[match[0]:match[1]])))
+		}
+
+		last = match[1]
+	}
+	out.WriteString(renderEmphasis(html.EscapeString(text[last:])))
+
+	return out.String()
+}
+
+// renderEmphasis works on escaped text, so the generated tags are the only markup
+func renderEmphasis(escaped string) string {
+	escaped = strongPattern.ReplaceAllString(escaped, "<strong>$1$2</strong>")
+	escaped = strikethroughPattern.ReplaceAllString(escaped, "<del>$1</del>")
+	escaped = emphasisPattern.ReplaceAllString(escaped, "$2<em>$1$3</em>$4")
+
+	return escaped
+}
+
+// safeURL only allows links that can not run scripts
+func safeURL(url string) bool {
+	lower := strings.ToLower(url)
+	for _, prefix := range []string{"http://", "https://", "mailto:"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownRenderer_Render(t *testing.T) {
+	renderer := NewMarkdownRenderer()
+
+	testcases := []struct {
+		markdown     string
+		expectedHtml string
+	}{
+		{markdown: "# Title", expectedHtml: "<h1>Title</h1>\n"},
+		{markdown: "Some **bold**, *italic*, ~~gone~~ and `code`", expectedHtml: "<p>Some <strong>bold</strong>, <em>italic</em>, <del>gone</del> and <code>code</code></p>\n"},
+		{markdown: "snake_case_name stays", expectedHtml: "<p>snake_case_name stays</p>\n"},
+		{markdown: "line one  \nline two", expectedHtml: "<p>line one<br>\nline two</p>\n"},
+		{markdown: "- [ ] open\n- [x] done", expectedHtml: "<ul>\n<li class=\"task\"><input type=\"checkbox\" disabled> open</li>\n<li class=\"task\"><input type=\"checkbox\" disabled checked> done</li>\n</ul>\n"},
+		{markdown: "3. three\n4. four", expectedHtml: "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+		{markdown: "> quoted", expectedHtml: "<blockquote>\n<p>quoted</p>\n</blockquote>\n"},
+		{markdown: "---", expectedHtml: "<hr>\n"},
+		{markdown: "```go\nif a < b {}\n```", expectedHtml: "<pre><code class=\"language-go\">if a &lt; b {}</code></pre>\n"},
+		{markdown: "[docs](https://example.com/?a=1&b=2)", expectedHtml: "<p><a href=\"https://example.com/?a=1&amp;b=2\" rel=\"nofollow noopener noreferrer\" target=\"_blank\">docs</a></p>\n"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.markdown, func(t *testing.T) {
+			// Act
+			rendered := renderer.Render(tc.markdown)
+
+			// Assert
+			if rendered != tc.expectedHtml {
+				t.Errorf("Expected %q, but got %q", tc.expectedHtml, rendered)
+			}
+		})
+	}
+}
+
+func TestMarkdownRenderer_Sanitise(t *testing.T) {
+	renderer := NewMarkdownRenderer()
+
+	testcases := []string{
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"[click](javascript:alert(1))",
+		"[click](JAVASCRIPT:alert(1))",
+		"**<b onclick=alert(1)>**",
+		"`</code><script>`",
+		"```\n</code></pre><script>\n```",
+		"# <iframe src=evil>",
+	}
+
+	for _, markdown := range testcases {
+		t.Run(markdown, func(t *testing.T) {
+			// Act
+			rendered := renderer.Render(markdown)
+
+			// Assert
+			for _, forbidden := range []string{"<script", "<img", "<iframe", "<b ", "href=\"javascript", "href=\"JAVASCRIPT"} {
+				if strings.Contains(rendered, forbidden) {
+					t.Errorf("Expected %q to be sanitised, but got %q", markdown, rendered)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/floxo05/todoapi/internal/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// upper bound for the search of the next matching date, e.g. february 29th in yearly rules
+const maxRecurrenceSteps = 100
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+type Recurrence struct{}
+
+func NewRecurrence() *Recurrence {
+	return &Recurrence{}
+}
+
+// ParseRule parses the supported subset of an iCalendar RRULE (FREQ, INTERVAL, BYDAY, COUNT and UNTIL)
+func (r *Recurrence) ParseRule(rule string) (*types.RecurrenceRule, error) {
+	rule = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rule)), "RRULE:")
+
+	parsed := types.RecurrenceRule{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		if part == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(part, "=")
+		if !found || value == "" {
+			return nil, fmt.Errorf("invalid recurrence rule part '%s'", part)
+		}
+
+		switch key {
+		case "FREQ":
+			switch value {
+			case types.FrequencyDaily, types.FrequencyWeekly, types.FrequencyMonthly, types.FrequencyYearly:
+				parsed.Frequency = value
+			default:
+				return nil, fmt.Errorf("unsupported frequency '%s'", value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, errors.New("INTERVAL must be a positive number")
+			}
+			parsed.Interval = interval
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				weekday, ok := weekdayCodes[code]
+				if !ok {
+					return nil, fmt.Errorf("invalid weekday '%s'", code)
+				}
+				parsed.Weekdays = append(parsed.Weekdays, weekday)
+			}
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return nil, errors.New("COUNT must be a positive number")
+			}
+			parsed.Count = count
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, errors.New("UNTIL must have the format YYYYMMDD or YYYYMMDDTHHMMSSZ")
+			}
+			parsed.Until = &until
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part '%s'", key)
+		}
+	}
+
+	if parsed.Frequency == "" {
+		return nil, errors.New("FREQ is required")
+	}
+
+	if parsed.Count > 0 && parsed.Until != nil {
+		return nil, errors.New("COUNT and UNTIL must not be combined")
+	}
+
+	if len(parsed.Weekdays) > 0 && parsed.Frequency != types.FrequencyDaily && parsed.Frequency != types.FrequencyWeekly {
+		return nil, errors.New("BYDAY is only supported for daily and weekly rules")
+	}
+
+	return &parsed, nil
+}
+
+// FormatRule returns the canonical RRULE representation that is stored with a todo
+func (r *Recurrence) FormatRule(rule *types.RecurrenceRule) string {
+	parts := []string{"FREQ=" + rule.Frequency}
+
+	if rule.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(rule.Interval))
+	}
+
+	if len(rule.Weekdays) > 0 {
+		var codes []string
+		for _, code := range []string{"MO", "TU", "WE", "TH", "FR", "SA", "SU"} {
+			if containsWeekday(rule.Weekdays, weekdayCodes[code]) {
+				codes = append(codes, code)
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+
+	if rule.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(rule.Count))
+	}
+
+	if rule.Until != nil {
+		parts = append(parts, "UNTIL="+rule.Until.UTC().Format("20060102T150405Z"))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// NextOccurrence builds the todo following the given one in its series.
+// It returns nil if the series has ended.
+func (r *Recurrence) NextOccurrence(todo *types.Todo) (*types.Todo, error) {
+	if todo.RecurrenceRule == "" {
+		return nil, nil
+	}
+
+	if todo.DueAt == nil {
+		return nil, errors.New("recurring todos need a due date")
+	}
+
+	rule, err := r.ParseRule(todo.RecurrenceRule)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrence := todo.Occurrence + 1
+	if rule.Count > 0 && occurrence > rule.Count {
+		return nil, nil
+	}
+
+	dueAt, ok := nextDate(rule, *todo.DueAt)
+	if !ok || (rule.Until != nil && dueAt.After(*rule.Until)) {
+		return nil, nil
+	}
+
+	next := types.Todo{
+		Title:          todo.Title,
+		Completed:      false,
+		CreatedAt:      time.Now(),
+		OwnerID:        todo.OwnerID,
+		Category:       todo.Category,
+		DueAt:          &dueAt,
+		DueHasTime:     todo.DueHasTime,
+		StartHasTime:   todo.StartHasTime,
+		RecurrenceRule: todo.RecurrenceRule,
+		Occurrence:     occurrence,
+		ParentID:       todo.ParentID,
+		Priority:       todo.Priority,
+		Description:    todo.Description,
+		WorkspaceID:    todo.WorkspaceID,
+	}
+
+	// keep the distance between start and due date
+	if todo.StartAt != nil {
+		startAt := todo.StartAt.Add(dueAt.Sub(*todo.DueAt))
+		next.StartAt = &startAt
+	}
+
+	return &next, nil
+}
+
+func nextDate(rule *types.RecurrenceRule, from time.Time) (time.Time, bool) {
+	switch rule.Frequency {
+	case types.FrequencyDaily:
+		for step := 1; step <= maxRecurrenceSteps; step++ {
+			candidate := from.AddDate(0, 0, step*rule.Interval)
+			if len(rule.Weekdays) == 0 || containsWeekday(rule.Weekdays, candidate.Weekday()) {
+				return candidate, true
+			}
+		}
+	case types.FrequencyWeekly:
+		if len(rule.Weekdays) == 0 {
+			return from.AddDate(0, 0, 7*rule.Interval), true
+		}
+
+		// weeks start on monday, remaining days of the current week come first
+		weekStart := from.AddDate(0, 0, -((int(from.Weekday()) + 6) % 7))
+		for day := 0; day < 7; day++ {
+			candidate := weekStart.AddDate(0, 0, day)
+			if candidate.After(from) && containsWeekday(rule.Weekdays, candidate.Weekday()) {
+				return candidate, true
+			}
+		}
+
+		nextWeek := weekStart.AddDate(0, 0, 7*rule.Interval)
+		for day := 0; day < 7; day++ {
+			candidate := nextWeek.AddDate(0, 0, day)
+			if containsWeekday(rule.Weekdays, candidate.Weekday()) {
+				return candidate, true
+			}
+		}
+	case types.FrequencyMonthly, types.FrequencyYearly:
+		months := rule.Interval
+		if rule.Frequency == types.FrequencyYearly {
+			months *= 12
+		}
+
+		// like RRULE, months without the day (e.g. the 31st) are skipped instead of overflowing
+		for step := 1; step <= maxRecurrenceSteps; step++ {
+			firstOfMonth := time.Date(from.Year(), from.Month()+time.Month(step*months), 1,
+				from.Hour(), from.Minute(), from.Second(), 0, from.Location())
+			if from.Day() <= daysInMonth(firstOfMonth) {
+				return firstOfMonth.AddDate(0, 0, from.Day()-1), true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func parseUntil(value string) (time.Time, error) {
+	until, err := time.Parse("20060102T150405Z", value)
+	if err == nil {
+		return until, nil
+	}
+
+	// a date includes the whole day
+	until, err = time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return until.Add(24*time.Hour - time.Second), nil
+}
+
+func daysInMonth(date time.Time) int {
+	return time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func containsWeekday(weekdays []time.Weekday, weekday time.Weekday) bool {
+	for _, w := range weekdays {
+		if w == weekday {
+			return true
+		}
+	}
+
+	return false
+}
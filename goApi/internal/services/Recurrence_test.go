@@ -0,0 +1,116 @@
+package services
+
+import (
+	"github.com/floxo05/todoapi/internal/types"
+	"testing"
+	"time"
+)
+
+func TestRecurrence_ParseRule(t *testing.T) {
+	recurrence := NewRecurrence()
+
+	testcases := []struct {
+		rule          string
+		expectedRule  string
+		expectedError bool
+	}{
+		{rule: "FREQ=DAILY", expectedRule: "FREQ=DAILY"},
+		{rule: "rrule:freq=weekly;interval=2;byday=we,mo", expectedRule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE"},
+		{rule: "FREQ=MONTHLY;COUNT=3", expectedRule: "FREQ=MONTHLY;COUNT=3"},
+		{rule: "FREQ=YEARLY;UNTIL=20300101", expectedRule: "FREQ=YEARLY;UNTIL=20300101T235959Z"},
+		{rule: "", expectedError: true},
+		{rule: "FREQ=HOURLY", expectedError: true},
+		{rule: "FREQ=DAILY;INTERVAL=0", expectedError: true},
+		{rule: "FREQ=MONTHLY;BYDAY=MO", expectedError: true},
+		{rule: "FREQ=DAILY;COUNT=2;UNTIL=20300101", expectedError: true},
+		{rule: "FREQ=DAILY;BYMONTH=1", expectedError: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.rule, func(t *testing.T) {
+			// Act
+			rule, err := recurrence.ParseRule(tc.rule)
+
+			// Assert
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("Expected an error, but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			if formatted := recurrence.FormatRule(rule); formatted != tc.expectedRule {
+				t.Errorf("Expected rule %s, but got %s", tc.expectedRule, formatted)
+			}
+		})
+	}
+}
+
+func TestRecurrence_NextOccurrence(t *testing.T) {
+	recurrence := NewRecurrence()
+
+	// a wednesday
+	dueAt := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		rule        string
+		occurrence  int
+		expectedDue *time.Time
+	}{
+		{rule: "FREQ=DAILY;INTERVAL=2", expectedDue: date(2024, 2, 2)},
+		{rule: "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", expectedDue: date(2024, 2, 1)},
+		{rule: "FREQ=WEEKLY", expectedDue: date(2024, 2, 7)},
+		{rule: "FREQ=WEEKLY;BYDAY=MO,FR", expectedDue: date(2024, 2, 2)},
+		{rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TU", expectedDue: date(2024, 2, 12)},
+		{rule: "FREQ=MONTHLY", expectedDue: date(2024, 3, 31)},
+		{rule: "FREQ=YEARLY", expectedDue: date(2025, 1, 31)},
+		{rule: "FREQ=DAILY;COUNT=3", occurrence: 2, expectedDue: date(2024, 2, 1)},
+		{rule: "FREQ=DAILY;COUNT=3", occurrence: 3, expectedDue: nil},
+		{rule: "FREQ=WEEKLY;UNTIL=20240206", expectedDue: nil},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.rule, func(t *testing.T) {
+			todo := types.Todo{ID: 1, Title: "Test Todo", DueAt: &dueAt, RecurrenceRule: tc.rule, Occurrence: 1}
+			if tc.occurrence > 0 {
+				todo.Occurrence = tc.occurrence
+			}
+
+			// Act
+			next, err := recurrence.NextOccurrence(&todo)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Expected error to be nil, but got %s", err.Error())
+			}
+
+			if tc.expectedDue == nil {
+				if next != nil {
+					t.Errorf("Expected the series to end, but got %s", next.DueAt)
+				}
+				return
+			}
+
+			if next == nil {
+				t.Fatalf("Expected a next occurrence, but got nil")
+			}
+
+			if !next.DueAt.Equal(*tc.expectedDue) {
+				t.Errorf("Expected due date %s, but got %s", tc.expectedDue, next.DueAt)
+			}
+
+			if next.Occurrence != todo.Occurrence+1 || next.RecurrenceRule != tc.rule || next.Completed {
+				t.Errorf("Expected the next occurrence to continue the series, but got %+v", next)
+			}
+		})
+	}
+}
+
+func date(year int, month time.Month, day int) *time.Time {
+	d := time.Date(year, month, day, 9, 0, 0, 0, time.UTC)
+	return &d
+}
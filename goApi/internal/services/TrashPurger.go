@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"github.com/floxo05/todoapi/internal/types"
+	"log"
+	"time"
+)
+
+// TrashPurger permanently removes todos that stayed in the trash longer than the retention period
+type TrashPurger struct {
+	transactionManager types.TransactionManager
+	retention          time.Duration
+	interval           time.Duration
+}
+
+func NewTrashPurger(transactionManager types.TransactionManager, retention time.Duration, interval time.Duration) *TrashPurger {
+	return &TrashPurger{transactionManager: transactionManager, retention: retention, interval: interval}
+}
+
+// Run purges the trash right away and then once per interval until the context is cancelled
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		// a failed run leaves the trash and the search index as they were, the next run tries again
+		var purged int
+		err := p.transactionManager.WithTransaction(func(tx types.Transaction) error {
+			var err error
+			purged, err = tx.Todos().PurgeDeletedTodos(time.Now().Add(-p.retention))
+
+			return err
+		})
+		if err != nil {
+			log.Printf("purging the trash failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d todos from the trash", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -20,5 +20,17 @@ func (u *UserContext) GetUserFromContext(c *gin.Context) (*types.User, error) {
 		return nil, errors.New("could not retrieve user")
 	}
 
-	return u.UserRepository.GetUserByUsername(username.(string))
+	user, err := u.UserRepository.GetUserByUsername(username.(string))
+	if err != nil {
+		return nil, err
+	}
+
+	// the workspace the request works in, see routes.WorkspaceMiddleware
+	if workspaceID, ok := c.Get("workspace_id"); ok {
+		id := workspaceID.(int)
+		user.WorkspaceID = &id
+		user.WorkspaceRole = c.GetString("workspace_role")
+	}
+
+	return user, nil
 }
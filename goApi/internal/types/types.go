@@ -1,50 +1,604 @@
 package types
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"time"
 )
 
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrTagExists     = errors.New("a tag with this title already exists")
+	// ErrVersionConflict is returned by updates based on an outdated version of a todo
+	ErrVersionConflict = errors.New("the todo was changed in the meantime")
+	// ErrOccurrenceExists is returned when the next occurrence of a recurring todo was already spawned
+	ErrOccurrenceExists = errors.New("the next occurrence was already created")
+	// ErrNotCategoryMember is returned when the role of a user who is no member of the category is changed
+	ErrNotCategoryMember = errors.New("user is not a member of the category")
+	// ErrNotWorkspaceMember is returned when the role of a user who is no member of the workspace is changed
+	ErrNotWorkspaceMember = errors.New("user is not a member of the workspace")
+	// ErrPermissionDenied is returned when the user has no access to a todo or category or lacks the role for a change
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrPublicLinkNotFound is returned for public links that do not exist
+	ErrPublicLinkNotFound = errors.New("public link not found")
+)
+
 type UserRepository interface {
 	GetUserByUsername(username string) (*User, error)
 	CreateUser(user *User) error
-	ShareTodoWithUser(todoID int, user *User, shareUser *User) error
+	ShareTodoWithUser(todoID int, user *User, shareUser *User, role string) error
+	SetCollaboratorRole(todoID int, user *User, collaborator *User, role string) error
+	GetCollaborators(todoID int, user *User) ([]Collaborator, error)
+	RemoveCollaborator(todoID int, user *User, collaborator *User) error
+	LeaveTodo(todoID int, user *User) error
+	// assignees need at least editor access to the todo
+	AssignTodo(todoID int, user *User, assignee *User) error
+	UnassignTodo(todoID int, user *User, assignee *User) error
+	SetAutoArchiveDays(user *User, days *int) error
 }
 
 type TodoRepository interface {
-	GetAllTodosByUser(user *User) ([]Todo, error)
+	GetAllTodosByUser(user *User, filter TodoFilter) ([]Todo, error)
 	CreateTodo(todo *Todo) error
+	// UpdateTodoById rejects the update with ErrVersionConflict unless todo.Version is 0 or the current version
 	UpdateTodoById(todo *Todo, user *User) error
+	// UpdateTodoFields is UpdateTodoById for the given TodoFields only
+	UpdateTodoFields(todo *Todo, fields []string, user *User) error
 	DeleteTodoById(todo *Todo, user *User) error
 	IsOwner(todo *Todo, user *User) (bool, error)
+	RequireRole(todoID int, user *User, role string) error
+	GetTodoById(id int, user *User) (*Todo, error)
+	// CreateNextOccurrence rejects a second follow-up of the same todo with ErrOccurrenceExists
+	CreateNextOccurrence(next *Todo, previous *Todo) error
+	GetTodoTree(id int, user *User) (*Todo, error)
+	GetSubtaskIds(todo *Todo) ([]int, error)
+	MoveTodo(todo *Todo, parentID *int, user *User) error
+	ReorderTodo(todo *Todo, afterID *int, categoryID *int, user *User) error
+	GetTodosPageByUser(user *User, filter TodoFilter, page PageRequest) (*Page[Todo], error)
+	// SearchTodos returns a limited number of matches, truncated tells if there are more
+	SearchTodos(user *User, query SearchQuery) (todos []Todo, truncated bool, err error)
+	GetTrashByUser(user *User) ([]Todo, error)
+	RestoreTodo(todo *Todo, user *User) error
+	PurgeTodo(todo *Todo, user *User) error
+	PurgeDeletedTodos(deletedBefore time.Time) (int, error)
+	SetCompleted(todo *Todo, completed bool, user *User) error
+	ArchiveTodo(todo *Todo, user *User) error
+	UnarchiveTodo(todo *Todo, user *User) error
+	ArchiveCompletedTodos(user *User, categoryID *int) (int, error)
+	AutoArchiveCompletedTodos(now time.Time) (int, error)
+	// GetTodoRevisions returns the previous versions of the todo, newest first
+	GetTodoRevisions(todoID int, user *User) ([]TodoRevision, error)
+	// RevertTodo restores the fields of the todo to a previous version, the current version becomes a revision itself.
+	// A version in todo has to match the current one, otherwise ErrVersionConflict is returned.
+	RevertTodo(todo *Todo, revisionID int, user *User) error
+}
+
+// InvitationRepository handles the invitations to shared todos, the recipient only gets access after accepting
+type InvitationRepository interface {
+	CreateInvitation(todoID int, user *User, invitee *User, role string) error
+	GetInvitationsByUser(user *User) ([]Invitation, error)
+	AcceptInvitation(invitationID int, user *User) error
+	DeclineInvitation(invitationID int, user *User) error
+	BlockUser(user *User, blockedUser *User) error
+	UnblockUser(user *User, blockedUser *User) error
+	GetBlockedUsers(user *User) ([]string, error)
+}
+
+// PublicLinkRepository handles the read only links to a todo or a category that work without an account
+type PublicLinkRepository interface {
+	CreatePublicLink(link *PublicLink, user *User) error
+	// GetPublicLinksByUser returns the links the user created and the links of the todos and categories they manage
+	GetPublicLinksByUser(user *User) ([]PublicLink, error)
+	RevokePublicLink(linkID int, user *User) error
+	// GetPublicLinkByToken returns nil for unknown and expired tokens and if the creator is no manager anymore
+	GetPublicLinkByToken(token string) (*PublicLink, error)
+	CountAccess(link *PublicLink) error
+}
+
+type WorkspaceRepository interface {
+	CreateWorkspace(workspace *Workspace, user *User) error
+	GetWorkspacesByUser(user *User) ([]Workspace, error)
+	// WorkspaceRole returns the role of the user in the workspace, "" if they are no member
+	WorkspaceRole(workspaceID int, user *User) (string, error)
+	GetWorkspaceMembers(workspaceID int, user *User) ([]Collaborator, error)
+	// SetWorkspaceMember changes the role of a member, it fails with ErrNotWorkspaceMember for other users
+	SetWorkspaceMember(workspaceID int, user *User, member *User, role string) error
+	RemoveWorkspaceMember(workspaceID int, user *User, member *User) error
+	// InviteWorkspaceMember invites a user to the workspace, they only become a member after accepting
+	InviteWorkspaceMember(workspaceID int, user *User, invitee *User, role string) error
+	GetWorkspaceInvitationsByUser(user *User) ([]WorkspaceInvitation, error)
+	AcceptWorkspaceInvitation(invitationID int, user *User) error
+	DeclineWorkspaceInvitation(invitationID int, user *User) error
+}
+
+// OwnershipRepository hands todos over to another owner together with their subtasks. The recipient has to accept
+// a transfer unless an admin of the workspace of the todo forces it.
+type OwnershipRepository interface {
+	RequestTransfer(todoID int, user *User, recipient *User) error
+	// GetTransfersByUser returns the pending transfers the user sent or received
+	GetTransfersByUser(user *User) ([]OwnershipTransfer, error)
+	AcceptTransfer(transferID int, user *User) error
+	// DeclineTransfer lets the recipient decline and the owner cancel a pending transfer
+	DeclineTransfer(transferID int, user *User) error
+	ForceTransfer(todoID int, user *User, recipient *User) error
+	GetTransferHistory(todoID int, user *User) ([]OwnershipTransfer, error)
+}
+
+// CommentRepository handles the comments on todos, everyone with access to a todo can read and write comments
+type CommentRepository interface {
+	CreateComment(comment *Comment, user *User) error
+	GetCommentsByTodo(todoID int, user *User) ([]Comment, error)
+	// UpdateComment changes the body of a comment of the user and keeps the previous body in its history
+	UpdateComment(comment *Comment, user *User) error
+	// DeleteComment deletes a comment of the user, managers of the todo can delete every comment
+	DeleteComment(commentID int, todoID int, user *User) error
+	GetCommentHistory(commentID int, todoID int, user *User) ([]CommentRevision, error)
+	// GetMentionsByUser returns the comments the user is mentioned in, newest first
+	GetMentionsByUser(user *User) ([]Comment, error)
+}
+
+// ActivityRepository keeps the history of the changes made to todos
+type ActivityRepository interface {
+	RecordEvent(event *TodoEvent) error
+	// GetTodoHistory returns the events of a todo the user has access to, oldest first
+	GetTodoHistory(todoID int, user *User) ([]TodoEvent, error)
+	// GetActivityByUser returns the events of all todos of the active workspace the user has access to, newest first
+	GetActivityByUser(user *User, page PageRequest) (*Page[TodoEvent], error)
+}
+
+// Transaction gives access to repositories that work on the same database transaction
+type Transaction interface {
+	Todos() TodoRepository
+	Tags() TagRepository
+	Users() UserRepository
+	Invitations() InvitationRepository
+	Ownership() OwnershipRepository
+	Categories() CategoryRepository
+	Comments() CommentRepository
+	// Activity records the events of the changes, so a change and its event are committed together
+	Activity() ActivityRepository
+	// Savepoint undoes the changes of fn if it returns an error, the rest of the transaction is kept
+	Savepoint(fn func() error) error
+}
+
+type TransactionManager interface {
+	// WithTransaction commits the changes of fn if it returns nil and rolls them back otherwise
+	WithTransaction(fn func(tx Transaction) error) error
+}
+
+// tags are personal, every user only sees their own tags on a todo
+type TagRepository interface {
+	CreateTag(tag *Tag) error
+	GetTagsByUserId(userID int) ([]Tag, error)
+	GetTagsByTodoIds(todoIDs []int, userID int) (map[int][]Tag, error)
+	RenameTag(tag *Tag, user *User) error
+	MergeTags(source *Tag, target *Tag, user *User) error
+	DeleteTag(tag *Tag, user *User) error
+	AttachTag(todoID int, tag *Tag, user *User) error
+	DetachTag(todoID int, tag *Tag, user *User) error
+}
+
+// SearchIndex matches the free text part of a search, the repository applies access and all other filters
+type SearchIndex interface {
+	IndexTodo(document SearchDocument) error
+	RemoveTodo(todoID int) error
+	// SearchTodos returns the ids of the todos of the user in the active workspace containing all words (as prefix)
+	// and phrases, best matches first. Indexes that do not know who can see a todo return all matches unlimited,
+	// the caller has to limit them after checking the access.
+	SearchTodos(user *User, words []string, phrases []string, limit int) ([]int, error)
 }
 
 type CategoryRepository interface {
 	UpsertCategory(category *Category) error
 	GetCategoryFromDB(category *Category) (*Category, error)
 	GetCategoryByID(id int) (*Category, error)
-	GetCategoriesByUserId(userID int) ([]Category, error)
+	// the categories of the user are the ones of the workspace, nil for the personal space
+	GetCategoriesByUserId(userID int, workspaceID *int) ([]Category, error)
+	GetCategoriesPageByUserId(userID int, workspaceID *int, page PageRequest) (*Page[Category], error)
+	// CategoryRole returns the role of the user on the category, owner for the creator and "" without access
+	CategoryRole(categoryID int, user *User) (string, error)
+	GetCategoryMembers(categoryID int, user *User) ([]Collaborator, error)
+	// SetCategoryMember changes the role of a member, it fails with ErrNotCategoryMember for other users
+	SetCategoryMember(categoryID int, user *User, member *User, role string) error
+	RemoveCategoryMember(categoryID int, user *User, member *User) error
+	// InviteCategoryMember invites a user to the category, they only become a member after accepting
+	InviteCategoryMember(categoryID int, user *User, invitee *User, role string) error
+	GetCategoryInvitationsByUser(user *User) ([]CategoryInvitation, error)
+	AcceptCategoryInvitation(invitationID int, user *User) error
+	DeclineCategoryInvitation(invitationID int, user *User) error
+	// GetCategoryTodoIds returns the ids of the todos in the category without checking the access of a user
+	GetCategoryTodoIds(categoryID int) ([]int, error)
 }
 
 type Todo struct {
-	ID        int       `json:"id"`
-	Title     string    `json:"title"`
-	Completed bool      `json:"completed"`
-	CreatedAt time.Time `json:"created_at"`
-	OwnerID   int       `json:"owner_id"`
-	Category  Category  `json:"category"`
+	ID             int        `json:"id"`
+	Title          string     `json:"title"`
+	Completed      bool       `json:"completed"`
+	CreatedAt      time.Time  `json:"created_at"`
+	OwnerID        int        `json:"owner_id"`
+	Category       Category   `json:"category"`
+	DueAt          *time.Time `json:"due_at"`
+	DueHasTime     bool       `json:"due_has_time"`
+	StartAt        *time.Time `json:"start_at"`
+	StartHasTime   bool       `json:"start_has_time"`
+	RecurrenceRule string     `json:"recurrence_rule"`
+	Occurrence     int        `json:"occurrence"`
+	NextOccurrence *Todo      `json:"next_occurrence,omitempty"`
+	ParentID       *int       `json:"parent_id"`
+	SubtaskCount   int        `json:"subtask_count"`
+	SubtasksDone   int        `json:"subtasks_done"`
+	Subtasks       []Todo     `json:"subtasks,omitempty"`
+	Priority       string     `json:"priority"`
+	Description    string     `json:"description"`
+	Tags           []Tag      `json:"tags"`
+	// Position is the place of the todo in the list of the requesting user
+	Position float64 `json:"position"`
+	// Role is the role of the requesting user on the todo
+	Role string `json:"role"`
+	// DeletedAt is set while the todo is in the trash
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at"`
+	// ArchivedAt is set while the user archived the todo, archived todos are left out of the todo list of the user
+	ArchivedAt *time.Time `json:"archived_at"`
+	// WorkspaceID is nil for todos in the personal space of their owner
+	WorkspaceID *int `json:"workspace_id"`
+	// Assignees are the usernames of the users responsible for the todo
+	Assignees []string `json:"assignees"`
+	// Version counts the changes of the todo, it is sent as ETag and checked against If-Match on updates
+	Version int `json:"version"`
+}
+
+const (
+	PriorityNone   = "none"
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+// fields of a todo that can be changed by an update, named like in the json of a todo
+const (
+	FieldTitle          = "title"
+	FieldCompleted      = "completed"
+	FieldCategory       = "category"
+	FieldDueAt          = "due_at"
+	FieldDueHasTime     = "due_has_time"
+	FieldStartAt        = "start_at"
+	FieldStartHasTime   = "start_has_time"
+	FieldRecurrenceRule = "recurrence_rule"
+	FieldPriority       = "priority"
+	FieldDescription    = "description"
+)
+
+var TodoFields = []string{FieldTitle, FieldCompleted, FieldCategory, FieldDueAt, FieldDueHasTime, FieldStartAt,
+	FieldStartHasTime, FieldRecurrenceRule, FieldPriority, FieldDescription}
+
+// Priorities are ordered from lowest to highest, the index is the level stored in the database
+var Priorities = []string{PriorityNone, PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent}
+
+// roles of a user on a todo: viewers can read it, editors change it, managers also share it with others
+// and the owner can do everything
+const (
+	RoleViewer  = "viewer"
+	RoleEditor  = "editor"
+	RoleManager = "manager"
+	RoleOwner   = "owner"
+)
+
+// Roles are ordered from least to most rights, every role includes the rights of the ones before
+var Roles = []string{RoleViewer, RoleEditor, RoleManager, RoleOwner}
+
+// roles of a user in a workspace: guests only see what is shared with them, members see and edit all todos of the
+// workspace, admins manage the todos and the members and the owner can do everything
+const (
+	WorkspaceGuest  = "guest"
+	WorkspaceMember = "member"
+	WorkspaceAdmin  = "admin"
+	WorkspaceOwner  = "owner"
+)
+
+// WorkspaceRoles are ordered from least to most rights like Roles
+var WorkspaceRoles = []string{WorkspaceGuest, WorkspaceMember, WorkspaceAdmin, WorkspaceOwner}
+
+// due filter modes for GetAllTodosByUser
+const (
+	DueOverdue  = "overdue"
+	DueToday    = "today"
+	DueThisWeek = "week"
+	DueNone     = "none"
+)
+
+// ownership filter modes for GetAllTodosByUser
+const (
+	OwnershipOwn    = "own"
+	OwnershipShared = "shared"
+)
+
+// tag filter modes for GetAllTodosByUser
+const (
+	TagModeAll = "all"
+	TagModeAny = "any"
+)
+
+// sort fields and orders for GetAllTodosByUser, without sort field todos are in the manual order of the user
+const (
+	SortPosition   = "position"
+	SortCreatedAt  = "created_at"
+	SortArchivedAt = "archived_at"
+	SortDueAt      = "due_at"
+	SortPriority   = "priority"
+	SortTitle      = "title"
+
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+type TodoFilter struct {
+	Due       string
+	Completed *bool
+	// CategoryID 0 selects todos without category
+	CategoryID *int
+	Priorities []string
+	Ownership  string
+	SortBy     string
+	SortOrder  string
+	// Tags are tag titles, TagMode decides whether todos need all or any of them
+	Tags    []string
+	TagMode string
+	// Archived selects the archived todos instead of the active ones
+	Archived bool
+	// AssignedToMe selects the todos the user is an assignee of
+	AssignedToMe bool
+	// Location is the time zone of the client, due filters use its calendar day. nil stands for UTC
+	Location *time.Location
+}
+
+// recurrence frequencies supported in RecurrenceRule.Frequency
+const (
+	FrequencyDaily   = "DAILY"
+	FrequencyWeekly  = "WEEKLY"
+	FrequencyMonthly = "MONTHLY"
+	FrequencyYearly  = "YEARLY"
+)
+
+// RecurrenceRule is the parsed form of an iCalendar RRULE like "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10".
+// Todo.Occurrence holds the 1-based position of a todo in its series.
+type RecurrenceRule struct {
+	Frequency string
+	Interval  int
+	Weekdays  []time.Weekday
+	// Count ends the series after the given number of occurrences, 0 means no limit
+	Count int
+	Until *time.Time
+}
+
+type PageRequest struct {
+	Limit int
+	// Cursor is the opaque next_cursor of the previous page, empty for the first page
+	Cursor    string
+	WithTotal bool
+}
+
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+	Total      *int    `json:"total,omitempty"`
+}
+
+type SearchDocument struct {
+	TodoID      int
+	Title       string
+	Category    string
+	Description string
+}
+
+// SearchQuery is the parsed form of a search like `report "q3 numbers" category:work is:open due:<2024-06-01`
+type SearchQuery struct {
+	Words     []string
+	Phrases   []string
+	Category  string
+	Completed *bool
+	Shared    *bool
+	// due dates on the calendar days [DueFrom, DueBefore), given as midnight UTC
+	DueFrom   *time.Time
+	DueBefore *time.Time
+	// Location is the time zone of the client, due dates with a time use its day boundaries. nil stands for UTC
+	Location *time.Location
 }
 
 type Category struct {
 	ID            int    `json:"id"`
 	Title         string `json:"title"`
 	CreatedUserId int    `json:"created_user_id"`
+	WorkspaceID   *int   `json:"workspace_id"`
+}
+
+type Tag struct {
+	ID            int    `json:"id"`
+	Title         string `json:"title"`
+	CreatedUserId int    `json:"created_user_id"`
 }
 
 type User struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// AutoArchiveDays archives completed todos of the user this many days after completion, nil turns it off
+	AutoArchiveDays *int `json:"auto_archive_days"`
+	// WorkspaceID is the workspace the request works in, nil for the personal space. WorkspaceRole is the role
+	// of the user in it.
+	WorkspaceID   *int   `json:"-"`
+	WorkspaceRole string `json:"-"`
+}
+
+type Workspace struct {
+	ID            int       `json:"id"`
+	Title         string    `json:"title"`
+	CreatedUserId int       `json:"created_user_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Role is the role of the requesting user in the workspace
+	Role string `json:"role"`
+}
+
+// Invitation is a pending invitation of the user to a todo
+type Invitation struct {
+	ID        int       `json:"id"`
+	TodoID    int       `json:"todo_id"`
+	TodoTitle string    `json:"todo_title"`
+	InvitedBy string    `json:"invited_by"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CategoryInvitation is a pending invitation of the user to a category
+type CategoryInvitation struct {
+	ID            int       `json:"id"`
+	CategoryID    int       `json:"category_id"`
+	CategoryTitle string    `json:"category_title"`
+	InvitedBy     string    `json:"invited_by"`
+	Role          string    `json:"role"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// WorkspaceInvitation is a pending invitation of the user to a workspace
+type WorkspaceInvitation struct {
+	ID             int       `json:"id"`
+	WorkspaceID    int       `json:"workspace_id"`
+	WorkspaceTitle string    `json:"workspace_title"`
+	InvitedBy      string    `json:"invited_by"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// Comment is a comment on a todo, Mentions are the usernames of the collaborators mentioned with @username
+type Comment struct {
+	ID        int        `json:"id"`
+	TodoID    int        `json:"todo_id"`
+	Author    string     `json:"author"`
+	Body      string     `json:"body"`
+	Mentions  []string   `json:"mentions"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// CommentRevision is a previous body of an edited comment, CreatedAt is when it was written
+type CommentRevision struct {
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OwnershipTransfer moves a todo from one owner to another, TransferredAt is nil while the transfer is pending
+type OwnershipTransfer struct {
+	ID        int    `json:"id"`
+	TodoID    int    `json:"todo_id"`
+	TodoTitle string `json:"todo_title"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	// ForcedBy is the admin who transferred the todo without asking the recipient
+	ForcedBy      *string    `json:"forced_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	TransferredAt *time.Time `json:"transferred_at"`
+}
+
+// PublicLink gives everyone with the token read access to a todo or to the todos of a category
+type PublicLink struct {
+	ID             int        `json:"id"`
+	Token          string     `json:"token"`
+	TodoID         *int       `json:"todo_id"`
+	CategoryID     *int       `json:"category_id"`
+	CreatedUserID  int        `json:"created_user_id"`
+	Password       string     `json:"-"`
+	HasPassword    bool       `json:"has_password"`
+	WorkspaceID    *int       `json:"workspace_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	AccessCount    int        `json:"access_count"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+}
+
+// PublicTodo is the part of a todo that is visible through a public link
+type PublicTodo struct {
+	Title       string       `json:"title"`
+	Completed   bool         `json:"completed"`
+	DueAt       *time.Time   `json:"due_at"`
+	DueHasTime  bool         `json:"due_has_time"`
+	Priority    string       `json:"priority"`
+	Description string       `json:"description"`
+	Subtasks    []PublicTodo `json:"subtasks"`
+}
+
+// TodoRevision is a previous version of a todo, ReplacedBy changed it at ReplacedAt
+type TodoRevision struct {
+	ID             int        `json:"id"`
+	TodoID         int        `json:"todo_id"`
+	Title          string     `json:"title"`
+	Completed      bool       `json:"completed"`
+	CategoryID     *int       `json:"category_id"`
+	CategoryTitle  *string    `json:"category_title"`
+	DueAt          *time.Time `json:"due_at"`
+	DueHasTime     bool       `json:"due_has_time"`
+	StartAt        *time.Time `json:"start_at"`
+	StartHasTime   bool       `json:"start_has_time"`
+	RecurrenceRule string     `json:"recurrence_rule"`
+	Priority       string     `json:"priority"`
+	Description    string     `json:"description"`
+	ReplacedBy     string     `json:"replaced_by"`
+	ReplacedAt     time.Time  `json:"replaced_at"`
+}
+
+// TodoEvent is a change made to a todo. Changes holds the changed fields, Subject the collaborator a sharing event
+// is about. The purge of a todo has no TodoID, it keeps the title and the workspace of the todo.
+type TodoEvent struct {
+	ID        int                    `json:"id"`
+	TodoID    int                    `json:"todo_id"`
+	TodoTitle string                 `json:"todo_title"`
+	ActorID   int                    `json:"-"`
+	Actor     string                 `json:"actor"`
+	Action    string                 `json:"action"`
+	Subject   *string                `json:"subject,omitempty"`
+	Changes   map[string]FieldChange `json:"changes,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	// WorkspaceID is only set for purge events
+	WorkspaceID *int `json:"-"`
+}
+
+// FieldChange is the value of a field before and after a change, nil if the field was not set
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// actions of a TodoEvent
+const (
+	EventCreated     = "created"
+	EventUpdated     = "updated"
+	EventCompleted   = "completed"
+	EventReopened    = "reopened"
+	EventDeleted     = "deleted"
+	EventRestored    = "restored"
+	EventReverted    = "reverted"
+	EventShared      = "shared"
+	EventRoleChanged = "role_changed"
+	EventUnshared    = "unshared"
+	EventLeft        = "left"
+	EventAssigned    = "assigned"
+	EventUnassigned  = "unassigned"
+	EventArchived    = "archived"
+	EventUnarchived  = "unarchived"
+	EventMoved       = "moved"
+	EventPurged      = "purged"
+	EventTransferred = "transferred"
+	EventTagged      = "tagged"
+	EventUntagged    = "untagged"
+)
+
+// Collaborator is a user with access to a todo or a member of a shared category
+type Collaborator struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
 }
 
 type UserContextInterface interface {
@@ -52,6 +606,26 @@ type UserContextInterface interface {
 }
 
 type CreateTodoRequest struct {
+	Title          string  `json:"title"`
+	DueAt          *string `json:"due_at"`
+	DueHasTime     *bool   `json:"due_has_time"`
+	StartAt        *string `json:"start_at"`
+	StartHasTime   *bool   `json:"start_has_time"`
+	RecurrenceRule *string `json:"recurrence_rule"`
+	ParentID       *int    `json:"parent_id"`
+	Priority       *string `json:"priority"`
+	Description    *string `json:"description"`
+}
+
+// CreatePublicLinkRequest needs either a todo or a category, the link does not expire without ExpiresAt
+type CreatePublicLinkRequest struct {
+	TodoID     *int       `json:"todo_id"`
+	CategoryID *int       `json:"category_id"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	Password   string     `json:"password"`
+}
+
+type CreateWorkspaceRequest struct {
 	Title string `json:"title"`
 }
 
@@ -59,21 +633,135 @@ type CreateCategoryRequest struct {
 	Title string `json:"title"`
 }
 
+type TagRequest struct {
+	Title string `json:"title"`
+}
+
+type MergeTagsRequest struct {
+	TargetID int `json:"target_id"`
+}
+
+type AttachTagRequest struct {
+	TagID int `json:"tag_id"`
+}
+
 type AuthRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
 type UpdateTodoRequest struct {
-	ID        *int      `json:"id"`
-	Title     *string   `json:"title"`
-	Completed *bool     `json:"completed"`
-	Category  *Category `json:"category"`
+	ID             *int      `json:"id"`
+	Title          *string   `json:"title"`
+	Completed      *bool     `json:"completed"`
+	Category       *Category `json:"category"`
+	DueAt          *string   `json:"due_at"`
+	DueHasTime     *bool     `json:"due_has_time"`
+	StartAt        *string   `json:"start_at"`
+	StartHasTime   *bool     `json:"start_has_time"`
+	RecurrenceRule *string   `json:"recurrence_rule"`
+	Priority       *string   `json:"priority"`
+	Description    *string   `json:"description"`
+}
+
+type RenderMarkdownRequest struct {
+	Markdown string `json:"markdown"`
+}
+
+// ArchiveCompletedRequest archives the completed todos of a category (0 for none), without category all of them
+type ArchiveCompletedRequest struct {
+	CategoryID *int `json:"category_id"`
+}
+
+type AutoArchiveRequest struct {
+	Days *int `json:"days"`
+}
+
+// actions of a bulk operation
+const (
+	BulkComplete     = "complete"
+	BulkReopen       = "reopen"
+	BulkMoveCategory = "move_category"
+	BulkDelete       = "delete"
+	BulkShare        = "share"
+	BulkTag          = "tag"
+)
+
+// statuses of the result of a bulk operation
+const (
+	BulkStatusDone       = "done"
+	BulkStatusFailed     = "failed"
+	BulkStatusRolledBack = "rolled_back"
+	BulkStatusSkipped    = "skipped"
+)
+
+type BulkRequest struct {
+	Operations []BulkOperation `json:"operations"`
+	// AllOrNothing rolls back every operation as soon as one of them fails
+	AllOrNothing bool `json:"all_or_nothing"`
 }
 
+// BulkOperation applies an action to one todo, CategoryID belongs to move_category, Username and Role to share
+// and TagID to tag
+type BulkOperation struct {
+	Action     string `json:"action"`
+	TodoID     int    `json:"todo_id"`
+	CategoryID *int   `json:"category_id"`
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	TagID      int    `json:"tag_id"`
+}
+
+type BulkResult struct {
+	TodoID int    `json:"todo_id"`
+	Action string `json:"action"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type MoveTodoRequest struct {
+	ParentID *int `json:"parent_id"`
+}
+
+// ReorderTodoRequest places a todo after another one, without AfterID it becomes the first todo.
+// With CategoryID the todo is moved into the category (0 for none) and AfterID must belong to it.
+type ReorderTodoRequest struct {
+	AfterID    *int `json:"after_id"`
+	CategoryID *int `json:"category_id"`
+}
+
+// ShareToUserRequest invites a user to a todo, the collaborator becomes an editor if the request has no role
 type ShareToUserRequest struct {
 	Username string `json:"username"`
 	TodoID   int    `json:"id"`
+	Role     string `json:"role"`
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type CommentRequest struct {
+	Body string `json:"body"`
+}
+
+type TransferOwnershipRequest struct {
+	Username string `json:"username"`
+}
+
+type BlockUserRequest struct {
+	Username string `json:"username"`
+}
+
+type RecurrenceInterface interface {
+	ParseRule(rule string) (*RecurrenceRule, error)
+	FormatRule(rule *RecurrenceRule) string
+	NextOccurrence(todo *Todo) (*Todo, error)
+}
+
+type MarkdownRendererInterface interface {
+	// Render converts markdown into sanitised HTML
+	Render(markdown string) string
 }
 
 type PasswordHasherInterface interface {
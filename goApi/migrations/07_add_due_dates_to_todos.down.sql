@@ -0,0 +1,6 @@
+ALTER TABLE todos
+    DROP INDEX IF EXISTS todos_due_at_index,
+    DROP COLUMN IF EXISTS due_at,
+    DROP COLUMN IF EXISTS due_has_time,
+    DROP COLUMN IF EXISTS start_at,
+    DROP COLUMN IF EXISTS start_has_time;
@@ -0,0 +1,6 @@
+ALTER TABLE todos
+    ADD due_at         DATETIME NULL,
+    ADD due_has_time   BOOLEAN  NOT NULL DEFAULT false,
+    ADD start_at       DATETIME NULL,
+    ADD start_has_time BOOLEAN  NOT NULL DEFAULT false,
+    ADD INDEX todos_due_at_index (due_at);
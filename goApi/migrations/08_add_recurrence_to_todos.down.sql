@@ -0,0 +1,3 @@
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS recurrence_rule,
+    DROP COLUMN IF EXISTS occurrence;
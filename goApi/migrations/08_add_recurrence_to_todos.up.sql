@@ -0,0 +1,3 @@
+ALTER TABLE todos
+    ADD recurrence_rule VARCHAR(255) NOT NULL DEFAULT '',
+    ADD occurrence      INT          NOT NULL DEFAULT 1;
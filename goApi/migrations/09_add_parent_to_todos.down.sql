@@ -0,0 +1,3 @@
+ALTER TABLE todos
+    DROP CONSTRAINT todos_todos_id_fk,
+    DROP COLUMN IF EXISTS parent_id;
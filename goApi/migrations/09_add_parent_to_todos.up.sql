@@ -0,0 +1,4 @@
+ALTER TABLE todos
+    ADD parent_id INT NULL,
+    ADD CONSTRAINT todos_todos_id_fk
+        FOREIGN KEY (parent_id) REFERENCES todos (id);
@@ -0,0 +1,3 @@
+ALTER TABLE todos
+    DROP INDEX IF EXISTS todos_priority_index,
+    DROP COLUMN IF EXISTS priority;
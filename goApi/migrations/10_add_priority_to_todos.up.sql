@@ -0,0 +1,5 @@
+# priority levels: 0 = none, 1 = low, 2 = medium, 3 = high, 4 = urgent
+
+ALTER TABLE todos
+    ADD priority TINYINT NOT NULL DEFAULT 0,
+    ADD INDEX todos_priority_index (priority);
@@ -0,0 +1,12 @@
+CREATE TABLE todo_search
+(
+    todo_id INT PRIMARY KEY,
+    content TEXT NOT NULL,
+    FULLTEXT INDEX todo_search_content_index (content),
+    FOREIGN KEY (todo_id) REFERENCES todos (id) ON DELETE CASCADE
+);
+
+INSERT INTO todo_search (todo_id, content)
+SELECT t.id, LOWER(CONCAT_WS(' ', t.title, c.title))
+FROM todos t
+         LEFT JOIN categories c ON c.id = t.category_id;
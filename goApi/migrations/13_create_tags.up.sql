@@ -0,0 +1,17 @@
+CREATE TABLE tags
+(
+    id              INT AUTO_INCREMENT PRIMARY KEY,
+    title           VARCHAR(100) NOT NULL,
+    created_user_id INT          NOT NULL,
+    UNIQUE KEY tags_user_title_unique (created_user_id, title),
+    FOREIGN KEY (created_user_id) REFERENCES users (id)
+);
+
+CREATE TABLE todo_tags
+(
+    todo_id INT,
+    tag_id  INT,
+    PRIMARY KEY (todo_id, tag_id),
+    FOREIGN KEY (todo_id) REFERENCES todos (id),
+    FOREIGN KEY (tag_id) REFERENCES tags (id)
+);
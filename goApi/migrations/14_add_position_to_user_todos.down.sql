@@ -0,0 +1,3 @@
+ALTER TABLE user_todos
+    DROP INDEX IF EXISTS user_todos_position_index,
+    DROP COLUMN IF EXISTS position;
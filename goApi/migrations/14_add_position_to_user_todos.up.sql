@@ -0,0 +1,8 @@
+# position of the todo in the list of the user, sorted ascending with gaps for manual reordering
+ALTER TABLE user_todos
+    ADD position DOUBLE NOT NULL DEFAULT 0,
+    ADD INDEX user_todos_position_index (user_id, position);
+
+# keep the previous order of creation
+UPDATE user_todos
+SET position = todo_id * 1024;
@@ -0,0 +1,3 @@
+ALTER TABLE todos
+    DROP INDEX IF EXISTS todos_deleted_at_index,
+    DROP COLUMN IF EXISTS deleted_at;
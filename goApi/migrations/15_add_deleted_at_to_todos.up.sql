@@ -0,0 +1,4 @@
+# todos with deleted_at are in the trash of their owner until they are restored or purged
+ALTER TABLE todos
+    ADD deleted_at DATETIME NULL,
+    ADD INDEX todos_deleted_at_index (deleted_at);
@@ -0,0 +1,7 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS auto_archive_days;
+
+ALTER TABLE todos
+    DROP INDEX IF EXISTS todos_archived_at_index,
+    DROP COLUMN IF EXISTS archived_at,
+    DROP COLUMN IF EXISTS completed_at;
@@ -0,0 +1,13 @@
+# archived todos are left out of the todo list, completed_at tells when they can be archived automatically
+ALTER TABLE todos
+    ADD completed_at DATETIME NULL,
+    ADD archived_at  DATETIME NULL,
+    ADD INDEX todos_archived_at_index (archived_at);
+
+UPDATE todos
+SET completed_at = UTC_TIMESTAMP()
+WHERE completed = true;
+
+# number of days after which completed todos of the user are archived, NULL turns it off
+ALTER TABLE users
+    ADD auto_archive_days INT NULL;
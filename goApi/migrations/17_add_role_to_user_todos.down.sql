@@ -0,0 +1,2 @@
+ALTER TABLE user_todos
+    DROP COLUMN IF EXISTS role;
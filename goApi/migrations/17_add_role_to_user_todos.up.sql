@@ -0,0 +1,8 @@
+# role of the user on the todo: viewer, editor, manager or owner. Existing collaborators keep their edit rights.
+ALTER TABLE user_todos
+    ADD role VARCHAR(10) NOT NULL DEFAULT 'editor';
+
+UPDATE user_todos ut
+    JOIN todos t ON t.id = ut.todo_id
+SET ut.role = 'owner'
+WHERE ut.user_id = t.owner_id;
@@ -0,0 +1,25 @@
+# a shared todo only shows up in the list of the recipient after they accepted the invitation
+CREATE TABLE invitations
+(
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    todo_id    INT         NOT NULL,
+    inviter_id INT         NOT NULL,
+    invitee_id INT         NOT NULL,
+    role       VARCHAR(10) NOT NULL,
+    created_at DATETIME    NOT NULL,
+    expires_at DATETIME    NOT NULL,
+    UNIQUE KEY invitations_todo_invitee_unique (todo_id, invitee_id),
+    FOREIGN KEY (todo_id) REFERENCES todos (id),
+    FOREIGN KEY (inviter_id) REFERENCES users (id),
+    FOREIGN KEY (invitee_id) REFERENCES users (id)
+);
+
+# users do not receive invitations from the users they blocked
+CREATE TABLE user_blocks
+(
+    user_id         INT,
+    blocked_user_id INT,
+    PRIMARY KEY (user_id, blocked_user_id),
+    FOREIGN KEY (user_id) REFERENCES users (id),
+    FOREIGN KEY (blocked_user_id) REFERENCES users (id)
+);
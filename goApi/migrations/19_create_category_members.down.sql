@@ -0,0 +1,4 @@
+ALTER TABLE user_todos
+    DROP INDEX IF EXISTS user_todos_via_category_id_index,
+    DROP COLUMN IF EXISTS via_category_id;
+DROP TABLE IF EXISTS category_members;
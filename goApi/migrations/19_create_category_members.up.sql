@@ -0,0 +1,15 @@
+# members of a shared category see all todos of the category with their role
+CREATE TABLE category_members
+(
+    category_id INT,
+    user_id     INT,
+    role        VARCHAR(10) NOT NULL,
+    PRIMARY KEY (category_id, user_id),
+    FOREIGN KEY (category_id) REFERENCES categories (id),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+# access that was granted through a shared category, it is taken away again with the membership
+ALTER TABLE user_todos
+    ADD via_category_id INT NULL,
+    ADD INDEX user_todos_via_category_id_index (via_category_id);
@@ -0,0 +1,18 @@
+# read only links to a todo or a category for people without an account
+CREATE TABLE public_links
+(
+    id               INT AUTO_INCREMENT PRIMARY KEY,
+    token            VARCHAR(64)  NOT NULL,
+    todo_id          INT          NULL,
+    category_id      INT          NULL,
+    created_user_id  INT          NOT NULL,
+    password         VARCHAR(255) NULL,
+    created_at       DATETIME     NOT NULL,
+    expires_at       DATETIME     NULL,
+    access_count     INT          NOT NULL DEFAULT 0,
+    last_accessed_at DATETIME     NULL,
+    UNIQUE KEY public_links_token_unique (token),
+    FOREIGN KEY (todo_id) REFERENCES todos (id),
+    FOREIGN KEY (category_id) REFERENCES categories (id),
+    FOREIGN KEY (created_user_id) REFERENCES users (id)
+);
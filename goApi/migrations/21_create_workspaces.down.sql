@@ -0,0 +1,17 @@
+ALTER TABLE public_links
+    DROP COLUMN IF EXISTS workspace_id;
+
+ALTER TABLE user_todos
+    DROP INDEX IF EXISTS user_todos_via_workspace_id_index,
+    DROP COLUMN IF EXISTS via_workspace_id;
+
+ALTER TABLE categories
+    DROP FOREIGN KEY IF EXISTS categories_workspaces_id_fk,
+    DROP COLUMN IF EXISTS workspace_id;
+
+ALTER TABLE todos
+    DROP FOREIGN KEY IF EXISTS todos_workspaces_id_fk,
+    DROP COLUMN IF EXISTS workspace_id;
+
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
@@ -0,0 +1,39 @@
+CREATE TABLE workspaces
+(
+    id              INT AUTO_INCREMENT PRIMARY KEY,
+    title           VARCHAR(255) NOT NULL,
+    created_user_id INT          NOT NULL,
+    created_at      DATETIME     NOT NULL,
+    FOREIGN KEY (created_user_id) REFERENCES users (id)
+);
+
+# role of the user in the workspace: guest, member, admin or owner
+CREATE TABLE workspace_members
+(
+    workspace_id INT,
+    user_id      INT,
+    role         VARCHAR(10) NOT NULL,
+    PRIMARY KEY (workspace_id, user_id),
+    FOREIGN KEY (workspace_id) REFERENCES workspaces (id),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+# todos and categories without workspace belong to the personal space of their user
+ALTER TABLE todos
+    ADD workspace_id INT NULL,
+    ADD CONSTRAINT todos_workspaces_id_fk
+        FOREIGN KEY (workspace_id) REFERENCES workspaces (id);
+
+ALTER TABLE categories
+    ADD workspace_id INT NULL,
+    ADD CONSTRAINT categories_workspaces_id_fk
+        FOREIGN KEY (workspace_id) REFERENCES workspaces (id);
+
+# access that was granted through the membership in a workspace
+ALTER TABLE user_todos
+    ADD via_workspace_id INT NULL,
+    ADD INDEX user_todos_via_workspace_id_index (via_workspace_id);
+
+# public links show the todos of the workspace of their todo or category
+ALTER TABLE public_links
+    ADD workspace_id INT NULL;
@@ -0,0 +1,16 @@
+# a transfer is pending until the recipient accepts it, the transferred ones are the ownership history of the todo
+CREATE TABLE ownership_transfers
+(
+    id             INT AUTO_INCREMENT PRIMARY KEY,
+    todo_id        INT      NOT NULL,
+    from_user_id   INT      NOT NULL,
+    to_user_id     INT      NOT NULL,
+    # the workspace admin who moved the todo without asking the recipient
+    forced_by_id   INT      NULL,
+    created_at     DATETIME NOT NULL,
+    transferred_at DATETIME NULL,
+    FOREIGN KEY (todo_id) REFERENCES todos (id),
+    FOREIGN KEY (from_user_id) REFERENCES users (id),
+    FOREIGN KEY (to_user_id) REFERENCES users (id),
+    FOREIGN KEY (forced_by_id) REFERENCES users (id)
+);
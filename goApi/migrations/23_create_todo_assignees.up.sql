@@ -0,0 +1,9 @@
+# the users responsible for a todo, assignees always have at least editor access to it
+CREATE TABLE todo_assignees
+(
+    todo_id INT,
+    user_id INT,
+    PRIMARY KEY (todo_id, user_id),
+    FOREIGN KEY (todo_id) REFERENCES todos (id),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
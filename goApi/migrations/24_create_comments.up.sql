@@ -0,0 +1,33 @@
+# comments of the collaborators on a todo, updated_at is set once a comment was edited
+CREATE TABLE comments
+(
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    todo_id    INT      NOT NULL,
+    user_id    INT      NOT NULL,
+    body       TEXT     NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NULL,
+    INDEX comments_todo_id_index (todo_id),
+    FOREIGN KEY (todo_id) REFERENCES todos (id),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+# the previous versions of edited comments
+CREATE TABLE comment_revisions
+(
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    comment_id INT      NOT NULL,
+    body       TEXT     NOT NULL,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (comment_id) REFERENCES comments (id) ON DELETE CASCADE
+);
+
+# users mentioned with @username in a comment
+CREATE TABLE comment_mentions
+(
+    comment_id INT,
+    user_id    INT,
+    PRIMARY KEY (comment_id, user_id),
+    FOREIGN KEY (comment_id) REFERENCES comments (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,15 @@
+# changes made to a todo, changes holds the changed fields with their old and new value as json
+CREATE TABLE todo_events
+(
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    todo_id    INT          NOT NULL,
+    actor_id   INT          NOT NULL,
+    action     VARCHAR(20)  NOT NULL,
+    # username of the collaborator a sharing event is about
+    subject    VARCHAR(255) NULL,
+    changes    TEXT         NULL,
+    created_at DATETIME     NOT NULL,
+    INDEX todo_events_todo_id_index (todo_id),
+    FOREIGN KEY (todo_id) REFERENCES todos (id),
+    FOREIGN KEY (actor_id) REFERENCES users (id)
+);
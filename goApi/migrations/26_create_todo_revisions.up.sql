@@ -0,0 +1,23 @@
+# previous versions of a todo, replaced_by changed the todo at replaced_at. The title of the category is kept
+# so a revert can bring back a category that was deleted since.
+CREATE TABLE todo_revisions
+(
+    id              INT AUTO_INCREMENT PRIMARY KEY,
+    todo_id         INT          NOT NULL,
+    title           VARCHAR(255) NOT NULL,
+    completed       BOOLEAN      NOT NULL,
+    category_id     INT          NULL,
+    category_title  VARCHAR(255) NULL,
+    due_at          DATETIME     NULL,
+    due_has_time    BOOLEAN      NOT NULL,
+    start_at        DATETIME     NULL,
+    start_has_time  BOOLEAN      NOT NULL,
+    recurrence_rule VARCHAR(255) NOT NULL,
+    priority        TINYINT      NOT NULL,
+    description     TEXT         NOT NULL,
+    replaced_by     INT          NOT NULL,
+    replaced_at     DATETIME     NOT NULL,
+    INDEX todo_revisions_todo_id_index (todo_id),
+    FOREIGN KEY (todo_id) REFERENCES todos (id),
+    FOREIGN KEY (replaced_by) REFERENCES users (id)
+);
@@ -0,0 +1,3 @@
+# version counts the changes of a todo, updates with an outdated version are rejected
+ALTER TABLE todos
+    ADD version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+ALTER TABLE todos
+    DROP FOREIGN KEY IF EXISTS todos_next_occurrence_id_fk,
+    DROP COLUMN IF EXISTS next_occurrence_id;
@@ -0,0 +1,5 @@
+# the follow-up spawned when a recurring todo was completed, it is spawned only once per occurrence
+ALTER TABLE todos
+    ADD next_occurrence_id INT NULL,
+    ADD CONSTRAINT todos_next_occurrence_id_fk
+        FOREIGN KEY (next_occurrence_id) REFERENCES todos (id) ON DELETE SET NULL;
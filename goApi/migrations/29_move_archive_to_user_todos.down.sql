@@ -0,0 +1,11 @@
+ALTER TABLE todos
+    ADD archived_at DATETIME NULL,
+    ADD INDEX todos_archived_at_index (archived_at);
+
+UPDATE todos t
+    JOIN user_todos ut ON ut.todo_id = t.id AND ut.user_id = t.owner_id
+SET t.archived_at = ut.archived_at;
+
+ALTER TABLE user_todos
+    DROP INDEX IF EXISTS user_todos_archived_at_index,
+    DROP COLUMN IF EXISTS archived_at;
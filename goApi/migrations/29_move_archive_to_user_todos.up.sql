@@ -0,0 +1,13 @@
+# every user archives the todos of their own list, the others keep seeing them
+ALTER TABLE user_todos
+    ADD archived_at DATETIME NULL,
+    ADD INDEX user_todos_archived_at_index (archived_at);
+
+UPDATE user_todos ut
+    JOIN todos t ON t.id = ut.todo_id
+SET ut.archived_at = t.archived_at
+WHERE t.archived_at IS NOT NULL;
+
+ALTER TABLE todos
+    DROP INDEX IF EXISTS todos_archived_at_index,
+    DROP COLUMN IF EXISTS archived_at;
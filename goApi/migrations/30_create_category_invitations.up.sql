@@ -0,0 +1,15 @@
+# a shared category only shows up for the member after they accepted the invitation
+CREATE TABLE category_invitations
+(
+    id          INT AUTO_INCREMENT PRIMARY KEY,
+    category_id INT         NOT NULL,
+    inviter_id  INT         NOT NULL,
+    invitee_id  INT         NOT NULL,
+    role        VARCHAR(10) NOT NULL,
+    created_at  DATETIME    NOT NULL,
+    expires_at  DATETIME    NOT NULL,
+    UNIQUE KEY category_invitations_category_invitee_unique (category_id, invitee_id),
+    FOREIGN KEY (category_id) REFERENCES categories (id),
+    FOREIGN KEY (inviter_id) REFERENCES users (id),
+    FOREIGN KEY (invitee_id) REFERENCES users (id)
+);
@@ -0,0 +1,15 @@
+# a user only joins a workspace after they accepted the invitation
+CREATE TABLE workspace_invitations
+(
+    id           INT AUTO_INCREMENT PRIMARY KEY,
+    workspace_id INT         NOT NULL,
+    inviter_id   INT         NOT NULL,
+    invitee_id   INT         NOT NULL,
+    role         VARCHAR(10) NOT NULL,
+    created_at   DATETIME    NOT NULL,
+    expires_at   DATETIME    NOT NULL,
+    UNIQUE KEY workspace_invitations_workspace_invitee_unique (workspace_id, invitee_id),
+    FOREIGN KEY (workspace_id) REFERENCES workspaces (id),
+    FOREIGN KEY (inviter_id) REFERENCES users (id),
+    FOREIGN KEY (invitee_id) REFERENCES users (id)
+);
@@ -0,0 +1,7 @@
+DELETE FROM todo_events WHERE todo_id IS NULL;
+
+ALTER TABLE todo_events
+    DROP FOREIGN KEY IF EXISTS todo_events_workspaces_id_fk,
+    DROP COLUMN IF EXISTS workspace_id,
+    DROP COLUMN IF EXISTS todo_title,
+    MODIFY todo_id INT NOT NULL;
@@ -0,0 +1,7 @@
+# the purge of a todo is recorded after the todo is gone, the event keeps the title and workspace of the todo
+ALTER TABLE todo_events
+    MODIFY todo_id INT NULL,
+    ADD todo_title VARCHAR(255) NULL,
+    ADD workspace_id INT NULL,
+    ADD CONSTRAINT todo_events_workspaces_id_fk
+        FOREIGN KEY (workspace_id) REFERENCES workspaces (id);
@@ -0,0 +1,3 @@
+ALTER TABLE user_todos
+    DROP INDEX IF EXISTS user_todos_via_parent_id_index,
+    DROP COLUMN IF EXISTS via_parent_id;
@@ -0,0 +1,12 @@
+# access that was inherited from a parent todo, it names the ancestor that was shared and is taken away again when
+# the todo is moved out from below it
+ALTER TABLE user_todos
+    ADD via_parent_id INT NULL,
+    ADD INDEX user_todos_via_parent_id_index (via_parent_id);
+
+# existing access to a subtask is attributed to its parent if the user can see the parent as well
+UPDATE user_todos ut
+    JOIN todos t ON t.id = ut.todo_id
+    JOIN user_todos p ON p.todo_id = t.parent_id AND p.user_id = ut.user_id
+SET ut.via_parent_id = t.parent_id
+WHERE ut.role <> 'owner';